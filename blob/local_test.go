@@ -0,0 +1,84 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "https://api.todanni.com/blobs", "secret")
+	require.NoError(t, err)
+
+	body := []byte("hello world")
+	key := "projects/1/abc"
+	require.NoError(t, store.Put(context.Background(), key, bytes.NewReader(body), int64(len(body)), "text/plain"))
+
+	download := Download{FileName: "hello.txt", ContentType: "text/plain"}
+	signed, err := store.SignedURL(key, download, time.Minute)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(signed, "https://api.todanni.com/blobs/projects/1/abc?"))
+
+	serve := func(rawURL string) *httptest.ResponseRecorder {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		u.Path = strings.TrimPrefix(u.Path, "/blobs")
+
+		w := httptest.NewRecorder()
+		store.ServeHTTP(w, httptest.NewRequest(http.MethodGet, u.String(), nil))
+		return w
+	}
+
+	w := serve(signed)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	require.Equal(t, `attachment; filename=hello.txt`, w.Header().Get("Content-Disposition"))
+	got, _ := io.ReadAll(w.Body)
+	require.Equal(t, body, got)
+
+	// Changing any signed parameter invalidates the link
+	tampered := strings.Replace(signed, "filename=hello.txt", "filename=evil.html", 1)
+	require.Equal(t, http.StatusForbidden, serve(tampered).Code)
+
+	expired, err := store.SignedURL(key, download, -time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, serve(expired).Code)
+
+	require.NoError(t, store.Delete(context.Background(), key))
+	require.Equal(t, http.StatusNotFound, serve(signed).Code)
+	require.NoError(t, store.Delete(context.Background(), key))
+}
+
+func TestValidKey(t *testing.T) {
+	require.True(t, validKey("projects/1/abc"))
+	require.False(t, validKey(""))
+	require.False(t, validKey("/etc/passwd"))
+	require.False(t, validKey("projects/../../etc/passwd"))
+	require.False(t, validKey("projects//abc"))
+	require.False(t, validKey(`projects\abc`))
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	virtualHosted, err := NewS3Store(S3Options{Endpoint: "https://s3.eu-west-2.amazonaws.com", Region: "eu-west-2", Bucket: "todanni"})
+	require.NoError(t, err)
+	u, err := virtualHosted.objectURL("projects/1/abc")
+	require.NoError(t, err)
+	require.Equal(t, "https://todanni.s3.eu-west-2.amazonaws.com/projects/1/abc", u.String())
+
+	pathStyle, err := NewS3Store(S3Options{Endpoint: "http://localhost:9000", Bucket: "todanni", PathStyle: true})
+	require.NoError(t, err)
+	u, err = pathStyle.objectURL("projects/1/abc")
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:9000/todanni/projects/1/abc", u.String())
+
+	_, err = pathStyle.objectURL("../abc")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,92 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/todanni/api/test"
+)
+
+type S3StoreTestSuite struct {
+	suite.Suite
+	store       *S3Store
+	CleanupFunc func()
+}
+
+func (s *S3StoreTestSuite) SetupSuite() {
+	var endpoint string
+	endpoint, s.CleanupFunc = test.SetupMinioWithDocker()
+
+	var err error
+	s.store, err = NewS3Store(S3Options{
+		Endpoint:  endpoint,
+		Bucket:    "attachments",
+		AccessKey: test.MinioAccessKey,
+		SecretKey: test.MinioSecretKey,
+		PathStyle: true,
+	})
+	require.NoError(s.T(), err)
+
+	req, err := http.NewRequest(http.MethodPut, endpoint+"/attachments", nil)
+	require.NoError(s.T(), err)
+	s.store.signer.signRequest(req, emptyPayloadHash, time.Now())
+	require.NoError(s.T(), s.store.do(req))
+}
+
+func (s *S3StoreTestSuite) TearDownSuite() {
+	s.CleanupFunc()
+}
+
+func (s *S3StoreTestSuite) Test_PutDownloadDelete() {
+	ctx := context.Background()
+	body := []byte("%PDF-1.4 quarterly numbers")
+	// Keys are escaped in the signed path, so check the ones that need it
+	key := "projects/1/report 2023+ü.pdf"
+
+	err := s.store.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "application/pdf")
+	require.NoError(s.T(), err)
+
+	download := Download{FileName: "Q1 report.pdf", ContentType: "application/pdf"}
+	signed, err := s.store.SignedURL(key, download, time.Minute)
+	require.NoError(s.T(), err)
+
+	resp, err := http.Get(signed)
+	require.NoError(s.T(), err)
+	downloaded, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode, string(downloaded))
+	require.Equal(s.T(), body, downloaded)
+	require.Equal(s.T(), "application/pdf", resp.Header.Get("Content-Type"))
+	require.Equal(s.T(), contentDisposition("Q1 report.pdf"), resp.Header.Get("Content-Disposition"))
+
+	// The overrides are part of the signature, so they can't be swapped for others
+	u, err := url.Parse(signed)
+	require.NoError(s.T(), err)
+	query := u.Query()
+	query.Set("response-content-type", "text/html")
+	u.RawQuery = query.Encode()
+	resp, err = http.Get(u.String())
+	require.NoError(s.T(), err)
+	resp.Body.Close()
+	require.Equal(s.T(), http.StatusForbidden, resp.StatusCode)
+
+	require.NoError(s.T(), s.store.Delete(ctx, key))
+
+	resp, err = http.Get(signed)
+	require.NoError(s.T(), err)
+	resp.Body.Close()
+	require.Equal(s.T(), http.StatusNotFound, resp.StatusCode)
+}
+
+func TestS3StoreTestSuite(t *testing.T) {
+	suite.Run(t, new(S3StoreTestSuite))
+}
@@ -0,0 +1,28 @@
+// Package blob stores uploaded files, like task attachments, and hands out signed,
+// expiring URLs to download them from.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store keeps blobs under keys made of path segments like "projects/1/abc".
+type Store interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads the blob until it expires.
+	SignedURL(key string, download Download, expiresIn time.Duration) (string, error)
+}
+
+// Download is how a signed URL serves the blob.
+type Download struct {
+	FileName    string
+	ContentType string
+}
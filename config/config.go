@@ -1,19 +1,79 @@
 package config
 
-import "github.com/caarlos0/env/v6"
+import (
+	"time"
+
+	"github.com/caarlos0/env/v6"
+)
 
 // Config contains the env variables needed to run the servers
 type Config struct {
-	DBHost            string `env:"POSTGRES_HOST,required"`
-	DBPort            int    `env:"POSTGRES_PORT,required"`
-	DBUser            string `env:"POSTGRES_USER,required"`
-	DBPassword        string `env:"POSTGRES_PASSWORD,required"`
-	DBName            string `env:"POSTGRES_NAME,required"`
-	SigningKey        string `env:"SIGNING_KEY,required"`
-	GoogleCredentials string `env:"GOOGLE_CREDENTIALS,required"`
-	SendGridAPIKey    string `env:"SENDGRID_API_KEY"`
-	Domain            string `env:"DOMAIN,required"`
-	RedirectURL       string `env:"REDIRECT_URL,required"`
+	DBHost     string `env:"POSTGRES_HOST,required"`
+	DBPort     int    `env:"POSTGRES_PORT,required"`
+	DBUser     string `env:"POSTGRES_USER,required"`
+	DBPassword string `env:"POSTGRES_PASSWORD,required"`
+	DBName     string `env:"POSTGRES_NAME,required"`
+	SigningKey string `env:"SIGNING_KEY,required"`
+	// JWTSigningKey is the base64 encoded PEM private key access tokens are signed with.
+	// It's required unless JWTTemporaryKey is set.
+	JWTSigningKey string `env:"JWT_SIGNING_KEY"`
+	// JWTTemporaryKey signs tokens with a key generated on start up when there's no
+	// JWTSigningKey. Tokens don't survive a restart, so it's only for development.
+	JWTTemporaryKey bool `env:"JWT_TEMPORARY_KEY" envDefault:"false"`
+	// JWTPreviousSigningKey is the key JWTSigningKey replaced, kept so existing tokens stay valid during a rotation
+	JWTPreviousSigningKey string `env:"JWT_PREVIOUS_SIGNING_KEY"`
+	GoogleCredentials     string `env:"GOOGLE_CREDENTIALS,required"`
+	SendGridAPIKey        string `env:"SENDGRID_API_KEY"`
+	Domain                string `env:"DOMAIN,required"`
+	RedirectURL           string `env:"REDIRECT_URL,required"`
+	// APIURL is the public base URL of this service, used to build provider callback URLs
+	APIURL string `env:"API_URL"`
+
+	GitHubClientID     string `env:"GITHUB_CLIENT_ID"`
+	GitHubClientSecret string `env:"GITHUB_CLIENT_SECRET"`
+
+	OIDCProviderName string `env:"OIDC_PROVIDER_NAME" envDefault:"oidc"`
+	OIDCIssuerURL    string `env:"OIDC_ISSUER_URL"`
+	OIDCClientID     string `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string `env:"OIDC_CLIENT_SECRET"`
+
+	// PermissionCacheTTL is how long project and dashboard membership lookups are cached
+	PermissionCacheTTL time.Duration `env:"PERMISSION_CACHE_TTL" envDefault:"30s"`
+	// TrustTokenPermissions lets the project and dashboard claims in a token grant access without a lookup
+	TrustTokenPermissions bool `env:"TRUST_TOKEN_PERMISSIONS" envDefault:"false"`
+
+	// MaxSubtaskDepth is how many levels of subtasks can be nested under a task
+	MaxSubtaskDepth int `env:"MAX_SUBTASK_DEPTH" envDefault:"3"`
+	// RecurrenceInterval is how often recurring tasks on a schedule are checked for new instances
+	RecurrenceInterval time.Duration `env:"RECURRENCE_INTERVAL" envDefault:"5m"`
+
+	// BlobStore is where attachments are kept, either "local" or "s3"
+	BlobStore string `env:"BLOB_STORE" envDefault:"local"`
+	// BlobDir is the directory the local blob store keeps files in
+	BlobDir     string `env:"BLOB_DIR" envDefault:"blobs"`
+	S3Endpoint  string `env:"S3_ENDPOINT"`
+	S3Region    string `env:"S3_REGION" envDefault:"us-east-1"`
+	S3Bucket    string `env:"S3_BUCKET"`
+	S3AccessKey string `env:"S3_ACCESS_KEY"`
+	S3SecretKey string `env:"S3_SECRET_KEY"`
+	// S3PathStyle puts the bucket in the URL path, which MinIO needs
+	S3PathStyle bool `env:"S3_PATH_STYLE" envDefault:"false"`
+
+	// AttachmentMaxSize is the largest file in bytes that can be attached to a task
+	AttachmentMaxSize int64 `env:"ATTACHMENT_MAX_SIZE" envDefault:"26214400"`
+	// AttachmentProjectQuota is how many bytes of attachments each project can store, 0 for no limit
+	AttachmentProjectQuota int64 `env:"ATTACHMENT_PROJECT_QUOTA" envDefault:"104857600"`
+	// AttachmentContentTypes are the media types that can be attached, like image/* or application/pdf
+	AttachmentContentTypes []string `env:"ATTACHMENT_CONTENT_TYPES" envSeparator:"," envDefault:"image/*,application/pdf,text/plain,text/markdown,text/csv"`
+	// AttachmentURLExpiry is how long attachment download links work for
+	AttachmentURLExpiry time.Duration `env:"ATTACHMENT_URL_EXPIRY" envDefault:"15m"`
+
+	// TriageUrgentWithin is how close to their deadline tasks count as urgent in the triage view
+	TriageUrgentWithin time.Duration `env:"TRIAGE_URGENT_WITHIN" envDefault:"48h"`
+
+	// SearchBackend is how search matches text, "postgres" for full text search or "like"
+	// for plain pattern matching that needs no search indexes
+	SearchBackend string `env:"SEARCH_BACKEND" envDefault:"postgres"`
 }
 
 func NewFromEnv() (Config, error) {
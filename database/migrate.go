@@ -0,0 +1,156 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/rank"
+)
+
+type migration struct {
+	name string
+	run  func(db *gorm.DB) error
+}
+
+// migrations bring existing rows in line with the models once AutoMigrate has
+// updated the schema. Each one has to be safe to run on every start up.
+var migrations = []migration{
+	{name: "project owner roles", run: backfillProjectOwnerRoles},
+	{name: "default task statuses", run: seedTaskStatuses},
+	{name: "task done to status", run: backfillTaskStatuses},
+	{name: "task ranks", run: backfillTaskRanks},
+	{name: "search columns", run: addSearchColumns},
+}
+
+// Migrate runs the data migrations in order.
+func Migrate(db *gorm.DB) error {
+	for _, m := range migrations {
+		if err := m.run(db); err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// backfillProjectOwnerRoles gives project owners the owner role. Members added before
+// roles existed get the member role from the column default.
+func backfillProjectOwnerRoles(db *gorm.DB) error {
+	return db.Exec(`UPDATE user_projects SET role = ?
+		FROM projects
+		WHERE projects.id = user_projects.project_id
+		AND projects.owner = user_projects.user_id
+		AND user_projects.role <> ?`, models.OwnerRole, models.OwnerRole).Error
+}
+
+// seedTaskStatuses gives projects created before task statuses existed the default ones.
+func seedTaskStatuses(db *gorm.DB) error {
+	var projectIDs []uint
+	result := db.Unscoped().Model(&models.Project{}).
+		Where("NOT EXISTS (SELECT 1 FROM task_statuses WHERE task_statuses.project_id = projects.id)").
+		Pluck("id", &projectIDs)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, projectID := range projectIDs {
+		statuses := models.DefaultTaskStatuses(projectID)
+		if err := db.Create(&statuses).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillTaskStatuses puts tasks without a status into their project's first
+// closed status if they're done, or its first open status if not.
+func backfillTaskStatuses(db *gorm.DB) error {
+	return db.Exec(`UPDATE tasks SET status_id = (
+			SELECT task_statuses.id FROM task_statuses
+			WHERE task_statuses.project_id = tasks.project_id
+			AND task_statuses.category = CASE WHEN tasks.done THEN ? ELSE ? END
+			ORDER BY task_statuses.position, task_statuses.id
+			LIMIT 1
+		)
+		WHERE tasks.status_id IS NULL`, models.ClosedCategory, models.OpenCategory).Error
+}
+
+// backfillTaskRanks ranks tasks that don't have one yet, in the order they were created,
+// after any tasks in the same status that are already ranked.
+func backfillTaskRanks(db *gorm.DB) error {
+	var statusIDs []uint
+	result := db.Unscoped().Model(&models.Task{}).
+		Where("status_id IS NOT NULL AND (rank IS NULL OR rank = '')").
+		Distinct().
+		Pluck("status_id", &statusIDs)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, statusID := range statusIDs {
+		var tasks []models.Task
+		result = db.Unscoped().
+			Where("status_id = ? AND (rank IS NULL OR rank = '')", statusID).
+			Order("created_at, id").
+			Find(&tasks)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var last []string
+		result = db.Unscoped().Model(&models.Task{}).
+			Where("status_id = ? AND rank <> ''", statusID).
+			Order(`rank COLLATE "C" DESC`).
+			Limit(1).
+			Pluck("rank", &last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		ranks := rank.Spread(len(tasks))
+		for i, task := range tasks {
+			if len(last) > 0 {
+				next, err := rank.After(last[0])
+				if err != nil {
+					return err
+				}
+				ranks[i], last[0] = next, next
+			}
+
+			result = db.Unscoped().Model(&models.Task{}).Where("id = ?", task.ID).Update("rank", ranks[i])
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+	}
+	return nil
+}
+
+// searchColumns are the text search vectors kept alongside the searchable tables, with
+// titles and names weighted above the text under them.
+var searchColumns = map[string]string{
+	"tasks": `setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+		setweight(to_tsvector('english', COALESCE(description, '')), 'B')`,
+	"task_comments": `setweight(to_tsvector('english', COALESCE(body, '')), 'C')`,
+	"projects":      `setweight(to_tsvector('english', COALESCE(name, '')), 'A')`,
+}
+
+// addSearchColumns adds the generated search_vector columns search matches against, and
+// the GIN indexes that keep it fast. Postgres keeps the columns up to date itself, so the
+// models don't know about them.
+func addSearchColumns(db *gorm.DB) error {
+	for _, table := range []string{"tasks", "task_comments", "projects"} {
+		err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (%s) STORED`, table, searchColumns[table])).Error
+		if err != nil {
+			return err
+		}
+
+		err = db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_search_vector ON %s USING GIN (search_vector)", table, table)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
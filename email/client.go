@@ -2,6 +2,8 @@ package email
 
 import (
 	"errors"
+	"fmt"
+	"html"
 
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
@@ -11,7 +13,9 @@ import (
 )
 
 const (
-	ProjectInviteEmailSubject = "ToDanni Project Invitation"
+	ProjectInviteEmailSubject   = "ToDanni Project Invitation"
+	DashboardInviteEmailSubject = "ToDanni Dashboard Invitation"
+	MentionEmailSubject         = "You were mentioned on ToDanni"
 )
 
 var (
@@ -20,7 +24,8 @@ var (
 
 type SenderClient interface {
 	SendProjectInvitationEmail(email ProjectInviteEmail) error
-	SendDashboardInvitationEmail() error
+	SendDashboardInvitationEmail(email DashboardInviteEmail) error
+	SendMentionEmail(email MentionEmail) error
 }
 
 type emailClient struct {
@@ -53,7 +58,45 @@ func (e *emailClient) SendProjectInvitationEmail(email ProjectInviteEmail) error
 	return nil
 }
 
-func (e *emailClient) SendDashboardInvitationEmail() error {
-	//TODO implement me
-	panic("implement me")
+func (e *emailClient) SendDashboardInvitationEmail(email DashboardInviteEmail) error {
+	to := mail.NewEmail(email.RecipientName, email.RecipientEmail)
+
+	plainTextContent := fmt.Sprintf("%s has invited you to join the dashboard %s. "+
+		"Log in to ToDanni to accept or reject the invitation.", email.InviterName, email.DashboardName)
+	htmlContent := fmt.Sprintf("<strong>%s</strong> has invited you to join the dashboard <strong>%s</strong>. "+
+		"Log in to ToDanni to accept or reject the invitation.",
+		html.EscapeString(email.InviterName), html.EscapeString(email.DashboardName))
+
+	message := mail.NewSingleEmail(Sender, DashboardInviteEmailSubject, to, plainTextContent, htmlContent)
+
+	response, err := e.client.Send(message)
+	if err != nil {
+		log.Error(err)
+		return errors.New("couldn't send email")
+	}
+
+	log.Info(response)
+	return nil
+}
+
+func (e *emailClient) SendMentionEmail(email MentionEmail) error {
+	to := mail.NewEmail(email.RecipientName, email.RecipientEmail)
+
+	plainTextContent := fmt.Sprintf("%s mentioned you on the task %s in %s:\n\n%s",
+		email.AuthorName, email.TaskTitle, email.ProjectName, email.CommentBody)
+	htmlContent := fmt.Sprintf("<strong>%s</strong> mentioned you on the task <strong>%s</strong> in %s:"+
+		"<blockquote>%s</blockquote>",
+		html.EscapeString(email.AuthorName), html.EscapeString(email.TaskTitle),
+		html.EscapeString(email.ProjectName), html.EscapeString(email.CommentBody))
+
+	message := mail.NewSingleEmail(Sender, MentionEmailSubject, to, plainTextContent, htmlContent)
+
+	response, err := e.client.Send(message)
+	if err != nil {
+		log.Error(err)
+		return errors.New("couldn't send email")
+	}
+
+	log.Info(response)
+	return nil
 }
@@ -7,4 +7,17 @@ type ProjectInviteEmail struct {
 }
 
 type DashboardInviteEmail struct {
+	DashboardName  string
+	InviterName    string
+	RecipientName  string
+	RecipientEmail string
+}
+
+type MentionEmail struct {
+	AuthorName     string
+	TaskTitle      string
+	ProjectName    string
+	CommentBody    string
+	RecipientName  string
+	RecipientEmail string
 }
@@ -0,0 +1,102 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const (
+	GitHubProviderName = "github"
+	gitHubAPIURL       = "https://api.github.com"
+)
+
+var (
+	ErrNoVerifiedEmail = errors.New("no verified email address")
+)
+
+type gitHubProvider struct {
+	oauthProvider
+	apiURL string
+}
+
+func NewGitHubProvider(clientID, clientSecret, redirectURL string) IdentityProvider {
+	return &gitHubProvider{
+		oauthProvider: oauthProvider{
+			name: GitHubProviderName,
+			config: &oauth2.Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Endpoint:     github.Endpoint,
+				Scopes:       []string{"read:user", "user:email"},
+			},
+		},
+		apiURL: gitHubAPIURL,
+	}
+}
+
+type gitHubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type gitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// UserInfo returns the GitHub user along with their primary email address, or
+// failing that any other verified one, as the email on the profile may be hidden.
+func (p *gitHubProvider) UserInfo(ctx context.Context, tok *oauth2.Token) (*UserInfo, error) {
+	var user gitHubUser
+	err := getJSON(ctx, tok, p.apiURL+"/user", &user)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []gitHubEmail
+	err = getJSON(ctx, tok, p.apiURL+"/user/emails", &emails)
+	if err != nil {
+		return nil, err
+	}
+
+	email, ok := verifiedGitHubEmail(emails)
+	if !ok {
+		return nil, ErrNoVerifiedEmail
+	}
+
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+
+	return &UserInfo{
+		Subject:       strconv.FormatInt(user.ID, 10),
+		Email:         email,
+		EmailVerified: true,
+		Name:          name,
+		Picture:       user.AvatarURL,
+	}, nil
+}
+
+func verifiedGitHubEmail(emails []gitHubEmail) (string, bool) {
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return email.Email, true
+		}
+	}
+
+	for _, email := range emails {
+		if email.Verified {
+			return email.Email, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,38 @@
+package identity
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	scopes "google.golang.org/api/oauth2/v2"
+)
+
+const (
+	GoogleProviderName = "google"
+	googleUserInfoURL  = "https://openidconnect.googleapis.com/v1/userinfo"
+)
+
+type googleProvider struct {
+	oauthProvider
+}
+
+// NewGoogleProvider returns a provider configured from a Google client credentials JSON file.
+// The callback URL is the one in the credentials.
+func NewGoogleProvider(credentials []byte) (IdentityProvider, error) {
+	config, err := google.ConfigFromJSON(credentials, scopes.OpenIDScope, scopes.UserinfoEmailScope, scopes.UserinfoProfileScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &googleProvider{
+		oauthProvider: oauthProvider{
+			name:   GoogleProviderName,
+			config: config,
+		},
+	}, nil
+}
+
+func (p *googleProvider) UserInfo(ctx context.Context, tok *oauth2.Token) (*UserInfo, error) {
+	return getOIDCUserInfo(ctx, tok, googleUserInfoURL)
+}
@@ -0,0 +1,89 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+)
+
+type oidcProvider struct {
+	oauthProvider
+	userInfoURL string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// NewOIDCProvider returns a provider for any OpenID Connect issuer, configured
+// from the issuer's discovery document.
+func NewOIDCProvider(ctx context.Context, name, issuerURL, clientID, clientSecret, redirectURL string) (IdentityProvider, error) {
+	var discovery oidcDiscovery
+	err := getJSON(ctx, nil, strings.TrimSuffix(issuerURL, "/")+discoveryPath, &discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.UserInfoEndpoint == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	return &oidcProvider{
+		oauthProvider: oauthProvider{
+			name: name,
+			config: &oauth2.Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  discovery.AuthorizationEndpoint,
+					TokenURL: discovery.TokenEndpoint,
+				},
+				Scopes: []string{"openid", "email", "profile"},
+			},
+		},
+		userInfoURL: discovery.UserInfoEndpoint,
+	}, nil
+}
+
+func (p *oidcProvider) UserInfo(ctx context.Context, tok *oauth2.Token) (*UserInfo, error) {
+	return getOIDCUserInfo(ctx, tok, p.userInfoURL)
+}
+
+type oidcUserInfo struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+// getOIDCUserInfo calls a standard OpenID Connect userinfo endpoint.
+func getOIDCUserInfo(ctx context.Context, tok *oauth2.Token, url string) (*UserInfo, error) {
+	var info oidcUserInfo
+	err := getJSON(ctx, tok, url, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Subject == "" {
+		return nil, errors.New("userinfo response has no subject")
+	}
+
+	return &UserInfo{
+		Subject:       info.Subject,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Name:          info.Name,
+		Picture:       info.Picture,
+	}, nil
+}
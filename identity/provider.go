@@ -0,0 +1,71 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// UserInfo is what an identity provider tells us about the user who logged in.
+type UserInfo struct {
+	// Subject is the provider's stable ID for the user
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// IdentityProvider is an OAuth 2.0 provider users can log in with.
+type IdentityProvider interface {
+	// Name is the provider's name as used in the /auth/{provider} routes
+	Name() string
+	AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string
+	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+	UserInfo(ctx context.Context, tok *oauth2.Token) (*UserInfo, error)
+}
+
+// oauthProvider implements the parts of IdentityProvider that are the same for every provider.
+type oauthProvider struct {
+	name   string
+	config *oauth2.Config
+}
+
+func (p *oauthProvider) Name() string {
+	return p.name
+}
+
+func (p *oauthProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return p.config.AuthCodeURL(state, opts...)
+}
+
+func (p *oauthProvider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.config.Exchange(ctx, code, opts...)
+}
+
+// getJSON makes a GET request authorised with the token and decodes the JSON response into v.
+func getJSON(ctx context.Context, tok *oauth2.Token, url string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Add("Accept", "application/json")
+	if tok != nil {
+		request.Header.Add("Authorization", "Bearer "+tok.AccessToken)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", response.StatusCode, url)
+	}
+
+	return json.NewDecoder(response.Body).Decode(v)
+}
@@ -0,0 +1,76 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestOIDCProvider_DiscoveryAndUserInfo(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                server.URL,
+			AuthorizationEndpoint: server.URL + "/authorize",
+			TokenEndpoint:         server.URL + "/token",
+			UserInfoEndpoint:      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"sub":"1234","email":"user@todanni.com","email_verified":true,"name":"User"}`))
+	})
+
+	provider, err := NewOIDCProvider(context.Background(), "company", server.URL, "client", "secret", "https://api/auth/company/callback")
+	require.NoError(t, err)
+	require.Equal(t, "company", provider.Name())
+	require.Contains(t, provider.AuthCodeURL("state"), server.URL+"/authorize?")
+
+	info, err := provider.UserInfo(context.Background(), &oauth2.Token{AccessToken: "access"})
+	require.NoError(t, err)
+	require.Equal(t, &UserInfo{
+		Subject:       "1234",
+		Email:         "user@todanni.com",
+		EmailVerified: true,
+		Name:          "User",
+	}, info)
+}
+
+func TestGitHubProvider_UsesVerifiedEmail(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":42,"login":"octocat","avatar_url":"https://avatars/42"}`))
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"email":"unverified@todanni.com","primary":true,"verified":false},
+			{"email":"verified@todanni.com","primary":false,"verified":true}
+		]`))
+	})
+
+	provider := NewGitHubProvider("client", "secret", "https://api/auth/github/callback").(*gitHubProvider)
+	provider.apiURL = server.URL
+
+	info, err := provider.UserInfo(context.Background(), &oauth2.Token{AccessToken: "access"})
+	require.NoError(t, err)
+	require.Equal(t, "42", info.Subject)
+	require.Equal(t, "verified@todanni.com", info.Email)
+	require.True(t, info.EmailVerified)
+	require.Equal(t, "octocat", info.Name)
+}
+
+func TestGitHubProvider_NoVerifiedEmail(t *testing.T) {
+	_, ok := verifiedGitHubEmail([]gitHubEmail{{Email: "user@todanni.com", Primary: true}})
+	require.False(t, ok)
+}
@@ -1,23 +1,35 @@
 package main
 
 import (
+	b64 "encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 
+	"github.com/todanni/api/blob"
 	"github.com/todanni/api/config"
 	"github.com/todanni/api/database"
+	"github.com/todanni/api/email"
 	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
 	"github.com/todanni/api/repository"
 	"github.com/todanni/api/service/auth"
 	"github.com/todanni/api/service/dashboard"
 	"github.com/todanni/api/service/project"
+	"github.com/todanni/api/service/search"
 	"github.com/todanni/api/service/task"
 	"github.com/todanni/api/token"
 )
 
+// blobsPath is where the local blob store serves downloads from
+const blobsPath = "/blobs"
+
 func main() {
 	// Read config
 	cfg, err := config.NewFromEnv()
@@ -33,30 +45,186 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Membership state lives on the join tables, so register their models before migrating
+	err = db.SetupJoinTable(&models.Dashboard{}, "Members", &models.DashboardMember{})
+	if err != nil {
+		log.Fatalf("couldn't set up join table: %v", err)
+	}
+	err = db.SetupJoinTable(&models.User{}, "Dashboards", &models.DashboardMember{})
+	if err != nil {
+		log.Fatalf("couldn't set up join table: %v", err)
+	}
+	err = db.SetupJoinTable(&models.Project{}, "Members", &models.ProjectMember{})
+	if err != nil {
+		log.Fatalf("couldn't set up join table: %v", err)
+	}
+	err = db.SetupJoinTable(&models.User{}, "Projects", &models.ProjectMember{})
+	if err != nil {
+		log.Fatalf("couldn't set up join table: %v", err)
+	}
+
 	// Perform migrations
-	err = db.AutoMigrate(&models.User{}, &models.Dashboard{}, &models.Project{}, &models.Task{})
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Dashboard{},
+		&models.Project{},
+		&models.ProjectInvite{},
+		&models.ProjectTransfer{},
+		&models.ProjectEvent{},
+		&models.Task{},
+		&models.TaskStatus{},
+		&models.TaskStatusTransition{},
+		&models.ChecklistItem{},
+		&models.TaskDependency{},
+		&models.TaskSeries{},
+		&models.TaskComment{},
+		&models.TaskCommentRevision{},
+		&models.Attachment{},
+		&models.Label{},
+		&models.SavedView{},
+		&models.TodayTask{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.RevokedToken{},
+		&models.Identity{},
+		&models.PersonalAccessToken{},
+	)
 	if err != nil {
 		log.Fatalf("couldn't auto migrate: %v", err)
 	}
 
+	err = database.Migrate(db)
+	if err != nil {
+		log.Fatalf("couldn't migrate data: %v", err)
+	}
+
 	// Initialise router
 	r := mux.NewRouter()
 
-	// Initialise middleware
-	authMiddleware := token.NewAuthMiddleware(cfg.SigningKey)
+	// Initialise clients
+	emailClient := email.NewEmailClient(cfg)
+
+	blobs, err := newBlobStore(cfg, r)
+	if err != nil {
+		log.Fatalf("couldn't set up blob store: %v", err)
+	}
 
 	// Initialise repositories
 	userRepo := repository.NewUserRepository(db)
 	projectRepo := repository.NewProjectRepository(db)
 	taskRepo := repository.NewTaskRepository(db)
+	statusRepo := repository.NewTaskStatusRepository(db)
+	checklistRepo := repository.NewChecklistRepository(db)
+	dependencyRepo := repository.NewTaskDependencyRepository(db)
+	seriesRepo := repository.NewTaskSeriesRepository(db)
+	commentRepo := repository.NewTaskCommentRepository(db)
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	labelRepo := repository.NewLabelRepository(db)
+	savedViewRepo := repository.NewSavedViewRepository(db)
+	todayRepo := repository.NewTodayRepository(db)
 	dashboardRepo := repository.NewDashboardRepository(db)
+	searchRepo, err := newSearchRepository(cfg, db)
+	if err != nil {
+		log.Fatalf("couldn't set up search: %v", err)
+	}
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	personalTokenRepo := repository.NewPersonalAccessTokenRepository(db)
+
+	// Load the keys access tokens are signed with
+	keys, err := loadKeySet(cfg)
+	if err != nil {
+		log.Fatalf("couldn't load signing keys: %v", err)
+	}
+
+	// Initialise middleware
+	authMiddleware := token.NewAuthMiddleware(keys).
+		WithSessionStore(sessionRepo).
+		WithPersonalAccessTokens(personalTokenRepo)
+
+	// Initialise permission checks
+	permissions := permission.NewChecker(projectRepo, dashboardRepo, permission.Options{
+		CacheTTL:         cfg.PermissionCacheTTL,
+		TrustTokenClaims: cfg.TrustTokenPermissions,
+	})
 
 	// Initialise services
-	project.NewProjectService(r, *authMiddleware, projectRepo)
-	task.NewTaskService(r, taskRepo, *authMiddleware)
-	dashboard.NewDashboardService(r, dashboardRepo)
-	auth.NewAuthService(r, cfg, userRepo, dashboardRepo, projectRepo, *authMiddleware)
+	project.NewProjectService(r, *authMiddleware, projectRepo, userRepo, statusRepo, taskRepo, labelRepo, emailClient, permissions)
+	task.NewTaskService(r, taskRepo, statusRepo, checklistRepo, dependencyRepo, seriesRepo, userRepo, projectRepo, commentRepo, attachmentRepo, labelRepo, savedViewRepo, todayRepo, blobs, emailClient, *authMiddleware, permissions, task.Options{
+		MaxSubtaskDepth:        cfg.MaxSubtaskDepth,
+		RecurrenceInterval:     cfg.RecurrenceInterval,
+		AttachmentMaxSize:      cfg.AttachmentMaxSize,
+		AttachmentProjectQuota: cfg.AttachmentProjectQuota,
+		AttachmentContentTypes: cfg.AttachmentContentTypes,
+		AttachmentURLExpiry:    cfg.AttachmentURLExpiry,
+		UrgentWithin:           cfg.TriageUrgentWithin,
+	})
+	dashboard.NewDashboardService(r, dashboardRepo, userRepo, emailClient, *authMiddleware, permissions)
+	search.NewSearchService(r, searchRepo, *authMiddleware)
+	auth.NewAuthService(r, cfg, userRepo, dashboardRepo, projectRepo, refreshTokenRepo, sessionRepo, personalTokenRepo, keys, *authMiddleware)
 
 	// Start the servers and listen
 	log.Fatal(http.ListenAndServe(":8083", r))
 }
+
+// newBlobStore sets up the configured blob store. The local store serves its own signed
+// download links, so it's mounted on the router under /blobs.
+func newBlobStore(cfg config.Config, r *mux.Router) (blob.Store, error) {
+	switch cfg.BlobStore {
+	case "s3":
+		return blob.NewS3Store(blob.S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	case "", "local":
+		store, err := blob.NewLocalStore(cfg.BlobDir, strings.TrimSuffix(cfg.APIURL, "/")+blobsPath, cfg.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+		r.PathPrefix(blobsPath + "/").Handler(http.StripPrefix(blobsPath, store))
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.BlobStore)
+	}
+}
+
+// newSearchRepository sets up the configured search backend.
+func newSearchRepository(cfg config.Config, db *gorm.DB) (repository.SearchRepository, error) {
+	switch cfg.SearchBackend {
+	case "", "postgres":
+		return repository.NewSearchRepository(db), nil
+	case "like":
+		return repository.NewLikeSearchRepository(db), nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", cfg.SearchBackend)
+	}
+}
+
+// loadKeySet decodes the configured signing keys. A temporary key is only generated if
+// it's been asked for, since tokens signed with it don't survive a restart and can't be
+// checked by other instances.
+func loadKeySet(cfg config.Config) (*token.KeySet, error) {
+	if cfg.JWTSigningKey == "" {
+		if !cfg.JWTTemporaryKey {
+			return nil, errors.New("JWT_SIGNING_KEY has to be set, or JWT_TEMPORARY_KEY for development")
+		}
+		log.Warn("JWT_SIGNING_KEY isn't set, signing tokens with a temporary key")
+		return token.GenerateKeySet()
+	}
+
+	active, err := b64.StdEncoding.DecodeString(cfg.JWTSigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := b64.StdEncoding.DecodeString(cfg.JWTPreviousSigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return token.ParseKeySet(active, previous)
+}
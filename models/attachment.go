@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// Attachment is a file uploaded to a task. The file itself lives in the blob store under
+// StorageKey, which is never shown to clients.
+type Attachment struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	TaskID      uint      `json:"task_id" gorm:"index"`
+	ProjectID   uint      `json:"project_id" gorm:"index"`
+	UploadedBy  string    `json:"uploaded_by"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-" gorm:"uniqueIndex"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// ChecklistItem is a step of a task that's too small to be a subtask of its own.
+type ChecklistItem struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	TaskID    uint      `json:"task_id" gorm:"index"`
+	Text      string    `json:"text"`
+	Done      bool      `json:"done"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
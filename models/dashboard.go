@@ -8,12 +8,31 @@ import (
 )
 
 type Dashboard struct {
-	ID        uuid.UUID      `gorm:"primarykey" json:"id"`
-	Status    Status         `json:"status"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
-	Members   []User         `json:"members" gorm:"many2many:user_dashboards;"`
+	ID          uuid.UUID         `gorm:"primarykey" json:"id"`
+	Name        string            `json:"name"`
+	Owner       string            `json:"owner"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt    `gorm:"index" json:"deleted_at"`
+	Members     []User            `json:"members" gorm:"many2many:user_dashboards;"`
+	Memberships []DashboardMember `json:"-" gorm:"foreignKey:DashboardID"`
+	Projects    []Project         `json:"projects" gorm:"many2many:dashboard_projects;"`
+}
+
+// DashboardMember is the user_dashboards join row. It holds the state of a
+// user's membership, so a dashboard only shows up for a user once they've
+// accepted the invitation to it.
+type DashboardMember struct {
+	DashboardID uuid.UUID `gorm:"primarykey" json:"dashboard_id"`
+	UserID      string    `gorm:"primarykey" json:"user_id"`
+	Status      Status    `json:"status"`
+	InvitedBy   string    `json:"invited_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (DashboardMember) TableName() string {
+	return "user_dashboards"
 }
 
 type Status string
@@ -22,4 +41,6 @@ const (
 	PendingStatus  Status = "PENDING"
 	AcceptedStatus Status = "ACCEPTED"
 	RejectedStatus Status = "REJECTED"
+	RevokedStatus  Status = "REVOKED"
+	ExpiredStatus  Status = "EXPIRED"
 )
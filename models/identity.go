@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Identity links a login with an identity provider to a user. A user can have
+// several, one for each provider they've logged in with.
+type Identity struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_identity_provider_subject"`
+	Subject   string    `json:"-" gorm:"uniqueIndex:idx_identity_provider_subject"`
+	UserID    string    `json:"user_id" gorm:"index"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
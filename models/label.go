@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// Label categorises a project's tasks, like "bug" or "design". Names are unique within
+// a project, ignoring case. Color is a hex color like #d73a4a.
+type Label struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	ProjectID uint      `json:"project_id" gorm:"index"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
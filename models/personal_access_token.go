@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// TokenScope limits what a personal access token can be used for.
+type TokenScope string
+
+const (
+	ReadScope      TokenScope = "read"
+	ReadWriteScope TokenScope = "read-write"
+)
+
+// PersonalAccessToken is a long-lived token a user creates for scripts and CI.
+// Only the hash of the token is stored, along with its first few characters so
+// the user can tell their tokens apart. If ProjectIDs is set, the token can only
+// be used to access those projects.
+type PersonalAccessToken struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     string `gorm:"index"`
+	Name       string
+	Prefix     string
+	TokenHash  string `gorm:"uniqueIndex"`
+	Scope      TokenScope
+	ProjectIDs []uint `gorm:"serializer:json"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// IsActive returns whether the token can still be used at the given time.
+func (t PersonalAccessToken) IsActive(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Project struct {
 	gorm.Model
@@ -9,11 +13,51 @@ type Project struct {
 	Members []User `json:"members" gorm:"many2many:user_projects;"`
 }
 
-// TODO: Make status an enum
+// ProjectRole is what a member is allowed to do in a project.
+type ProjectRole string
+
+const (
+	OwnerRole  ProjectRole = "owner"
+	AdminRole  ProjectRole = "admin"
+	MemberRole ProjectRole = "member"
+	ViewerRole ProjectRole = "viewer"
+)
+
+// ProjectMember is the user_projects join row, holding the member's role in the project.
+type ProjectMember struct {
+	ProjectID uint        `gorm:"primarykey" json:"project_id"`
+	UserID    string      `gorm:"primarykey" json:"user_id"`
+	User      User        `json:"-"`
+	Role      ProjectRole `gorm:"default:member" json:"role"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func (ProjectMember) TableName() string {
+	return "user_projects"
+}
 
+// ProjectInvite is an invitation for a user to join a project. The user only
+// becomes a member once they accept it, and only while it hasn't expired.
 type ProjectInvite struct {
-	ID        uint   `json:"id" gorm:"primarykey"`
-	ProjectID uint   `json:"project_id"`
-	UserID    string `json:"user_id"`
-	Status    string `json:"status"`
+	ID        uint    `json:"id" gorm:"primarykey"`
+	ProjectID uint    `json:"project_id"`
+	Project   Project `json:"-"`
+	UserID    string  `json:"user_id"`
+	InvitedBy string  `json:"invited_by"`
+	// Role is the role the user gets in the project when they accept
+	Role      ProjectRole `json:"role" gorm:"default:member"`
+	Status    Status      `json:"status"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// CurrentStatus returns the status of the invite, taking into account
+// whether a pending invite has expired.
+func (i ProjectInvite) CurrentStatus(now time.Time) Status {
+	if i.Status == PendingStatus && now.After(i.ExpiresAt) {
+		return ExpiredStatus
+	}
+	return i.Status
 }
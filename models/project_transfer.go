@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// ProjectTransfer is a request from a project's owner to hand the project over
+// to another member, for when the new owner has to accept it first.
+type ProjectTransfer struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	ProjectID  uint      `json:"project_id" gorm:"index"`
+	FromUserID string    `json:"from_user_id"`
+	ToUserID   string    `json:"to_user_id"`
+	Status     Status    `json:"status"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CurrentStatus returns the status of the transfer, taking into account
+// whether a pending transfer has expired.
+func (t ProjectTransfer) CurrentStatus(now time.Time) Status {
+	if t.Status == PendingStatus && now.After(t.ExpiresAt) {
+		return ExpiredStatus
+	}
+	return t.Status
+}
+
+// RoleChanges returns the members' roles once the transfer goes through, the new owner's
+// first. The previous owner stays on as an admin.
+func (t ProjectTransfer) RoleChanges() []ProjectMember {
+	return []ProjectMember{
+		{ProjectID: t.ProjectID, UserID: t.ToUserID, Role: OwnerRole},
+		{ProjectID: t.ProjectID, UserID: t.FromUserID, Role: AdminRole},
+	}
+}
+
+// Event returns the entry for the project's history when the actor does something to the transfer.
+func (t ProjectTransfer) Event(eventType ProjectEventType, actorID string) ProjectEvent {
+	return ProjectEvent{
+		ProjectID:  t.ProjectID,
+		Type:       eventType,
+		ActorID:    actorID,
+		FromUserID: t.FromUserID,
+		ToUserID:   t.ToUserID,
+	}
+}
+
+type ProjectEventType string
+
+const (
+	TransferRequestedEvent ProjectEventType = "TRANSFER_REQUESTED"
+	TransferDeclinedEvent  ProjectEventType = "TRANSFER_DECLINED"
+	TransferCancelledEvent ProjectEventType = "TRANSFER_CANCELLED"
+	OwnerChangedEvent      ProjectEventType = "OWNER_CHANGED"
+)
+
+// ProjectEvent is an entry in a project's history. ActorID is the user who made
+// the change, and FromUserID and ToUserID the users it moved the project between.
+type ProjectEvent struct {
+	ID         uint             `json:"id" gorm:"primarykey"`
+	ProjectID  uint             `json:"project_id" gorm:"index"`
+	Type       ProjectEventType `json:"type"`
+	ActorID    string           `json:"actor_id"`
+	FromUserID string           `json:"from_user_id,omitempty"`
+	ToUserID   string           `json:"to_user_id,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
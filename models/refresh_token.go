@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is the server side record of a refresh token. Only the hash of
+// the token is stored. Every refresh token issued from the same login shares a
+// FamilyID, so the whole chain can be revoked if a used token is presented again.
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    string    `gorm:"index"`
+	FamilyID  uuid.UUID `gorm:"type:uuid;index"`
+	TokenHash string    `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
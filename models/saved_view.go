@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// TaskGrouping is how the tasks in a saved view are grouped.
+type TaskGrouping string
+
+const (
+	NoGrouping      TaskGrouping = ""
+	GroupByStatus   TaskGrouping = "status"
+	GroupByAssignee TaskGrouping = "assignee"
+	GroupByPriority TaskGrouping = "priority"
+	GroupByProject  TaskGrouping = "project"
+	GroupByLabel    TaskGrouping = "label"
+)
+
+// SavedView is a task query kept to be run again. Query and Sort are in the taskquery
+// language, and "me" in the query is whoever runs the view. Views belong to the user who
+// made them, and are shared with the members of ProjectID if it's set.
+type SavedView struct {
+	ID        uint         `json:"id" gorm:"primarykey"`
+	OwnerID   string       `json:"owner_id" gorm:"index"`
+	ProjectID *uint        `json:"project_id" gorm:"index"`
+	Name      string       `json:"name"`
+	Query     string       `json:"query"`
+	Sort      string       `json:"sort"`
+	GroupBy   TaskGrouping `json:"group_by"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
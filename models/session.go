@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a single login of a user on a device. Its ID is carried in the
+// "sid" claim of every access token issued for the login, and is also the
+// family ID of the login's refresh tokens.
+type Session struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primarykey"`
+	UserID     string     `json:"user_id" gorm:"index"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// RevokedToken records an access token, by its jti claim, that mustn't be
+// accepted again even though its signature is still valid.
+type RevokedToken struct {
+	JTI       string    `gorm:"primarykey"`
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -6,6 +6,17 @@ import (
 	"gorm.io/gorm"
 )
 
+// Priority is how soon a task needs doing, P0 being the most pressing.
+type Priority string
+
+const (
+	CriticalPriority Priority = "P0"
+	HighPriority     Priority = "P1"
+	NormalPriority   Priority = "P2"
+	LowPriority      Priority = "P3"
+)
+
+// Task is a piece of work in a project.
 type Task struct {
 	ID          uint           `json:"id" gorm:"primarykey"`
 	Title       string         `json:"title"`
@@ -19,4 +30,24 @@ type Task struct {
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// StatusID is the task's status in its project. Done is kept in sync with whether
+	// the status is in the closed category, for clients that predate statuses
+	StatusID *uint `json:"status_id" gorm:"index"`
+	// Rank orders the task within its status, see the rank package
+	Rank string `json:"rank" gorm:"index"`
+	// ParentID makes the task a subtask of that task, which is always in the same project
+	ParentID *uint `json:"parent_id" gorm:"index"`
+	// SeriesID is the TaskSeries a recurring task is an instance of
+	SeriesID *uint `json:"series_id" gorm:"index"`
+	// RecurrenceOverride replaces the series' rule when working out the instance after
+	// this one only
+	RecurrenceOverride *string `json:"recurrence_override"`
+	// Labels are always from the task's project
+	Labels []Label `json:"labels" gorm:"many2many:task_labels"`
+	// Priority, Urgent and Important place the task in the triage view. Urgent and
+	// Important are worked out from its deadline and priority when they aren't set
+	Priority  Priority `json:"priority" gorm:"default:P2;index"`
+	Urgent    *bool    `json:"urgent"`
+	Important *bool    `json:"important"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskComment is a message on a task. Replies have the comment they answer as their
+// ParentID. Mentions are the IDs of the project members mentioned in the body.
+type TaskComment struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	TaskID    uint           `json:"task_id" gorm:"index"`
+	ParentID  *uint          `json:"parent_id" gorm:"index"`
+	AuthorID  string         `json:"author_id"`
+	Body      string         `json:"body"`
+	Mentions  []string       `json:"mentions" gorm:"serializer:json"`
+	EditedAt  *time.Time     `json:"edited_at"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// TaskCommentRevision is what a comment said before one of its edits.
+type TaskCommentRevision struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CommentID uint      `json:"comment_id" gorm:"index"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
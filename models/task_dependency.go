@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// TaskDependency records that a task can't be finished until the BlockedBy task is done.
+// The tasks can be in different projects.
+type TaskDependency struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	TaskID      uint      `json:"task_id" gorm:"uniqueIndex:idx_task_dependency"`
+	BlockedByID uint      `json:"blocked_by_id" gorm:"uniqueIndex:idx_task_dependency;index"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
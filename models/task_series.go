@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// RecurrenceTrigger is what creates the next instance of a recurring task.
+type RecurrenceTrigger string
+
+const (
+	// CompletionTrigger creates the next instance when the latest one is done
+	CompletionTrigger RecurrenceTrigger = "completion"
+	// ScheduleTrigger creates the next instance once the latest one's deadline has passed
+	ScheduleTrigger RecurrenceTrigger = "schedule"
+)
+
+// TaskSeries is the recurrence rule shared by the instances of a recurring task, which
+// are tasks with its ID. Rule is an RFC 5545 RRULE, expanded from Start in TimeZone so
+// deadlines keep their local time. A series with EndedAt set creates no more instances.
+type TaskSeries struct {
+	ID        uint              `json:"id" gorm:"primarykey"`
+	ProjectID uint              `json:"project_id" gorm:"index"`
+	Rule      string            `json:"rule"`
+	TimeZone  string            `json:"time_zone"`
+	Start     time.Time         `json:"start"`
+	Trigger   RecurrenceTrigger `json:"trigger"`
+	CreatedBy string            `json:"created_by"`
+	EndedAt   *time.Time        `json:"ended_at"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// StatusCategory groups task statuses by how far along the work is.
+// Tasks in a closed status count as done.
+type StatusCategory string
+
+const (
+	OpenCategory   StatusCategory = "open"
+	ActiveCategory StatusCategory = "active"
+	ClosedCategory StatusCategory = "closed"
+)
+
+// TaskStatus is one of the stages a project's tasks move through, in Position order.
+// It's shown as a column on the project's board, and if WIPLimit is set, no more than
+// that many tasks can be in it at once.
+type TaskStatus struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	ProjectID uint           `json:"project_id" gorm:"index"`
+	Name      string         `json:"name"`
+	Position  int            `json:"position"`
+	Category  StatusCategory `json:"category"`
+	WIPLimit  *int           `json:"wip_limit"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// TaskStatusTransition allows tasks to move from one status to another. If a project
+// has no transitions from a status, tasks can move from it to any other status.
+type TaskStatusTransition struct {
+	ID           uint `json:"id" gorm:"primarykey"`
+	ProjectID    uint `json:"project_id" gorm:"index"`
+	FromStatusID uint `json:"from_status_id"`
+	ToStatusID   uint `json:"to_status_id"`
+}
+
+// DefaultTaskStatuses returns the statuses every new project starts with.
+func DefaultTaskStatuses(projectID uint) []TaskStatus {
+	return []TaskStatus{
+		{ProjectID: projectID, Name: "Backlog", Position: 0, Category: OpenCategory},
+		{ProjectID: projectID, Name: "To do", Position: 1, Category: OpenCategory},
+		{ProjectID: projectID, Name: "In progress", Position: 2, Category: ActiveCategory},
+		{ProjectID: projectID, Name: "Review", Position: 3, Category: ActiveCategory},
+		{ProjectID: projectID, Name: "Done", Position: 4, Category: ClosedCategory},
+	}
+}
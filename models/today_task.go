@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// TodayTask is a task a user picked to work on during Day, a date like 2023-03-06 in
+// their time zone. Picks from earlier days don't count, so the list starts empty each day.
+type TodayTask struct {
+	UserID    string    `json:"user_id" gorm:"primarykey"`
+	TaskID    uint      `json:"task_id" gorm:"primarykey"`
+	Day       string    `json:"day" gorm:"index"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
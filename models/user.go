@@ -6,11 +6,14 @@ import (
 	"gorm.io/gorm"
 )
 
+// User is someone who can log in. TimeZone is an IANA time zone name, with an empty one
+// meaning UTC.
 type User struct {
 	ID          string         `json:"id" gorm:"primarykey"`
 	DisplayName string         `json:"display_name"`
 	Email       string         `json:"email"`
 	ProfilePic  string         `json:"profile_pic"`
+	TimeZone    string         `json:"time_zone"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index" `
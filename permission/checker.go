@@ -0,0 +1,177 @@
+package permission
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/token"
+)
+
+var (
+	DefaultCacheTTL = 30 * time.Second
+)
+
+// ProjectMembership is the part of the project repository the Checker uses.
+type ProjectMembership interface {
+	// GetProjectRole returns the user's role in the project, or an empty role if they aren't a member.
+	GetProjectRole(userID string, projectID uint) (models.ProjectRole, error)
+}
+
+// DashboardMembership is the part of the dashboard repository the Checker uses.
+type DashboardMembership interface {
+	IsDashboardMember(userID string, dashboardID uuid.UUID) (bool, error)
+}
+
+// Checker decides whether the user behind an access token can access a project
+// or dashboard, based on their current membership rather than the claims the
+// token was issued with.
+type Checker interface {
+	HasProjectPermission(accessToken *token.ToDanniToken, projectID uint) bool
+	// ProjectRole returns the user's role in the project, or an empty role if they can't access it.
+	ProjectRole(accessToken *token.ToDanniToken, projectID uint) models.ProjectRole
+	// Can returns whether the user's role in the project allows the action.
+	Can(accessToken *token.ToDanniToken, projectID uint, action Action) bool
+	HasDashboardPermission(accessToken *token.ToDanniToken, dashboardID uuid.UUID) bool
+
+	// Invalidate drops any cached answers for the user, and should be called
+	// whenever their memberships change.
+	Invalidate(userID string)
+}
+
+type Options struct {
+	// CacheTTL is how long a membership lookup is reused for.
+	CacheTTL time.Duration
+
+	// TrustTokenClaims allows access straight away if the token's project or
+	// dashboard claims include the resource, skipping the database. Claims don't
+	// carry roles, so they only grant read access to projects.
+	// Removed members keep access until their token expires when this is set.
+	TrustTokenClaims bool
+}
+
+type cacheEntry struct {
+	role    models.ProjectRole
+	expires time.Time
+}
+
+type checker struct {
+	projects   ProjectMembership
+	dashboards DashboardMembership
+	options    Options
+	now        func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]map[string]cacheEntry
+}
+
+func NewChecker(projects ProjectMembership, dashboards DashboardMembership, options Options) Checker {
+	if options.CacheTTL <= 0 {
+		options.CacheTTL = DefaultCacheTTL
+	}
+
+	return &checker{
+		projects:   projects,
+		dashboards: dashboards,
+		options:    options,
+		now:        time.Now,
+		cache:      make(map[string]map[string]cacheEntry),
+	}
+}
+
+func (c *checker) HasProjectPermission(accessToken *token.ToDanniToken, projectID uint) bool {
+	return c.Can(accessToken, projectID, ViewProject)
+}
+
+func (c *checker) Can(accessToken *token.ToDanniToken, projectID uint, action Action) bool {
+	if action == ViewProject && c.options.TrustTokenClaims &&
+		accessToken.GetUserID() != "" && accessToken.AllowsProject(projectID) &&
+		accessToken.HasProjectPermission(projectID) {
+		return true
+	}
+
+	return RoleAllows(c.ProjectRole(accessToken, projectID), action)
+}
+
+func (c *checker) ProjectRole(accessToken *token.ToDanniToken, projectID uint) models.ProjectRole {
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		return ""
+	}
+
+	// Personal access tokens can be limited to some of the user's projects
+	if !accessToken.AllowsProject(projectID) {
+		return ""
+	}
+
+	return c.lookup(userID, fmt.Sprintf("project:%d", projectID), func() (models.ProjectRole, error) {
+		return c.projects.GetProjectRole(userID, projectID)
+	})
+}
+
+func (c *checker) HasDashboardPermission(accessToken *token.ToDanniToken, dashboardID uuid.UUID) bool {
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		return false
+	}
+
+	// Dashboards span projects, so tokens limited to specific projects can't use them
+	if accessToken.IsProjectRestricted() {
+		return false
+	}
+
+	if c.options.TrustTokenClaims && accessToken.HasDashboardPermission(dashboardID) {
+		return true
+	}
+
+	// Dashboards have no roles, so members are cached with the member role
+	role := c.lookup(userID, "dashboard:"+dashboardID.String(), func() (models.ProjectRole, error) {
+		isMember, err := c.dashboards.IsDashboardMember(userID, dashboardID)
+		if err != nil || !isMember {
+			return "", err
+		}
+		return models.MemberRole, nil
+	})
+	return role != ""
+}
+
+func (c *checker) Invalidate(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cache, userID)
+}
+
+// lookup returns the cached role for the user and key if there's one that
+// hasn't expired, otherwise it asks the database and caches the result.
+// An empty role means no access. Errors are treated as no access and aren't cached.
+func (c *checker) lookup(userID, key string, getRole func() (models.ProjectRole, error)) models.ProjectRole {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.cache[userID][key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.role
+	}
+
+	role, err := getRole()
+	if err != nil {
+		log.Errorf("couldn't check membership of %s for user %s: %v", key, userID, err)
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cache[userID] == nil {
+		c.cache[userID] = make(map[string]cacheEntry)
+	}
+	c.cache[userID][key] = cacheEntry{
+		role:    role,
+		expires: now.Add(c.options.CacheTTL),
+	}
+	return role
+}
@@ -0,0 +1,135 @@
+package permission
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/token"
+)
+
+type fakeMembership struct {
+	projects   map[uint]models.ProjectRole
+	dashboards map[uuid.UUID]bool
+	lookups    int
+}
+
+func (f *fakeMembership) GetProjectRole(userID string, projectID uint) (models.ProjectRole, error) {
+	f.lookups++
+	return f.projects[projectID], nil
+}
+
+func (f *fakeMembership) IsDashboardMember(userID string, dashboardID uuid.UUID) (bool, error) {
+	f.lookups++
+	return f.dashboards[dashboardID], nil
+}
+
+func newTestToken(projects []models.Project) *token.ToDanniToken {
+	accessToken := token.NewAccessToken()
+	accessToken.SetUserID("user1234")
+	accessToken.SetProjectsPermissions(projects)
+	accessToken.SetDashboardPermissions(make([]models.Dashboard, 0))
+	return accessToken
+}
+
+func TestChecker_LooksUpMembershipNotClaims(t *testing.T) {
+	repo := &fakeMembership{projects: map[uint]models.ProjectRole{1: models.MemberRole}}
+	c := NewChecker(repo, repo, Options{})
+
+	// The token was issued before the user joined project 1
+	accessToken := newTestToken(make([]models.Project, 0))
+
+	require.True(t, c.HasProjectPermission(accessToken, 1))
+	require.False(t, c.HasProjectPermission(accessToken, 2))
+}
+
+func TestChecker_CachesUntilExpiryOrInvalidate(t *testing.T) {
+	repo := &fakeMembership{projects: map[uint]models.ProjectRole{1: models.MemberRole}}
+	c := NewChecker(repo, repo, Options{CacheTTL: time.Minute}).(*checker)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	accessToken := newTestToken(make([]models.Project, 0))
+
+	require.True(t, c.HasProjectPermission(accessToken, 1))
+	require.True(t, c.HasProjectPermission(accessToken, 1))
+	require.Equal(t, 1, repo.lookups)
+
+	// The user is removed from the project and the cache is told about it
+	delete(repo.projects, 1)
+	c.Invalidate("user1234")
+	require.False(t, c.HasProjectPermission(accessToken, 1))
+	require.Equal(t, 2, repo.lookups)
+
+	// The user is added back without an invalidation, which is picked up once the entry expires
+	repo.projects[1] = models.MemberRole
+	require.False(t, c.HasProjectPermission(accessToken, 1))
+	now = now.Add(2 * time.Minute)
+	require.True(t, c.HasProjectPermission(accessToken, 1))
+	require.Equal(t, 3, repo.lookups)
+}
+
+func TestChecker_TrustTokenClaims(t *testing.T) {
+	repo := &fakeMembership{}
+	accessToken := newTestToken([]models.Project{{Model: gorm.Model{ID: 1}}})
+
+	trusting := NewChecker(repo, repo, Options{TrustTokenClaims: true})
+	require.True(t, trusting.HasProjectPermission(accessToken, 1))
+	require.Equal(t, 0, repo.lookups)
+
+	strict := NewChecker(repo, repo, Options{})
+	require.False(t, strict.HasProjectPermission(accessToken, 1))
+	require.Equal(t, 1, repo.lookups)
+}
+
+func TestChecker_Dashboards(t *testing.T) {
+	dashboardID := uuid.New()
+	repo := &fakeMembership{dashboards: map[uuid.UUID]bool{dashboardID: true}}
+	c := NewChecker(repo, repo, Options{})
+	accessToken := newTestToken(make([]models.Project, 0))
+
+	require.True(t, c.HasDashboardPermission(accessToken, dashboardID))
+	require.False(t, c.HasDashboardPermission(accessToken, uuid.New()))
+}
+
+func TestChecker_PersonalAccessTokenProjectRestriction(t *testing.T) {
+	repo := &fakeMembership{
+		projects:   map[uint]models.ProjectRole{1: models.MemberRole, 2: models.MemberRole},
+		dashboards: map[uuid.UUID]bool{},
+	}
+	c := NewChecker(repo, repo, Options{})
+
+	accessToken := token.FromPersonalAccessToken(models.PersonalAccessToken{
+		ID:         1,
+		UserID:     "user1234",
+		Scope:      models.ReadWriteScope,
+		ProjectIDs: []uint{1},
+	})
+
+	require.True(t, c.HasProjectPermission(accessToken, 1))
+	require.False(t, c.HasProjectPermission(accessToken, 2))
+
+	dashboardID := uuid.New()
+	repo.dashboards[dashboardID] = true
+	require.False(t, c.HasDashboardPermission(accessToken, dashboardID))
+}
+
+func TestChecker_Roles(t *testing.T) {
+	repo := &fakeMembership{projects: map[uint]models.ProjectRole{1: models.ViewerRole, 2: models.AdminRole}}
+	c := NewChecker(repo, repo, Options{})
+	accessToken := newTestToken(make([]models.Project, 0))
+
+	require.Equal(t, models.ViewerRole, c.ProjectRole(accessToken, 1))
+	require.True(t, c.HasProjectPermission(accessToken, 1))
+	require.False(t, c.Can(accessToken, 1, EditTask))
+
+	require.True(t, c.Can(accessToken, 2, ManageMembers))
+	require.False(t, c.Can(accessToken, 2, DeleteProject))
+
+	require.Equal(t, models.ProjectRole(""), c.ProjectRole(accessToken, 3))
+	require.False(t, c.Can(accessToken, 3, ViewProject))
+}
@@ -0,0 +1,72 @@
+package permission
+
+import (
+	"github.com/todanni/api/models"
+)
+
+// Action is something a project member may be allowed to do, depending on their role.
+type Action string
+
+const (
+	ViewProject     Action = "project:view"
+	EditProject     Action = "project:edit"
+	DeleteProject   Action = "project:delete"
+	TransferProject Action = "project:transfer"
+	ManageMembers   Action = "project:manage-members"
+	ManageAdmins    Action = "project:manage-admins"
+
+	CreateTask Action = "task:create"
+	EditTask   Action = "task:edit"
+	// DeleteTask allows deleting tasks the member created, DeleteAnyTask anyone's
+	DeleteTask    Action = "task:delete"
+	DeleteAnyTask Action = "task:delete-any"
+
+	// Everyone can edit and delete their own comments, DeleteAnyComment allows deleting anyone's
+	CommentOnTask    Action = "comment:create"
+	DeleteAnyComment Action = "comment:delete-any"
+
+	// ShareView allows sharing saved views with the project. Everyone can edit and delete
+	// their own views, and members who can edit the project anyone's.
+	ShareView Action = "view:share"
+)
+
+// roleActions is the permission matrix. Each role can do everything the role
+// below it can, and more.
+var roleActions = map[models.ProjectRole][]Action{
+	models.ViewerRole: {ViewProject},
+	models.MemberRole: {ViewProject, CreateTask, EditTask, DeleteTask, CommentOnTask, ShareView},
+	models.AdminRole: {ViewProject, CreateTask, EditTask, DeleteTask, DeleteAnyTask,
+		CommentOnTask, DeleteAnyComment, ShareView, EditProject, ManageMembers},
+	models.OwnerRole: {ViewProject, CreateTask, EditTask, DeleteTask, DeleteAnyTask,
+		CommentOnTask, DeleteAnyComment, ShareView, EditProject, ManageMembers, ManageAdmins, DeleteProject, TransferProject},
+}
+
+// RoleAllows returns whether a member with the role can perform the action.
+func RoleAllows(role models.ProjectRole, action Action) bool {
+	for _, allowed := range roleActions[role] {
+		if allowed == action {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidRole returns whether the role is one of the known project roles.
+func IsValidRole(role models.ProjectRole) bool {
+	_, ok := roleActions[role]
+	return ok
+}
+
+// CanAssignRole returns whether a member with the role can give another member the
+// target role, or change the role of a member who has it. The owner role is only
+// handed over by transferring the project, and only the owner manages admins.
+func CanAssignRole(role models.ProjectRole, target models.ProjectRole) bool {
+	switch target {
+	case models.OwnerRole:
+		return false
+	case models.AdminRole:
+		return RoleAllows(role, ManageAdmins)
+	default:
+		return RoleAllows(role, ManageMembers)
+	}
+}
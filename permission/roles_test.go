@@ -0,0 +1,43 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestRoleAllows(t *testing.T) {
+	require.True(t, RoleAllows(models.ViewerRole, ViewProject))
+	require.False(t, RoleAllows(models.ViewerRole, EditTask))
+
+	require.False(t, RoleAllows(models.ViewerRole, CommentOnTask))
+	require.False(t, RoleAllows(models.ViewerRole, ShareView))
+
+	require.True(t, RoleAllows(models.MemberRole, EditTask))
+	require.True(t, RoleAllows(models.MemberRole, CommentOnTask))
+	require.True(t, RoleAllows(models.MemberRole, ShareView))
+	require.False(t, RoleAllows(models.MemberRole, DeleteAnyComment))
+	require.False(t, RoleAllows(models.MemberRole, ManageMembers))
+
+	require.True(t, RoleAllows(models.AdminRole, ManageMembers))
+	require.True(t, RoleAllows(models.AdminRole, DeleteAnyComment))
+	require.False(t, RoleAllows(models.AdminRole, DeleteProject))
+	require.False(t, RoleAllows(models.AdminRole, TransferProject))
+
+	require.True(t, RoleAllows(models.OwnerRole, DeleteProject))
+	require.True(t, RoleAllows(models.OwnerRole, TransferProject))
+
+	require.False(t, RoleAllows("", ViewProject))
+}
+
+func TestCanAssignRole(t *testing.T) {
+	require.True(t, CanAssignRole(models.AdminRole, models.ViewerRole))
+	require.True(t, CanAssignRole(models.AdminRole, models.MemberRole))
+	require.False(t, CanAssignRole(models.AdminRole, models.AdminRole))
+	require.False(t, CanAssignRole(models.MemberRole, models.ViewerRole))
+
+	require.True(t, CanAssignRole(models.OwnerRole, models.AdminRole))
+	require.False(t, CanAssignRole(models.OwnerRole, models.OwnerRole))
+}
@@ -0,0 +1,138 @@
+// Package rank generates lexicographically ordered strings for keeping items in a
+// user defined order. A new rank can always be made between two others, so moving
+// an item only changes that item's rank rather than renumbering its neighbours.
+//
+// Ranks are base 36 fractions written without the leading "0.", using the digits
+// 0-9 and a-z, and never end in 0 so there's always room before them.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	base   = len(digits)
+)
+
+var (
+	ErrInvalidRank  = errors.New("rank must only contain 0-9 and a-z and not end in 0")
+	ErrInvalidRange = errors.New("first rank must sort before the second")
+)
+
+// Initial returns the rank for the first item in an empty list.
+func Initial() string {
+	return string(digits[base/2])
+}
+
+// Between returns a rank that sorts after a and before b. An empty a means the start
+// of the list, and an empty b the end of it.
+func Between(a, b string) (string, error) {
+	if !valid(a) || !valid(b) {
+		return "", ErrInvalidRank
+	}
+	if b != "" && a >= b {
+		return "", ErrInvalidRange
+	}
+	return midpoint(a, b), nil
+}
+
+// After returns a rank that sorts after a.
+func After(a string) (string, error) {
+	return Between(a, "")
+}
+
+// Before returns a rank that sorts before b.
+func Before(b string) (string, error) {
+	return Between("", b)
+}
+
+// Spread returns n ranks in order, spaced evenly so there's room between each of them.
+func Spread(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	// Use the fewest digits that leave a gap between every rank
+	width, size := 1, base
+	for size <= n {
+		width++
+		size *= base
+	}
+
+	step := size / (n + 1)
+	ranks := make([]string, n)
+	for i := range ranks {
+		ranks[i] = format(step*(i+1), width)
+	}
+	return ranks
+}
+
+// midpoint returns the rank halfway between a and b, where b is empty for the end of the list.
+func midpoint(a, b string) string {
+	// Keep any prefix the two have in common, treating missing digits of a as 0
+	if b != "" {
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(suffix(a, n), b[n:])
+		}
+	}
+
+	// The first digits differ, so pick one between them if there's room
+	da := value(digitAt(a, 0))
+	db := base
+	if b != "" {
+		db = value(b[0])
+	}
+	if db-da > 1 {
+		return string(digits[(da+db)/2])
+	}
+
+	// The first digits are consecutive. b's first digit alone works if b has more digits,
+	// as they can't all be 0. Otherwise keep a's first digit and go halfway to the end after it.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(digits[da]) + midpoint(suffix(a, 1), "")
+}
+
+func valid(rank string) bool {
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(digits, rank[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(rank, "0")
+}
+
+func digitAt(rank string, i int) byte {
+	if i < len(rank) {
+		return rank[i]
+	}
+	return digits[0]
+}
+
+func suffix(rank string, i int) string {
+	if i < len(rank) {
+		return rank[i:]
+	}
+	return ""
+}
+
+func value(digit byte) int {
+	return strings.IndexByte(digits, digit)
+}
+
+// format writes v in base 36 using width digits, without trailing zeros.
+func format(v, width int) string {
+	out := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		out[i] = digits[v%base]
+		v /= base
+	}
+	return strings.TrimRight(string(out), "0")
+}
@@ -0,0 +1,93 @@
+package rank
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"", ""},
+		{"", "i"},
+		{"i", ""},
+		{"a", "c"},
+		{"a", "b"},
+		{"a", "a1"},
+		{"az", "b"},
+		{"zz", ""},
+		{"", "01"},
+		{"a1", "a2"},
+	}
+
+	for _, test := range tests {
+		between, err := Between(test.a, test.b)
+		require.NoError(t, err)
+		require.Greater(t, between, test.a, "between %q and %q", test.a, test.b)
+		if test.b != "" {
+			require.Less(t, between, test.b, "between %q and %q", test.a, test.b)
+		}
+		require.True(t, valid(between), between)
+	}
+}
+
+func TestBetween_Invalid(t *testing.T) {
+	_, err := Between("b", "a")
+	require.ErrorIs(t, err, ErrInvalidRange)
+
+	_, err = Between("a", "a")
+	require.ErrorIs(t, err, ErrInvalidRange)
+
+	_, err = Between("a0", "")
+	require.ErrorIs(t, err, ErrInvalidRank)
+
+	_, err = Between("A", "")
+	require.ErrorIs(t, err, ErrInvalidRank)
+}
+
+func TestBetween_RepeatedInserts(t *testing.T) {
+	ranks := []string{Initial()}
+	random := rand.New(rand.NewSource(1))
+
+	// Insert in random places, including always at the same spot
+	for i := 0; i < 1000; i++ {
+		position := random.Intn(len(ranks) + 1)
+		if i%2 == 0 {
+			position = 0
+		}
+
+		before, after := "", ""
+		if position > 0 {
+			before = ranks[position-1]
+		}
+		if position < len(ranks) {
+			after = ranks[position]
+		}
+
+		rank, err := Between(before, after)
+		require.NoError(t, err)
+
+		ranks = append(ranks[:position], append([]string{rank}, ranks[position:]...)...)
+		require.True(t, sort.StringsAreSorted(ranks))
+	}
+}
+
+func TestSpread(t *testing.T) {
+	require.Nil(t, Spread(0))
+
+	for _, n := range []int{1, 35, 36, 100, 5000} {
+		ranks := Spread(n)
+		require.Len(t, ranks, n)
+		require.True(t, sort.StringsAreSorted(ranks))
+		for i, rank := range ranks {
+			require.True(t, valid(rank), rank)
+			if i > 0 {
+				require.NotEqual(t, ranks[i-1], rank)
+			}
+		}
+	}
+}
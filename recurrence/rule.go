@@ -0,0 +1,453 @@
+// Package recurrence parses and expands the RFC 5545 RRULE subset used by recurring tasks.
+//
+// Rules can be DAILY, WEEKLY, MONTHLY or YEARLY with an INTERVAL, and end after a COUNT
+// or at an UNTIL time. They can be narrowed with BYDAY, including the nth weekday of a
+// month like 2TU or -1FR, BYMONTHDAY and BYMONTH. Occurrences are worked out on the
+// calendar of the start time's location, so they keep their time of day across DST changes.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// untilLayout is the UTC form of an RRULE date time.
+const untilLayout = "20060102T150405Z"
+
+// maxPeriods stops rules that can never match, like the 30th of February, from looping forever.
+const maxPeriods = 10000
+
+var (
+	ErrInvalidRule = errors.New("invalid recurrence rule")
+)
+
+// Weekday is a BYDAY entry. N picks the nth of that weekday in the month, or year for
+// yearly rules, counting from the end if it's negative. 0 means every one.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Parse reads a rule like "FREQ=WEEKLY;BYDAY=MO,WE", with or without the "RRULE:" prefix.
+func Parse(s string) (Rule, error) {
+	rule := Rule{Interval: 1, WeekStart: time.Monday}
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return Rule{}, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(value))
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parsePositive(value)
+		case "COUNT":
+			rule.Count, err = parsePositive(value)
+		case "UNTIL":
+			rule.Until, err = parseUntil(value)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseList(value, -31, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseList(value, 1, 12)
+			for _, month := range months {
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		case "WKST":
+			day, ok := weekdayNames[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("unknown weekday %q", value)
+			}
+			rule.WeekStart = day
+		default:
+			err = fmt.Errorf("unsupported part %q", name)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return Rule{}, fmt.Errorf("%w: COUNT and UNTIL can't both be set", ErrInvalidRule)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return Rule{}, fmt.Errorf("%w: numbered BYDAY is only allowed for MONTHLY and YEARLY rules", ErrInvalidRule)
+		}
+	}
+	return rule, nil
+}
+
+// String formats the rule without the "RRULE:" prefix.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			name := strings.ToUpper(day.Day.String()[:2])
+			if day.N != 0 {
+				name = strconv.Itoa(day.N) + name
+			}
+			days = append(days, name)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, 0, len(r.ByMonth))
+		for _, month := range r.ByMonth {
+			months = append(months, int(month))
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+strings.ToUpper(r.WeekStart.String()[:2]))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence after the given time, for a series starting at start.
+// The start is always the first occurrence. It returns false once the rule has ended.
+func (r Rule) Next(start time.Time, after time.Time) (time.Time, bool) {
+	occurrences := r.Between(start, after, 1)
+	if len(occurrences) == 0 {
+		return time.Time{}, false
+	}
+	return occurrences[0], true
+}
+
+// Between returns up to n occurrences after the given time, for a series starting at start.
+func (r Rule) Between(start time.Time, after time.Time, n int) []time.Time {
+	var occurrences []time.Time
+	if n < 1 {
+		return occurrences
+	}
+
+	// The start counts as the first occurrence, even if the rule wouldn't produce it
+	count := 1
+	if start.After(after) {
+		occurrences = append(occurrences, start)
+	}
+
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	for period := 0; period < maxPeriods && len(occurrences) < n; period++ {
+		candidates := r.expand(start, period*interval)
+		if len(candidates) == 0 {
+			continue
+		}
+
+		for _, candidate := range candidates {
+			if !candidate.After(start) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return occurrences
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return occurrences
+			}
+			if candidate.After(after) {
+				occurrences = append(occurrences, candidate)
+				if len(occurrences) == n {
+					break
+				}
+			}
+		}
+	}
+	return occurrences
+}
+
+// expand returns the days matching the rule in the period that's offset periods after
+// the start's, in order, at the start's time of day.
+func (r Rule) expand(start time.Time, offset int) []time.Time {
+	loc := start.Location()
+	year, month, day := start.Date()
+	hour, min, sec := start.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, min, sec, start.Nanosecond(), loc)
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		date := at(year, month, day+offset)
+		if r.matchesMonth(date.Month()) && r.matchesMonthDay(date) && r.matchesWeekday(date.Weekday()) {
+			days = append(days, date)
+		}
+
+	case Weekly:
+		// Weeks begin on WeekStart. Without BYDAY, the rule repeats on the start's weekday
+		shift := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := at(year, month, day-shift+7*offset)
+		for i := 0; i < 7; i++ {
+			date := at(weekStart.Year(), weekStart.Month(), weekStart.Day()+i)
+			matches := r.matchesWeekday(date.Weekday())
+			if len(r.ByDay) == 0 {
+				matches = date.Weekday() == start.Weekday()
+			}
+			if matches && r.matchesMonth(date.Month()) {
+				days = append(days, date)
+			}
+		}
+
+	case Monthly:
+		first := at(year, month+time.Month(offset), 1)
+		if r.matchesMonth(first.Month()) {
+			days = r.expandMonth(first, day, at)
+		}
+
+	case Yearly:
+		targetYear := year + offset
+		if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) > 0 {
+			days = r.expandYearWeekdays(targetYear, at)
+			break
+		}
+
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{month}
+		}
+		for _, m := range months {
+			days = append(days, r.expandMonth(at(targetYear, m, 1), day, at)...)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// expandMonth returns the matching days of the month starting at first. Without BYDAY or
+// BYMONTHDAY, that's the start's day of the month, skipping months too short to have it.
+func (r Rule) expandMonth(first time.Time, startDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	year, month := first.Year(), first.Month()
+	length := daysIn(year, month)
+
+	var days []time.Time
+	switch {
+	case len(r.ByDay) > 0:
+		for d := 1; d <= length; d++ {
+			date := at(year, month, d)
+			if r.matchesNthWeekday(date, d, length) && r.matchesMonthDay(date) {
+				days = append(days, date)
+			}
+		}
+	case len(r.ByMonthDay) > 0:
+		for d := 1; d <= length; d++ {
+			date := at(year, month, d)
+			if r.matchesMonthDay(date) {
+				days = append(days, date)
+			}
+		}
+	case startDay <= length:
+		days = append(days, at(year, month, startDay))
+	}
+	return days
+}
+
+// expandYearWeekdays returns the days of the year matching BYDAY, where numbered weekdays
+// count through the whole year.
+func (r Rule) expandYearWeekdays(year int, at func(int, time.Month, int) time.Time) []time.Time {
+	length := 365
+	if daysIn(year, time.February) == 29 {
+		length = 366
+	}
+
+	var days []time.Time
+	for d := 1; d <= length; d++ {
+		date := at(year, time.January, d)
+		if r.matchesNthWeekday(date, d, length) {
+			days = append(days, date)
+		}
+	}
+	return days
+}
+
+func (r Rule) matchesMonth(month time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesMonthDay(date time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := daysIn(date.Year(), date.Month())
+	for _, d := range r.ByMonthDay {
+		if d == date.Day() || (d < 0 && length+d+1 == date.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesWeekday(day time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNthWeekday checks the date against BYDAY, where index is the date's 1 based
+// position within a period of the given length.
+func (r Rule) matchesNthWeekday(date time.Time, index int, length int) bool {
+	for _, d := range r.ByDay {
+		if d.Day != date.Weekday() {
+			continue
+		}
+		nth := (index-1)/7 + 1
+		fromEnd := -((length-index)/7 + 1)
+		if d.N == 0 || d.N == nth || d.N == fromEnd {
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func parsePositive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%q must be a positive number", value)
+	}
+	return n, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(untilLayout, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		// A date on its own includes the whole day
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseByDay(value string) ([]Weekday, error) {
+	var days []Weekday
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToUpper(strings.TrimSpace(entry))
+		if len(entry) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", entry)
+		}
+
+		day, ok := weekdayNames[entry[len(entry)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", entry)
+		}
+
+		n := 0
+		if prefix := entry[:len(entry)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid BYDAY %q", entry)
+			}
+		}
+		days = append(days, Weekday{Day: day, N: n})
+	}
+	return days, nil
+}
+
+func parseList(value string, min int, max int) ([]int, error) {
+	var values []int
+	for _, entry := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(entry))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("%q must be between %d and %d", entry, min, max)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,203 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, s string) Rule {
+	t.Helper()
+	rule, err := Parse(s)
+	require.NoError(t, err)
+	return rule
+}
+
+func dates(t *testing.T, layout string, values ...string) []time.Time {
+	t.Helper()
+	var times []time.Time
+	for _, value := range values {
+		parsed, err := time.Parse(layout, value)
+		require.NoError(t, err)
+		times = append(times, parsed)
+	}
+	return times
+}
+
+func TestParse(t *testing.T) {
+	rule := mustParse(t, "RRULE:FREQ=MONTHLY;INTERVAL=2;BYDAY=2TU,-1FR;COUNT=5")
+	require.Equal(t, Monthly, rule.Freq)
+	require.Equal(t, 2, rule.Interval)
+	require.Equal(t, 5, rule.Count)
+	require.Equal(t, []Weekday{{Day: time.Tuesday, N: 2}, {Day: time.Friday, N: -1}}, rule.ByDay)
+
+	// Formatting gives back an equivalent rule
+	require.Equal(t, "FREQ=MONTHLY;INTERVAL=2;COUNT=5;BYDAY=2TU,-1FR", rule.String())
+	require.Equal(t, rule, mustParse(t, rule.String()))
+
+	rule = mustParse(t, "FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=-1;UNTIL=20301231T000000Z;WKST=SU")
+	require.Equal(t, []time.Month{time.March, time.September}, rule.ByMonth)
+	require.Equal(t, time.Sunday, rule.WeekStart)
+	require.Equal(t, rule, mustParse(t, rule.String()))
+
+	for _, invalid := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20300101",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;BYSETPOS=1",
+		"FREQ",
+	} {
+		_, err := Parse(invalid)
+		require.ErrorIs(t, err, ErrInvalidRule, invalid)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	const layout = "2006-01-02 15:04"
+	start := func(value string) time.Time { return dates(t, layout, value)[0] }
+
+	tests := []struct {
+		rule     string
+		start    string
+		after    string
+		n        int
+		expected []string
+	}{
+		{
+			rule:     "FREQ=DAILY;INTERVAL=3",
+			start:    "2023-01-30 09:00",
+			after:    "2023-01-30 09:00",
+			n:        3,
+			expected: []string{"2023-02-02 09:00", "2023-02-05 09:00", "2023-02-08 09:00"},
+		},
+		{
+			// Weekly on given days, starting midweek
+			rule:     "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			start:    "2023-03-08 10:00",
+			after:    "2023-03-01 00:00",
+			n:        4,
+			expected: []string{"2023-03-08 10:00", "2023-03-10 10:00", "2023-03-13 10:00", "2023-03-15 10:00"},
+		},
+		{
+			rule:     "FREQ=WEEKLY;INTERVAL=2",
+			start:    "2023-03-07 08:30",
+			after:    "2023-03-07 08:30",
+			n:        2,
+			expected: []string{"2023-03-21 08:30", "2023-04-04 08:30"},
+		},
+		{
+			// Second Tuesday of the month
+			rule:     "FREQ=MONTHLY;BYDAY=2TU",
+			start:    "2023-01-10 12:00",
+			after:    "2023-01-10 12:00",
+			n:        3,
+			expected: []string{"2023-02-14 12:00", "2023-03-14 12:00", "2023-04-11 12:00"},
+		},
+		{
+			// Last Friday of the month
+			rule:     "FREQ=MONTHLY;BYDAY=-1FR",
+			start:    "2023-01-27 17:00",
+			after:    "2023-01-27 17:00",
+			n:        2,
+			expected: []string{"2023-02-24 17:00", "2023-03-31 17:00"},
+		},
+		{
+			// Months without a 31st are skipped
+			rule:     "FREQ=MONTHLY",
+			start:    "2023-01-31 09:00",
+			after:    "2023-01-31 09:00",
+			n:        2,
+			expected: []string{"2023-03-31 09:00", "2023-05-31 09:00"},
+		},
+		{
+			// Last day of every month
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start:    "2023-01-31 09:00",
+			after:    "2023-01-31 09:00",
+			n:        2,
+			expected: []string{"2023-02-28 09:00", "2023-03-31 09:00"},
+		},
+		{
+			// Fourth Thursday of November
+			rule:     "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			start:    "2023-11-23 15:00",
+			after:    "2023-11-23 15:00",
+			n:        2,
+			expected: []string{"2024-11-28 15:00", "2025-11-27 15:00"},
+		},
+		{
+			// Leap days only come every four years
+			rule:     "FREQ=YEARLY",
+			start:    "2024-02-29 00:00",
+			after:    "2024-02-29 00:00",
+			n:        1,
+			expected: []string{"2028-02-29 00:00"},
+		},
+		{
+			// The start counts towards COUNT
+			rule:     "FREQ=DAILY;COUNT=3",
+			start:    "2023-06-01 07:00",
+			after:    "2023-06-01 07:00",
+			n:        5,
+			expected: []string{"2023-06-02 07:00", "2023-06-03 07:00"},
+		},
+		{
+			rule:     "FREQ=WEEKLY;UNTIL=20230615T070000Z",
+			start:    "2023-06-01 07:00",
+			after:    "2023-06-01 07:00",
+			n:        5,
+			expected: []string{"2023-06-08 07:00", "2023-06-15 07:00"},
+		},
+		{
+			// A rule that never matches ends instead of looping forever
+			rule:  "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			start: "2023-01-01 00:00",
+			after: "2023-01-01 00:00",
+			n:     1,
+		},
+	}
+
+	for _, test := range tests {
+		rule := mustParse(t, test.rule)
+		got := rule.Between(start(test.start), start(test.after), test.n)
+
+		var expected []time.Time
+		if len(test.expected) > 0 {
+			expected = dates(t, layout, test.expected...)
+		}
+		require.Equal(t, expected, got, test.rule)
+	}
+}
+
+func TestNextKeepsLocalTimeAcrossDST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	// The clocks go forward on the 26th of March 2023
+	start := time.Date(2023, time.March, 25, 9, 0, 0, 0, london)
+	rule := mustParse(t, "FREQ=DAILY")
+
+	next, ok := rule.Next(start, start)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2023, time.March, 26, 9, 0, 0, 0, london), next)
+	require.Equal(t, 23*time.Hour, next.Sub(start))
+
+	// Weekdays are those of the location, not UTC
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	start = time.Date(2023, time.March, 6, 8, 0, 0, 0, tokyo)
+	rule = mustParse(t, "FREQ=WEEKLY;BYDAY=MO")
+
+	next, ok = rule.Next(start, start)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2023, time.March, 13, 8, 0, 0, 0, tokyo), next)
+
+	_, ok = mustParse(t, "FREQ=DAILY;COUNT=1").Next(start, start)
+	require.False(t, ok)
+}
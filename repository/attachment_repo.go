@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+var (
+	ErrQuotaExceeded = errors.New("the project doesn't have enough storage left for this file")
+)
+
+type AttachmentRepository interface {
+	ListAttachments(taskID uint) ([]models.Attachment, error)
+	GetAttachment(taskID uint, attachmentID uint) (models.Attachment, error)
+	CreateAttachment(attachment models.Attachment, quota int64) (models.Attachment, error)
+	DeleteAttachment(attachment models.Attachment) error
+	ProjectStorageUsed(projectID uint) (int64, error)
+}
+
+type attachmentRepo struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepo{
+		db: db,
+	}
+}
+
+// attachmentLockKey is paired with the project ID to serialise uploads to a project, so
+// two uploads at the same time can't both fit in the last of its quota.
+const attachmentLockKey = 7283042
+
+func (r *attachmentRepo) ListAttachments(taskID uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	result := r.db.
+		Where("task_id = ?", taskID).
+		Order("created_at, id").
+		Find(&attachments)
+	return attachments, result.Error
+}
+
+func (r *attachmentRepo) GetAttachment(taskID uint, attachmentID uint) (models.Attachment, error) {
+	var attachment models.Attachment
+	result := r.db.Where("task_id = ?", taskID).First(&attachment, attachmentID)
+	return attachment, result.Error
+}
+
+// CreateAttachment saves the attachment if the project's attachments stay within the
+// quota, returning ErrQuotaExceeded if they wouldn't. A quota of 0 or less is unlimited.
+func (r *attachmentRepo) CreateAttachment(attachment models.Attachment, quota int64) (models.Attachment, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if quota > 0 {
+			result := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", attachmentLockKey, attachment.ProjectID)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			used, err := projectStorageUsed(tx, attachment.ProjectID)
+			if err != nil {
+				return err
+			}
+			if used+attachment.Size > quota {
+				return ErrQuotaExceeded
+			}
+		}
+		return tx.Create(&attachment).Error
+	})
+	return attachment, err
+}
+
+func (r *attachmentRepo) DeleteAttachment(attachment models.Attachment) error {
+	return r.db.Delete(&attachment).Error
+}
+
+// ProjectStorageUsed returns the total size of the project's attachments in bytes.
+// Attachments of deleted tasks don't count.
+func (r *attachmentRepo) ProjectStorageUsed(projectID uint) (int64, error) {
+	return projectStorageUsed(r.db, projectID)
+}
+
+func projectStorageUsed(db *gorm.DB, projectID uint) (int64, error) {
+	var used int64
+	result := db.Model(&models.Attachment{}).
+		Select("COALESCE(SUM(size), 0)").
+		Where("project_id = ?", projectID).
+		Where("task_id IN (SELECT id FROM tasks WHERE deleted_at IS NULL)").
+		Scan(&used)
+	return used, result.Error
+}
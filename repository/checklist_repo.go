@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+type ChecklistRepository interface {
+	ListChecklistItems(taskIDs ...uint) ([]models.ChecklistItem, error)
+	GetChecklistItem(taskID uint, itemID uint) (models.ChecklistItem, error)
+	CreateChecklistItem(item models.ChecklistItem) (models.ChecklistItem, error)
+	UpdateChecklistItem(item models.ChecklistItem) (models.ChecklistItem, error)
+	DeleteChecklistItem(item models.ChecklistItem) error
+}
+
+type checklistRepo struct {
+	db *gorm.DB
+}
+
+func NewChecklistRepository(db *gorm.DB) ChecklistRepository {
+	return &checklistRepo{
+		db: db,
+	}
+}
+
+// ListChecklistItems returns the checklist items of the tasks, in order.
+func (r *checklistRepo) ListChecklistItems(taskIDs ...uint) ([]models.ChecklistItem, error) {
+	var items []models.ChecklistItem
+	if len(taskIDs) == 0 {
+		return items, nil
+	}
+
+	result := r.db.
+		Where("task_id IN ?", taskIDs).
+		Order("task_id, position, id").
+		Find(&items)
+	return items, result.Error
+}
+
+func (r *checklistRepo) GetChecklistItem(taskID uint, itemID uint) (models.ChecklistItem, error) {
+	var item models.ChecklistItem
+	result := r.db.Where("task_id = ?", taskID).First(&item, itemID)
+	return item, result.Error
+}
+
+func (r *checklistRepo) CreateChecklistItem(item models.ChecklistItem) (models.ChecklistItem, error) {
+	result := r.db.Create(&item)
+	return item, result.Error
+}
+
+func (r *checklistRepo) UpdateChecklistItem(item models.ChecklistItem) (models.ChecklistItem, error) {
+	result := r.db.Model(&item).
+		Select("Text", "Done", "Position").
+		Updates(item)
+	return item, result.Error
+}
+
+func (r *checklistRepo) DeleteChecklistItem(item models.ChecklistItem) error {
+	return r.db.Delete(&item).Error
+}
@@ -3,34 +3,165 @@ package repository
 import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/todanni/api/models"
 )
 
 type DashboardRepository interface {
 	CreateDashboard(dashboard models.Dashboard) (models.Dashboard, error)
+	GetDashboardByID(id uuid.UUID) (models.Dashboard, error)
+	UpdateDashboard(dashboard models.Dashboard) (models.Dashboard, error)
 	DeleteDashboard(id uuid.UUID) (models.Dashboard, error)
 	ListDashboardsByUser(userID string) ([]models.Dashboard, error)
+
+	GetDashboardMember(dashboardID uuid.UUID, userID string) (models.DashboardMember, error)
+	IsDashboardMember(userID string, dashboardID uuid.UUID) (bool, error)
+	InviteDashboardMember(dashboardID uuid.UUID, userID, invitedBy string) (models.DashboardMember, error)
+	UpdateDashboardMemberStatus(dashboardID uuid.UUID, userID string, status models.Status) error
+	RemoveDashboardMember(dashboardID uuid.UUID, userID string) error
+	ListDashboardInvitesByUser(userID string) ([]models.Dashboard, error)
 }
 
 type dashboardRepo struct {
 	db *gorm.DB
 }
 
+// CreateDashboard persists the dashboard and adds its members as accepted,
+// since they're added by the person creating it rather than invited.
 func (d dashboardRepo) CreateDashboard(dashboard models.Dashboard) (models.Dashboard, error) {
-	//TODO implement me
-	panic("implement me")
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Members").Create(&dashboard)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, member := range dashboard.Members {
+			result = tx.Create(&models.DashboardMember{
+				DashboardID: dashboard.ID,
+				UserID:      member.ID,
+				Status:      models.AcceptedStatus,
+				InvitedBy:   dashboard.Owner,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+	return dashboard, err
+}
+
+func (d dashboardRepo) GetDashboardByID(id uuid.UUID) (models.Dashboard, error) {
+	var dashboard models.Dashboard
+	result := d.db.Preload("Members").Preload("Memberships").Preload("Projects").
+		First(&dashboard, "id = ?", id)
+	return dashboard, result.Error
+}
+
+// UpdateDashboard updates the dashboard's name and, if Projects is not nil,
+// replaces the set of projects grouped on the dashboard.
+func (d dashboardRepo) UpdateDashboard(dashboard models.Dashboard) (models.Dashboard, error) {
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Dashboard{ID: dashboard.ID}).
+			Omit(clause.Associations).
+			Updates(models.Dashboard{Name: dashboard.Name})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if dashboard.Projects == nil {
+			return nil
+		}
+
+		return tx.Model(&models.Dashboard{ID: dashboard.ID}).
+			Association("Projects").
+			Replace(dashboard.Projects)
+	})
+	if err != nil {
+		return dashboard, err
+	}
+
+	return d.GetDashboardByID(dashboard.ID)
 }
 
 func (d dashboardRepo) DeleteDashboard(id uuid.UUID) (models.Dashboard, error) {
-	//TODO implement me
-	panic("implement me")
+	dashboard, err := d.GetDashboardByID(id)
+	if err != nil {
+		return dashboard, err
+	}
+
+	result := d.db.Delete(&models.Dashboard{}, "id = ?", id)
+	return dashboard, result.Error
 }
 
+// ListDashboardsByUser returns the dashboards the user has accepted membership of.
 func (d dashboardRepo) ListDashboardsByUser(userID string) ([]models.Dashboard, error) {
-	var user models.User
-	result := d.db.Model(&models.User{}).Preload("Dashboard.Members").First(&user, userID)
-	return user.Dashboards, result.Error
+	return d.listDashboardsByMemberStatus(userID, models.AcceptedStatus)
+}
+
+// ListDashboardInvitesByUser returns the dashboards the user has been invited to
+// and hasn't yet responded to.
+func (d dashboardRepo) ListDashboardInvitesByUser(userID string) ([]models.Dashboard, error) {
+	return d.listDashboardsByMemberStatus(userID, models.PendingStatus)
+}
+
+func (d dashboardRepo) listDashboardsByMemberStatus(userID string, status models.Status) ([]models.Dashboard, error) {
+	var dashboards []models.Dashboard
+	result := d.db.Preload("Members").Preload("Memberships").Preload("Projects").
+		Joins("INNER JOIN user_dashboards ud ON ud.dashboard_id = dashboards.id").
+		Where("ud.user_id = ? AND ud.status = ?", userID, status).
+		Find(&dashboards)
+	return dashboards, result.Error
+}
+
+func (d dashboardRepo) GetDashboardMember(dashboardID uuid.UUID, userID string) (models.DashboardMember, error) {
+	var member models.DashboardMember
+	result := d.db.Where("dashboard_id = ? AND user_id = ?", dashboardID, userID).First(&member)
+	return member, result.Error
+}
+
+// IsDashboardMember returns whether the user has accepted membership of the dashboard.
+func (d dashboardRepo) IsDashboardMember(userID string, dashboardID uuid.UUID) (bool, error) {
+	var count int64
+	result := d.db.Model(&models.DashboardMember{}).
+		Joins("INNER JOIN dashboards ON dashboards.id = user_dashboards.dashboard_id AND dashboards.deleted_at IS NULL").
+		Where("user_dashboards.user_id = ? AND user_dashboards.dashboard_id = ? AND user_dashboards.status = ?",
+			userID, dashboardID, models.AcceptedStatus).
+		Count(&count)
+	return count > 0, result.Error
+}
+
+// InviteDashboardMember creates a pending membership for the user. Inviting
+// someone who previously rejected the dashboard puts them back to pending.
+func (d dashboardRepo) InviteDashboardMember(dashboardID uuid.UUID, userID, invitedBy string) (models.DashboardMember, error) {
+	member := models.DashboardMember{
+		DashboardID: dashboardID,
+		UserID:      userID,
+		Status:      models.PendingStatus,
+		InvitedBy:   invitedBy,
+	}
+	result := d.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dashboard_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "invited_by", "updated_at"}),
+	}).Create(&member)
+	return member, result.Error
+}
+
+func (d dashboardRepo) UpdateDashboardMemberStatus(dashboardID uuid.UUID, userID string, status models.Status) error {
+	result := d.db.Model(&models.DashboardMember{}).
+		Where("dashboard_id = ? AND user_id = ?", dashboardID, userID).
+		Update("status", status)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (d dashboardRepo) RemoveDashboardMember(dashboardID uuid.UUID, userID string) error {
+	result := d.db.Where("dashboard_id = ? AND user_id = ?", dashboardID, userID).
+		Delete(&models.DashboardMember{})
+	return result.Error
 }
 
 func NewDashboardRepository(db *gorm.DB) DashboardRepository {
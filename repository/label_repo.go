@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+var (
+	ErrLabelExists = errors.New("the project already has a label with this name")
+)
+
+type LabelRepository interface {
+	ListLabels(projectID uint) ([]models.Label, error)
+	GetLabel(projectID uint, labelID uint) (models.Label, error)
+	CreateLabel(label models.Label) (models.Label, error)
+	UpdateLabel(label models.Label) (models.Label, error)
+	DeleteLabel(label models.Label) error
+
+	SetTaskLabels(task models.Task, labels []models.Label) error
+}
+
+type labelRepo struct {
+	db *gorm.DB
+}
+
+func NewLabelRepository(db *gorm.DB) LabelRepository {
+	return &labelRepo{
+		db: db,
+	}
+}
+
+// labelLockKey is paired with the project ID to serialise changes to a project's label
+// names, so two labels with the same name can't be saved at the same time.
+const labelLockKey = 7283043
+
+func (r *labelRepo) ListLabels(projectID uint) ([]models.Label, error) {
+	var labels []models.Label
+	result := r.db.
+		Where("project_id = ?", projectID).
+		Order("LOWER(name), id").
+		Find(&labels)
+	return labels, result.Error
+}
+
+func (r *labelRepo) GetLabel(projectID uint, labelID uint) (models.Label, error) {
+	var label models.Label
+	result := r.db.Where("project_id = ?", projectID).First(&label, labelID)
+	return label, result.Error
+}
+
+// CreateLabel returns ErrLabelExists if the project has a label with the same name.
+func (r *labelRepo) CreateLabel(label models.Label) (models.Label, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkLabelName(tx, label); err != nil {
+			return err
+		}
+		return tx.Create(&label).Error
+	})
+	return label, err
+}
+
+// UpdateLabel saves the label's name and color, returning ErrLabelExists if another of
+// the project's labels has the name.
+func (r *labelRepo) UpdateLabel(label models.Label) (models.Label, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkLabelName(tx, label); err != nil {
+			return err
+		}
+		return tx.Model(&label).
+			Select("Name", "Color").
+			Updates(label).Error
+	})
+	return label, err
+}
+
+// DeleteLabel deletes the label and takes it off the tasks it was on.
+func (r *labelRepo) DeleteLabel(label models.Label) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("DELETE FROM task_labels WHERE label_id = ?", label.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(&label).Error
+	})
+}
+
+// SetTaskLabels replaces the task's labels.
+func (r *labelRepo) SetTaskLabels(task models.Task, labels []models.Label) error {
+	return r.db.Model(&models.Task{ID: task.ID}).Association("Labels").Replace(labels)
+}
+
+// checkLabelName locks the project's labels and checks no other label has the name.
+func checkLabelName(tx *gorm.DB, label models.Label) error {
+	result := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", labelLockKey, label.ProjectID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var count int64
+	result = tx.Model(&models.Label{}).
+		Where("project_id = ? AND LOWER(name) = ? AND id <> ?", label.ProjectID, strings.ToLower(label.Name), label.ID).
+		Count(&count)
+	if result.Error != nil {
+		return result.Error
+	}
+	if count > 0 {
+		return ErrLabelExists
+	}
+	return nil
+}
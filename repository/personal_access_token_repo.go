@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+var (
+	// personalAccessTokenTouchInterval limits how often a token's last used time is written
+	personalAccessTokenTouchInterval = time.Minute
+)
+
+type PersonalAccessTokenRepository interface {
+	CreatePersonalAccessToken(pat models.PersonalAccessToken) (models.PersonalAccessToken, error)
+	ListPersonalAccessTokensByUser(userID string) ([]models.PersonalAccessToken, error)
+	RevokePersonalAccessToken(id uint, userID string) error
+
+	// GetPersonalAccessTokenByHash and TouchPersonalAccessToken make the repository
+	// usable as the middleware's token.PersonalAccessTokenStore
+	GetPersonalAccessTokenByHash(hash string) (models.PersonalAccessToken, error)
+	TouchPersonalAccessToken(id uint) error
+}
+
+type personalAccessTokenRepo struct {
+	db *gorm.DB
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepo{
+		db: db,
+	}
+}
+
+func (r *personalAccessTokenRepo) CreatePersonalAccessToken(pat models.PersonalAccessToken) (models.PersonalAccessToken, error) {
+	result := r.db.Create(&pat)
+	return pat, result.Error
+}
+
+func (r *personalAccessTokenRepo) ListPersonalAccessTokensByUser(userID string) ([]models.PersonalAccessToken, error) {
+	var pats []models.PersonalAccessToken
+	result := r.db.
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&pats)
+	return pats, result.Error
+}
+
+// RevokePersonalAccessToken revokes the user's token with the given ID.
+// gorm.ErrRecordNotFound is returned if the user has no such active token.
+func (r *personalAccessTokenRepo) RevokePersonalAccessToken(id uint, userID string) error {
+	result := r.db.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepo) GetPersonalAccessTokenByHash(hash string) (models.PersonalAccessToken, error) {
+	var pat models.PersonalAccessToken
+	result := r.db.Where("token_hash = ?", hash).First(&pat)
+	return pat, result.Error
+}
+
+// TouchPersonalAccessToken records that the token was just used. Scripts can make a lot
+// of requests, so it's only written if it wasn't already within the last minute.
+func (r *personalAccessTokenRepo) TouchPersonalAccessToken(id uint) error {
+	now := time.Now()
+	result := r.db.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-personalAccessTokenTouchInterval)).
+		Update("last_used_at", now)
+	return result.Error
+}
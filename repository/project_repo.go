@@ -1,21 +1,46 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	"github.com/todanni/api/models"
 )
 
+var (
+	ErrProjectOwnerChanged = errors.New("project owner has changed")
+	ErrInviteNotPending    = errors.New("the project invite has already been answered, revoked or has expired")
+)
+
 type ProjectRepository interface {
 	CreateProject(project models.Project) (models.Project, error)
 	UpdateProject(project models.Project) (models.Project, error)
 	ListProjectsByUser(userID string) ([]models.Project, error)
 	GetProjectByID(projectID string) (models.Project, error)
 	DeleteProject(projectID string) error
-	ListProjectMembers(projectID string) ([]models.User, error)
+	ListProjectMembers(projectID string) ([]models.ProjectMember, error)
 	AddProjectMember(userID string, prjID uint) error
 	RemoveProjectMember(userID string, prjID uint) error
+	IsProjectMember(userID string, prjID uint) (bool, error)
+	GetProjectRole(userID string, prjID uint) (models.ProjectRole, error)
+	UpdateProjectMemberRole(userID string, prjID uint, role models.ProjectRole) error
+
+	CreateProjectInvite(invite models.ProjectInvite) (models.ProjectInvite, error)
+	GetProjectInviteByID(inviteID string) (models.ProjectInvite, error)
+	GetPendingProjectInvite(userID string, prjID uint) (models.ProjectInvite, error)
+	ListProjectInvitesByProject(prjID uint) ([]models.ProjectInvite, error)
+	ListPendingProjectInvitesByUser(userID string) ([]models.ProjectInvite, error)
+	UpdateProjectInviteStatus(inviteID uint, status models.Status) error
+	AcceptProjectInvite(invite models.ProjectInvite) error
+
+	CreateProjectTransfer(transfer models.ProjectTransfer) (models.ProjectTransfer, error)
+	GetPendingProjectTransfer(prjID uint) (models.ProjectTransfer, error)
+	CloseProjectTransfer(transfer models.ProjectTransfer, status models.Status, actorID string) error
+	TransferProjectOwnership(transfer models.ProjectTransfer, actorID string) error
+	ListProjectEvents(prjID uint) ([]models.ProjectEvent, error)
 }
 
 type projectRepo struct {
@@ -30,15 +55,35 @@ func NewProjectRepository(db *gorm.DB) ProjectRepository {
 
 func (r *projectRepo) ListProjectsByUser(userID string) ([]models.Project, error) {
 	var projects []models.Project
-	result := r.db.Raw(
-		"SELECT * FROM projects INNER JOIN user_projects up on projects.id = up.project_id WHERE user_id=?", userID).
-		Scan(&projects)
+	result := r.db.Select("projects.*").
+		Joins("INNER JOIN user_projects ON user_projects.project_id = projects.id").
+		Where("user_projects.user_id = ?", userID).
+		Find(&projects)
 	return projects, result.Error
 }
 
+// CreateProject creates the project with its owner as its first member,
+// and the default task statuses.
 func (r *projectRepo) CreateProject(project models.Project) (models.Project, error) {
-	result := r.db.Create(&project)
-	return project, result.Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Members").Create(&project)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Create(&models.ProjectMember{
+			ProjectID: project.ID,
+			UserID:    project.Owner,
+			Role:      models.OwnerRole,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		statuses := models.DefaultTaskStatuses(project.ID)
+		return tx.Create(&statuses).Error
+	})
+	return project, err
 }
 
 func (r *projectRepo) DeleteProject(projectID string) error {
@@ -52,10 +97,12 @@ func (r *projectRepo) GetProjectByID(projectID string) (models.Project, error) {
 	return project, result.Error
 }
 
-func (r *projectRepo) ListProjectMembers(projectID string) ([]models.User, error) {
-	var projectMembers []models.User
-	result := r.db.Raw("SELECT * FROM users INNER JOIN user_projects up on users.id = up.user_id WHERE project_id = ?", projectID).
-		Scan(&projectMembers)
+func (r *projectRepo) ListProjectMembers(projectID string) ([]models.ProjectMember, error) {
+	var projectMembers []models.ProjectMember
+	result := r.db.Preload("User").
+		Where("project_id = ?", projectID).
+		Order("created_at").
+		Find(&projectMembers)
 	return projectMembers, result.Error
 }
 
@@ -77,3 +124,220 @@ func (r *projectRepo) UpdateProject(project models.Project) (models.Project, err
 	result := r.db.Model(&project).Clauses(clause.Returning{}).Updates(project)
 	return project, result.Error
 }
+
+func (r *projectRepo) IsProjectMember(userID string, projectID uint) (bool, error) {
+	var count int64
+	result := r.db.Table("user_projects").
+		Joins("INNER JOIN projects ON projects.id = user_projects.project_id AND projects.deleted_at IS NULL").
+		Where("user_projects.user_id = ? AND user_projects.project_id = ?", userID, projectID).
+		Count(&count)
+	return count > 0, result.Error
+}
+
+// GetProjectRole returns the user's role in the project, or an empty role if
+// they aren't a member or the project has been deleted.
+func (r *projectRepo) GetProjectRole(userID string, projectID uint) (models.ProjectRole, error) {
+	var members []models.ProjectMember
+	result := r.db.
+		Joins("INNER JOIN projects ON projects.id = user_projects.project_id AND projects.deleted_at IS NULL").
+		Where("user_projects.user_id = ? AND user_projects.project_id = ?", userID, projectID).
+		Limit(1).
+		Find(&members)
+	if result.Error != nil || len(members) == 0 {
+		return "", result.Error
+	}
+	return members[0].Role, nil
+}
+
+func (r *projectRepo) UpdateProjectMemberRole(userID string, projectID uint, role models.ProjectRole) error {
+	result := r.db.Model(&models.ProjectMember{}).
+		Where("user_id = ? AND project_id = ?", userID, projectID).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *projectRepo) CreateProjectInvite(invite models.ProjectInvite) (models.ProjectInvite, error) {
+	result := r.db.Create(&invite)
+	return invite, result.Error
+}
+
+func (r *projectRepo) GetProjectInviteByID(inviteID string) (models.ProjectInvite, error) {
+	var invite models.ProjectInvite
+	result := r.db.Preload("Project").First(&invite, inviteID)
+	return invite, result.Error
+}
+
+// GetPendingProjectInvite returns the user's unexpired pending invite to the project, if there is one.
+func (r *projectRepo) GetPendingProjectInvite(userID string, projectID uint) (models.ProjectInvite, error) {
+	var invite models.ProjectInvite
+	result := r.db.
+		Where("user_id = ? AND project_id = ? AND status = ? AND expires_at > ?",
+			userID, projectID, models.PendingStatus, time.Now()).
+		First(&invite)
+	return invite, result.Error
+}
+
+func (r *projectRepo) ListProjectInvitesByProject(projectID uint) ([]models.ProjectInvite, error) {
+	var invites []models.ProjectInvite
+	result := r.db.Preload("Project").
+		Where("project_id = ?", projectID).
+		Order("created_at DESC").
+		Find(&invites)
+	return invites, result.Error
+}
+
+func (r *projectRepo) ListPendingProjectInvitesByUser(userID string) ([]models.ProjectInvite, error) {
+	var invites []models.ProjectInvite
+	result := r.db.Preload("Project").
+		Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.PendingStatus, time.Now()).
+		Order("created_at DESC").
+		Find(&invites)
+	return invites, result.Error
+}
+
+func (r *projectRepo) UpdateProjectInviteStatus(inviteID uint, status models.Status) error {
+	result := r.db.Model(&models.ProjectInvite{ID: inviteID}).Update("status", status)
+	return result.Error
+}
+
+// AcceptProjectInvite marks the invite as accepted and adds the invited user
+// to the project with the role they were invited with. It returns ErrInviteNotPending
+// if the invite was answered, revoked or expired since it was looked up.
+func (r *projectRepo) AcceptProjectInvite(invite models.ProjectInvite) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProjectInvite{}).
+			Where("id = ? AND status = ? AND expires_at > ?", invite.ID, models.PendingStatus, time.Now()).
+			Update("status", models.AcceptedStatus)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInviteNotPending
+		}
+
+		role := invite.Role
+		if role == "" {
+			role = models.MemberRole
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ProjectMember{
+			ProjectID: invite.ProjectID,
+			UserID:    invite.UserID,
+			Role:      role,
+		}).Error
+	})
+}
+
+// CreateProjectTransfer stores a pending transfer, replacing any other pending
+// transfer of the project, and records the request in the project's history.
+func (r *projectRepo) CreateProjectTransfer(transfer models.ProjectTransfer) (models.ProjectTransfer, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProjectTransfer{}).
+			Where("project_id = ? AND status = ?", transfer.ProjectID, models.PendingStatus).
+			Update("status", models.RevokedStatus)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Create(&transfer)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		event := transfer.Event(models.TransferRequestedEvent, transfer.FromUserID)
+		return tx.Create(&event).Error
+	})
+	return transfer, err
+}
+
+// GetPendingProjectTransfer returns the project's unexpired pending transfer, if there is one.
+func (r *projectRepo) GetPendingProjectTransfer(projectID uint) (models.ProjectTransfer, error) {
+	var transfer models.ProjectTransfer
+	result := r.db.
+		Where("project_id = ? AND status = ? AND expires_at > ?", projectID, models.PendingStatus, time.Now()).
+		First(&transfer)
+	return transfer, result.Error
+}
+
+// CloseProjectTransfer marks a pending transfer as rejected by the new owner or revoked
+// by the current one, and records it in the project's history.
+func (r *projectRepo) CloseProjectTransfer(transfer models.ProjectTransfer, status models.Status, actorID string) error {
+	eventType := models.TransferCancelledEvent
+	if status == models.RejectedStatus {
+		eventType = models.TransferDeclinedEvent
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProjectTransfer{ID: transfer.ID}).Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		event := transfer.Event(eventType, actorID)
+		return tx.Create(&event).Error
+	})
+}
+
+// TransferProjectOwnership makes ToUserID the owner of the project, demotes FromUserID to admin,
+// and records the change in the project's history. If the transfer was stored, it's marked as
+// accepted, and any other pending transfer of the project is revoked. ErrProjectOwnerChanged
+// is returned if FromUserID no longer owns the project.
+func (r *projectRepo) TransferProjectOwnership(transfer models.ProjectTransfer, actorID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Project{}).
+			Where("id = ? AND owner = ?", transfer.ProjectID, transfer.FromUserID).
+			Update("owner", transfer.ToUserID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrProjectOwnerChanged
+		}
+
+		for _, member := range transfer.RoleChanges() {
+			result = tx.Model(&models.ProjectMember{}).
+				Where("project_id = ? AND user_id = ?", member.ProjectID, member.UserID).
+				Update("role", member.Role)
+			if result.Error != nil {
+				return result.Error
+			}
+			// The new owner has to still be a member
+			if result.RowsAffected == 0 && member.Role == models.OwnerRole {
+				return gorm.ErrRecordNotFound
+			}
+		}
+
+		if transfer.ID != 0 {
+			result = tx.Model(&models.ProjectTransfer{ID: transfer.ID}).Update("status", models.AcceptedStatus)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		// Any other request to transfer the project can't go through any more
+		result = tx.Model(&models.ProjectTransfer{}).
+			Where("project_id = ? AND status = ? AND id <> ?", transfer.ProjectID, models.PendingStatus, transfer.ID).
+			Update("status", models.RevokedStatus)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		event := transfer.Event(models.OwnerChangedEvent, actorID)
+		return tx.Create(&event).Error
+	})
+}
+
+func (r *projectRepo) ListProjectEvents(projectID uint) ([]models.ProjectEvent, error) {
+	var events []models.ProjectEvent
+	result := r.db.
+		Where("project_id = ?", projectID).
+		Order("created_at DESC, id DESC").
+		Find(&events)
+	return events, result.Error
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+var (
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+)
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(refreshToken models.RefreshToken) (models.RefreshToken, error)
+	GetRefreshTokenByHash(hash string) (models.RefreshToken, error)
+	RotateRefreshToken(used models.RefreshToken, next models.RefreshToken) (models.RefreshToken, error)
+}
+
+type refreshTokenRepo struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepo{
+		db: db,
+	}
+}
+
+func (r *refreshTokenRepo) CreateRefreshToken(refreshToken models.RefreshToken) (models.RefreshToken, error) {
+	result := r.db.Create(&refreshToken)
+	return refreshToken, result.Error
+}
+
+func (r *refreshTokenRepo) GetRefreshTokenByHash(hash string) (models.RefreshToken, error) {
+	var refreshToken models.RefreshToken
+	result := r.db.Where("token_hash = ?", hash).First(&refreshToken)
+	return refreshToken, result.Error
+}
+
+// RotateRefreshToken marks the used token as spent and stores its replacement.
+// If the used token was spent or revoked concurrently, ErrRefreshTokenReused is returned.
+func (r *refreshTokenRepo) RotateRefreshToken(used models.RefreshToken, next models.RefreshToken) (models.RefreshToken, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", used.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		return tx.Create(&next).Error
+	})
+	return next, err
+}
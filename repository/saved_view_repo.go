@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+type SavedViewRepository interface {
+	ListSavedViews(userID string) ([]models.SavedView, error)
+	GetSavedView(viewID uint) (models.SavedView, error)
+	CreateSavedView(view models.SavedView) (models.SavedView, error)
+	UpdateSavedView(view models.SavedView) (models.SavedView, error)
+	DeleteSavedView(view models.SavedView) error
+}
+
+type savedViewRepo struct {
+	db *gorm.DB
+}
+
+func NewSavedViewRepository(db *gorm.DB) SavedViewRepository {
+	return &savedViewRepo{
+		db: db,
+	}
+}
+
+// ListSavedViews returns the user's personal views and the views shared with the projects
+// they're a member of.
+func (r *savedViewRepo) ListSavedViews(userID string) ([]models.SavedView, error) {
+	var views []models.SavedView
+	result := r.db.
+		Where("(owner_id = ? AND project_id IS NULL) OR project_id IN (SELECT project_id FROM user_projects WHERE user_id = ?)", userID, userID).
+		Order("LOWER(name), id").
+		Find(&views)
+	return views, result.Error
+}
+
+func (r *savedViewRepo) GetSavedView(viewID uint) (models.SavedView, error) {
+	var view models.SavedView
+	result := r.db.First(&view, viewID)
+	return view, result.Error
+}
+
+func (r *savedViewRepo) CreateSavedView(view models.SavedView) (models.SavedView, error) {
+	result := r.db.Create(&view)
+	return view, result.Error
+}
+
+func (r *savedViewRepo) UpdateSavedView(view models.SavedView) (models.SavedView, error) {
+	result := r.db.Save(&view)
+	return view, result.Error
+}
+
+func (r *savedViewRepo) DeleteSavedView(view models.SavedView) error {
+	return r.db.Delete(&view).Error
+}
@@ -0,0 +1,448 @@
+package repository
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+// SearchType is a kind of thing search looks through.
+type SearchType string
+
+const (
+	TaskResult    SearchType = "task"
+	CommentResult SearchType = "comment"
+	ProjectResult SearchType = "project"
+)
+
+// SearchTypes are every type search looks through, in the order results of equal rank
+// are listed.
+var SearchTypes = []SearchType{ProjectResult, TaskResult, CommentResult}
+
+// SearchQuery is what to search for and where.
+type SearchQuery struct {
+	Text string
+	// MemberID limits results to the projects the user is a member of. Projects on
+	// dashboards the user has joined are found as well, but not their tasks or comments.
+	MemberID string
+	// AllowedProjectIDs limits the results to these projects if it isn't nil
+	AllowedProjectIDs []uint
+	ProjectIDs        []uint
+	// Types limits the results to these types, or every type if it's empty
+	Types []SearchType
+	Limit int
+}
+
+// SearchResult is a task, comment or project matching a search. For comments, Title is
+// the title of the task they're on. Snippet is HTML escaped, with the matching words
+// wrapped in <mark> tags.
+type SearchResult struct {
+	Type      SearchType `json:"type"`
+	ID        uint       `json:"id"`
+	ProjectID uint       `json:"project_id"`
+	TaskID    *uint      `json:"task_id,omitempty"`
+	Title     string     `json:"title"`
+	Snippet   string     `json:"snippet"`
+	Rank      float64    `json:"rank"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type SearchRepository interface {
+	Search(query SearchQuery) ([]SearchResult, error)
+}
+
+// searchConfig is the text search configuration the search columns are built with
+const searchConfig = "english"
+
+// Matches are marked with control characters that won't be in ordinary text, so the
+// snippet can be HTML escaped before they're turned into tags.
+const (
+	startMark = '\x02'
+	stopMark  = '\x03'
+)
+
+// headlineOptions picks a couple of short fragments around the matches
+const headlineOptions = "StartSel=\x02, StopSel=\x03, MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=\" … \""
+
+type searchRepo struct {
+	db *gorm.DB
+}
+
+// NewSearchRepository searches with the Postgres text search columns and indexes added
+// by the search migration.
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	return &searchRepo{db: db}
+}
+
+func (r *searchRepo) Search(query SearchQuery) ([]SearchResult, error) {
+	var results []SearchResult
+	for _, searchType := range query.types() {
+		db := r.db.Joins("CROSS JOIN websearch_to_tsquery(?, ?) AS query", searchConfig, query.Text)
+
+		switch searchType {
+		case TaskResult:
+			db = db.Table("tasks").
+				Select(`'task' AS type, tasks.id, tasks.project_id, tasks.id AS task_id, tasks.title,
+					ts_headline(?, concat_ws(' ', tasks.title, tasks.description), query, ?) AS snippet,
+					ts_rank(tasks.search_vector, query) AS rank, tasks.updated_at`, searchConfig, headlineOptions).
+				Where("tasks.search_vector @@ query").
+				Where("tasks.deleted_at IS NULL").
+				Scopes(query.memberScope("tasks.project_id"))
+		case CommentResult:
+			db = db.Table("task_comments").
+				Select(`'comment' AS type, task_comments.id, tasks.project_id, tasks.id AS task_id, tasks.title,
+					ts_headline(?, task_comments.body, query, ?) AS snippet,
+					ts_rank(task_comments.search_vector, query) AS rank, task_comments.updated_at`, searchConfig, headlineOptions).
+				Joins("JOIN tasks ON tasks.id = task_comments.task_id AND tasks.deleted_at IS NULL").
+				Where("task_comments.search_vector @@ query").
+				Where("task_comments.deleted_at IS NULL").
+				Scopes(query.memberScope("tasks.project_id"))
+		case ProjectResult:
+			db = db.Table("projects").
+				Select(`'project' AS type, projects.id, projects.id AS project_id, projects.name AS title,
+					ts_headline(?, projects.name, query, ?) AS snippet,
+					ts_rank(projects.search_vector, query) AS rank, projects.updated_at`, searchConfig, headlineOptions).
+				Where("projects.search_vector @@ query").
+				Where("projects.deleted_at IS NULL").
+				Scopes(query.projectScope)
+		}
+
+		var found []SearchResult
+		result := db.Order("rank DESC").Limit(query.Limit).Scan(&found)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		for i := range found {
+			found[i].Snippet = highlight(found[i].Snippet)
+		}
+		results = append(results, found...)
+	}
+	return rankResults(results, query.Limit), nil
+}
+
+type likeSearchRepo struct {
+	db *gorm.DB
+}
+
+// NewLikeSearchRepository searches with plain LIKE patterns instead of text search. It
+// needs no search columns or indexes, so it works on any database, but it's slow on large
+// tables and only finds words as they're typed.
+func NewLikeSearchRepository(db *gorm.DB) SearchRepository {
+	return &likeSearchRepo{db: db}
+}
+
+func (r *likeSearchRepo) Search(query SearchQuery) ([]SearchResult, error) {
+	terms := searchTerms(query.Text)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	var results []SearchResult
+	for _, searchType := range query.types() {
+		var db *gorm.DB
+		var title, body, updatedAt string
+
+		switch searchType {
+		case TaskResult:
+			title, body, updatedAt = "tasks.title", "COALESCE(tasks.description, '')", "tasks.updated_at"
+			db = r.db.Table("tasks").
+				Select(`'task' AS type, tasks.id, tasks.project_id, tasks.id AS task_id, tasks.title,
+					COALESCE(tasks.description, '') AS snippet, tasks.updated_at`).
+				Where("tasks.deleted_at IS NULL").
+				Scopes(query.memberScope("tasks.project_id"))
+		case CommentResult:
+			title, body, updatedAt = "task_comments.body", "task_comments.body", "task_comments.updated_at"
+			db = r.db.Table("task_comments").
+				Select(`'comment' AS type, task_comments.id, tasks.project_id, tasks.id AS task_id, tasks.title,
+					task_comments.body AS snippet, task_comments.updated_at`).
+				Joins("JOIN tasks ON tasks.id = task_comments.task_id AND tasks.deleted_at IS NULL").
+				Where("task_comments.deleted_at IS NULL").
+				Scopes(query.memberScope("tasks.project_id"))
+		case ProjectResult:
+			title, body, updatedAt = "projects.name", "projects.name", "projects.updated_at"
+			db = r.db.Table("projects").
+				Select(`'project' AS type, projects.id, projects.id AS project_id, projects.name AS title,
+					projects.name AS snippet, projects.updated_at`).
+				Where("projects.deleted_at IS NULL").
+				Scopes(query.projectScope)
+		}
+
+		for _, term := range terms {
+			pattern := "%" + escapeLike(strings.ToLower(term)) + "%"
+			db = db.Where("LOWER("+title+") LIKE ? ESCAPE '\\' OR LOWER("+body+") LIKE ? ESCAPE '\\'", pattern, pattern)
+		}
+
+		var found []SearchResult
+		result := db.Order(updatedAt + " DESC").Limit(query.Limit).Scan(&found)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		for i := range found {
+			// A comment's title is its task's, which the search didn't look at
+			text, matchTitle := found[i].Snippet, found[i].Title
+			switch found[i].Type {
+			case TaskResult:
+				text = strings.TrimSpace(found[i].Title + " " + text)
+			case CommentResult:
+				matchTitle = ""
+			}
+			found[i].Rank = likeRank(matchTitle, text, terms)
+			found[i].Snippet = highlight(likeSnippet(text, terms))
+		}
+		results = append(results, found...)
+	}
+	return rankResults(results, query.Limit), nil
+}
+
+func (q SearchQuery) types() []SearchType {
+	if len(q.Types) == 0 {
+		return SearchTypes
+	}
+	var types []SearchType
+	for _, searchType := range SearchTypes {
+		for _, wanted := range q.Types {
+			if searchType == wanted {
+				types = append(types, searchType)
+				break
+			}
+		}
+	}
+	return types
+}
+
+// memberScope restricts results to the projects the query allows, by the column holding
+// their project.
+func (q SearchQuery) memberScope(column string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if q.MemberID != "" {
+			db = db.Where(column+" IN (SELECT project_id FROM user_projects WHERE user_id = ?)", q.MemberID)
+		}
+		return q.projectFilter(db, column)
+	}
+}
+
+// projectScope restricts project results to the projects the user is a member of or can
+// see on one of their dashboards.
+func (q SearchQuery) projectScope(db *gorm.DB) *gorm.DB {
+	if q.MemberID != "" {
+		db = db.Where(`projects.id IN (SELECT project_id FROM user_projects WHERE user_id = ?)
+			OR projects.id IN (SELECT dashboard_projects.project_id FROM dashboard_projects
+				JOIN user_dashboards ON user_dashboards.dashboard_id = dashboard_projects.dashboard_id
+				JOIN dashboards ON dashboards.id = dashboard_projects.dashboard_id AND dashboards.deleted_at IS NULL
+				WHERE user_dashboards.user_id = ? AND user_dashboards.status = ?)`,
+			q.MemberID, q.MemberID, models.AcceptedStatus)
+	}
+	return q.projectFilter(db, "projects.id")
+}
+
+func (q SearchQuery) projectFilter(db *gorm.DB, column string) *gorm.DB {
+	if q.AllowedProjectIDs != nil {
+		db = db.Where(column+" IN ?", q.AllowedProjectIDs)
+	}
+	if len(q.ProjectIDs) > 0 {
+		db = db.Where(column+" IN ?", q.ProjectIDs)
+	}
+	return db
+}
+
+// rankResults puts the best results first and keeps at most limit of them.
+func rankResults(results []SearchResult, limit int) []SearchResult {
+	order := map[SearchType]int{}
+	for i, searchType := range SearchTypes {
+		order[searchType] = i
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		if results[i].Type != results[j].Type {
+			return order[results[i].Type] < order[results[j].Type]
+		}
+		return results[i].UpdatedAt.After(results[j].UpdatedAt)
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	if results == nil {
+		return []SearchResult{}
+	}
+	return results
+}
+
+// highlight HTML escapes a snippet and turns its marks into <mark> tags. A mark left
+// open at the end is closed.
+func highlight(marked string) string {
+	var b strings.Builder
+	open := false
+	for {
+		i := strings.IndexAny(marked, string([]rune{startMark, stopMark}))
+		if i < 0 {
+			break
+		}
+		b.WriteString(html.EscapeString(marked[:i]))
+		switch rune(marked[i]) {
+		case startMark:
+			if !open {
+				b.WriteString("<mark>")
+				open = true
+			}
+		case stopMark:
+			if open {
+				b.WriteString("</mark>")
+				open = false
+			}
+		}
+		marked = marked[i+1:]
+	}
+	b.WriteString(html.EscapeString(marked))
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
+
+// searchTerms splits the text into the words and quoted phrases the LIKE search looks
+// for. The OR and - operators the text search understands are dropped.
+func searchTerms(text string) []string {
+	var terms []string
+	var current strings.Builder
+	quoted := false
+
+	flush := func() {
+		term := strings.TrimSpace(current.String())
+		current.Reset()
+		if !quoted {
+			term = strings.TrimPrefix(term, "-")
+			if strings.EqualFold(term, "or") {
+				return
+			}
+		}
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			flush()
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return terms
+}
+
+// likeRank scores a LIKE match by how many of the terms are in the title, and then in
+// the rest of the text.
+func likeRank(title, text string, terms []string) float64 {
+	var rank float64
+	for _, term := range terms {
+		if len(findTerm(lowerRunes(title), lowerRunes(term))) > 0 {
+			rank += 0.1
+		} else if len(findTerm(lowerRunes(text), lowerRunes(term))) > 0 {
+			rank += 0.05
+		}
+	}
+	return rank / float64(len(terms))
+}
+
+// snippetRadius is how many characters of context are kept either side of the first match
+const snippetRadius = 80
+
+// likeSnippet cuts the text down to the part around the first match and marks where the
+// terms are in it.
+func likeSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := lowerRunes(text)
+
+	first := len(runes)
+	var matches [][2]int
+	for _, term := range terms {
+		for _, match := range findTerm(lower, lowerRunes(term)) {
+			matches = append(matches, match)
+			if match[0] < first {
+				first = match[0]
+			}
+		}
+	}
+	if first == len(runes) {
+		first = 0
+	}
+
+	start, end := first-snippetRadius, first+snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+	// Don't cut words in half
+	for start > 0 && !unicode.IsSpace(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && !unicode.IsSpace(runes[end]) {
+		end++
+	}
+
+	marked := make([]bool, len(runes))
+	for _, match := range matches {
+		for i := match[0]; i < match[1]; i++ {
+			marked[i] = true
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteRune(startMark)
+		}
+		b.WriteRune(runes[i])
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteRune(stopMark)
+		}
+	}
+	if end < len(runes) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
+
+// lowerRunes lower cases the text one rune at a time, so positions in it match positions
+// in the original.
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// findTerm returns where the term is in the text, as start and end positions.
+func findTerm(text, term []rune) [][2]int {
+	if len(term) == 0 {
+		return nil
+	}
+	var matches [][2]int
+	for i := 0; i+len(term) <= len(text); i++ {
+		if string(text[i:i+len(term)]) == string(term) {
+			matches = append(matches, [2]int{i, i + len(term)})
+			i += len(term) - 1
+		}
+	}
+	return matches
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlight(t *testing.T) {
+	require.Equal(t, "fix the <mark>login</mark> page", highlight("fix the \x02login\x03 page"))
+	require.Equal(t, "&lt;b&gt; <mark>&amp;</mark>", highlight("<b> \x02&\x03"))
+	// Stray or unbalanced marks don't leave tags open
+	require.Equal(t, "a<mark>b</mark>", highlight("\x03a\x02b"))
+	require.Equal(t, "<mark>ab</mark>", highlight("\x02a\x02b\x03\x03"))
+}
+
+func TestSearchTerms(t *testing.T) {
+	require.Equal(t, []string{"release notes", "draft"}, searchTerms(`"release notes" or -draft`))
+	require.Equal(t, []string{"login", "bug"}, searchTerms(" login  OR bug "))
+	require.Empty(t, searchTerms(`"" -`))
+}
+
+func TestLikeSnippet(t *testing.T) {
+	require.Equal(t, "Fix the \x02Login\x03 page", likeSnippet("Fix the Login page", []string{"login"}))
+	require.Equal(t, "\x02abab\x03 c", likeSnippet("abab c", []string{"ab"}))
+	require.Equal(t, "Ünïcode \x02ÄB\x03", likeSnippet("Ünïcode ÄB", []string{"äb"}))
+
+	long := ""
+	for i := 0; i < 40; i++ {
+		long += "word "
+	}
+	snippet := likeSnippet(long+"needle "+long, []string{"needle"})
+	require.Contains(t, snippet, "\x02needle\x03")
+	require.True(t, len([]rune(snippet)) < len([]rune(long))*2)
+	require.Equal(t, "… word", snippet[:len("… word")])
+	require.Equal(t, "word …", snippet[len(snippet)-len("word …"):])
+}
+
+func TestLikeRank(t *testing.T) {
+	terms := []string{"login"}
+	inTitle := likeRank("Login page", "Login page broken", terms)
+	inText := likeRank("Broken page", "Broken page on login", terms)
+	require.Greater(t, inTitle, inText)
+	require.Greater(t, inText, 0.0)
+}
+
+func TestRankResults(t *testing.T) {
+	now := time.Now()
+	results := rankResults([]SearchResult{
+		{Type: CommentResult, ID: 1, Rank: 0.5},
+		{Type: TaskResult, ID: 2, Rank: 0.5},
+		{Type: TaskResult, ID: 3, Rank: 0.5, UpdatedAt: now},
+		{Type: ProjectResult, ID: 4, Rank: 0.1},
+		{Type: TaskResult, ID: 5, Rank: 0.9},
+	}, 4)
+
+	var ids []uint
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	require.Equal(t, []uint{5, 3, 2, 1}, ids)
+	require.Equal(t, []SearchResult{}, rankResults(nil, 10))
+}
+
+func TestSearchQueryTypes(t *testing.T) {
+	require.Equal(t, SearchTypes, SearchQuery{}.types())
+	require.Equal(t, []SearchType{ProjectResult, CommentResult}, SearchQuery{Types: []SearchType{CommentResult, ProjectResult}}.types())
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/todanni/api/models"
+)
+
+var (
+	// sessionTouchInterval limits how often a session's last seen details are written
+	sessionTouchInterval = time.Minute
+)
+
+type SessionRepository interface {
+	CreateSession(session models.Session) (models.Session, error)
+	GetSessionByID(sessionID uuid.UUID) (models.Session, error)
+	ListActiveSessionsByUser(userID string) ([]models.Session, error)
+	ExtendSession(sessionID uuid.UUID, expiresAt time.Time) error
+	RevokeSession(sessionID uuid.UUID) error
+
+	RevokeToken(tokenID string, expiresAt time.Time) error
+
+	// IsRevoked and TouchSession make the repository usable as the middleware's token.SessionStore
+	IsRevoked(tokenID, sessionID string) (bool, error)
+	TouchSession(sessionID, ipAddress, userAgent string) error
+}
+
+type sessionRepo struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepo{
+		db: db,
+	}
+}
+
+func (r *sessionRepo) CreateSession(session models.Session) (models.Session, error) {
+	result := r.db.Create(&session)
+	return session, result.Error
+}
+
+func (r *sessionRepo) GetSessionByID(sessionID uuid.UUID) (models.Session, error) {
+	var session models.Session
+	result := r.db.First(&session, "id = ?", sessionID)
+	return session, result.Error
+}
+
+func (r *sessionRepo) ListActiveSessionsByUser(userID string) ([]models.Session, error) {
+	var sessions []models.Session
+	result := r.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions)
+	return sessions, result.Error
+}
+
+func (r *sessionRepo) ExtendSession(sessionID uuid.UUID, expiresAt time.Time) error {
+	result := r.db.Model(&models.Session{ID: sessionID}).Update("expires_at", expiresAt)
+	return result.Error
+}
+
+// RevokeSession revokes the session along with every refresh token issued for it.
+func (r *sessionRepo) RevokeSession(sessionID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeToken adds the token ID to the revocation list until the token would have expired anyway.
+// Entries for tokens that have since expired are cleared out at the same time.
+func (r *sessionRepo) RevokeToken(tokenID string, expiresAt time.Time) error {
+	result := r.db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	result = r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       tokenID,
+		ExpiresAt: expiresAt,
+	})
+	return result.Error
+}
+
+// IsRevoked returns whether the token itself, or the session it was issued for, has been revoked.
+func (r *sessionRepo) IsRevoked(tokenID, sessionID string) (bool, error) {
+	var count int64
+	result := r.db.Model(&models.RevokedToken{}).Where("jti = ?", tokenID).Count(&count)
+	if result.Error != nil || count > 0 {
+		return count > 0, result.Error
+	}
+
+	if sessionID == "" {
+		return false, nil
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return true, nil
+	}
+
+	result = r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NOT NULL", id).
+		Count(&count)
+	return count > 0, result.Error
+}
+
+// TouchSession records the session as seen from the given address and device,
+// writing at most once every sessionTouchInterval.
+func (r *sessionRepo) TouchSession(sessionID, ipAddress, userAgent string) error {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result := r.db.Model(&models.Session{}).
+		Where("id = ? AND last_seen_at < ?", id, now.Add(-sessionTouchInterval)).
+		Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"ip_address":   ipAddress,
+			"user_agent":   userAgent,
+		})
+	return result.Error
+}
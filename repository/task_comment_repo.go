@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+type TaskCommentRepository interface {
+	ListTaskComments(taskID uint) ([]models.TaskComment, error)
+	GetTaskComment(taskID uint, commentID uint) (models.TaskComment, error)
+	CreateTaskComment(comment models.TaskComment) (models.TaskComment, error)
+	UpdateTaskComment(comment models.TaskComment, previousBody string) (models.TaskComment, error)
+	DeleteTaskComment(comment models.TaskComment) error
+	ListTaskCommentRevisions(commentID uint) ([]models.TaskCommentRevision, error)
+}
+
+type taskCommentRepo struct {
+	db *gorm.DB
+}
+
+func NewTaskCommentRepository(db *gorm.DB) TaskCommentRepository {
+	return &taskCommentRepo{
+		db: db,
+	}
+}
+
+// ListTaskComments returns the task's comments oldest first, including deleted ones so
+// their replies can still be threaded.
+func (r *taskCommentRepo) ListTaskComments(taskID uint) ([]models.TaskComment, error) {
+	var comments []models.TaskComment
+	result := r.db.Unscoped().
+		Where("task_id = ?", taskID).
+		Order("created_at, id").
+		Find(&comments)
+	return comments, result.Error
+}
+
+func (r *taskCommentRepo) GetTaskComment(taskID uint, commentID uint) (models.TaskComment, error) {
+	var comment models.TaskComment
+	result := r.db.Where("task_id = ?", taskID).First(&comment, commentID)
+	return comment, result.Error
+}
+
+func (r *taskCommentRepo) CreateTaskComment(comment models.TaskComment) (models.TaskComment, error) {
+	result := r.db.Create(&comment)
+	return comment, result.Error
+}
+
+// UpdateTaskComment saves the comment's new body and mentions, keeping the previous
+// body as a revision.
+func (r *taskCommentRepo) UpdateTaskComment(comment models.TaskComment, previousBody string) (models.TaskComment, error) {
+	now := time.Now()
+	comment.EditedAt = &now
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Create(&models.TaskCommentRevision{
+			CommentID: comment.ID,
+			Body:      previousBody,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&comment).
+			Select("Body", "Mentions", "EditedAt").
+			Updates(comment).Error
+	})
+	return comment, err
+}
+
+func (r *taskCommentRepo) DeleteTaskComment(comment models.TaskComment) error {
+	return r.db.Delete(&comment).Error
+}
+
+// ListTaskCommentRevisions returns the comment's earlier bodies, oldest first.
+func (r *taskCommentRepo) ListTaskCommentRevisions(commentID uint) ([]models.TaskCommentRevision, error) {
+	var revisions []models.TaskCommentRevision
+	result := r.db.
+		Where("comment_id = ?", commentID).
+		Order("created_at, id").
+		Find(&revisions)
+	return revisions, result.Error
+}
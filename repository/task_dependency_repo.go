@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+var (
+	ErrDependencyCycle  = errors.New("dependency would create a cycle")
+	ErrDependencyExists = errors.New("dependency already exists")
+)
+
+type TaskDependencyRepository interface {
+	CreateTaskDependency(dependency models.TaskDependency) (models.TaskDependency, error)
+	DeleteTaskDependency(taskID uint, blockedByID uint) error
+	ListBlockers(taskID uint) ([]models.Task, error)
+	ListBlockedTasks(taskID uint) ([]models.Task, error)
+}
+
+type taskDependencyRepo struct {
+	db *gorm.DB
+}
+
+func NewTaskDependencyRepository(db *gorm.DB) TaskDependencyRepository {
+	return &taskDependencyRepo{
+		db: db,
+	}
+}
+
+// dependencyLockKey serialises dependency changes, so two links created at the same
+// time can't form a cycle between them.
+const dependencyLockKey = 7283041
+
+// CreateTaskDependency links the tasks, returning ErrDependencyCycle if the blocking task
+// is already waiting on the task, directly or through other tasks.
+func (r *taskDependencyRepo) CreateTaskDependency(dependency models.TaskDependency) (models.TaskDependency, error) {
+	if dependency.TaskID == dependency.BlockedByID {
+		return dependency, ErrDependencyCycle
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("SELECT pg_advisory_xact_lock(?)", dependencyLockKey)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var exists bool
+		result = tx.Raw(`SELECT EXISTS (SELECT 1 FROM task_dependencies WHERE task_id = ? AND blocked_by_id = ?)`,
+			dependency.TaskID, dependency.BlockedByID).Scan(&exists)
+		if result.Error != nil {
+			return result.Error
+		}
+		if exists {
+			return ErrDependencyExists
+		}
+
+		// Follow what the blocking task is waiting on until it runs out or reaches the task
+		var cycle bool
+		result = tx.Raw(`
+			WITH RECURSIVE blockers AS (
+				SELECT blocked_by_id FROM task_dependencies WHERE task_id = ?
+				UNION
+				SELECT task_dependencies.blocked_by_id FROM task_dependencies
+				JOIN blockers ON task_dependencies.task_id = blockers.blocked_by_id
+			)
+			SELECT EXISTS (SELECT 1 FROM blockers WHERE blocked_by_id = ?)`,
+			dependency.BlockedByID, dependency.TaskID).Scan(&cycle)
+		if result.Error != nil {
+			return result.Error
+		}
+		if cycle {
+			return ErrDependencyCycle
+		}
+
+		return tx.Create(&dependency).Error
+	})
+	return dependency, err
+}
+
+// DeleteTaskDependency removes the link, returning gorm.ErrRecordNotFound if there wasn't one.
+func (r *taskDependencyRepo) DeleteTaskDependency(taskID uint, blockedByID uint) error {
+	result := r.db.
+		Where("task_id = ? AND blocked_by_id = ?", taskID, blockedByID).
+		Delete(&models.TaskDependency{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListBlockers returns the tasks the task is waiting on.
+func (r *taskDependencyRepo) ListBlockers(taskID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	result := r.db.
+		Joins("JOIN task_dependencies ON task_dependencies.blocked_by_id = tasks.id").
+		Where("task_dependencies.task_id = ?", taskID).
+		Order("tasks.id").
+		Find(&tasks)
+	return tasks, result.Error
+}
+
+// ListBlockedTasks returns the tasks waiting on the task.
+func (r *taskDependencyRepo) ListBlockedTasks(taskID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	result := r.db.
+		Joins("JOIN task_dependencies ON task_dependencies.task_id = tasks.id").
+		Where("task_dependencies.blocked_by_id = ?", taskID).
+		Order("tasks.id").
+		Find(&tasks)
+	return tasks, result.Error
+}
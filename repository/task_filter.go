@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// TaskFilter narrows a list of tasks. A task has to match every condition that's set, and
+// any of the values within a condition. User IDs can be empty to match unassigned tasks.
+type TaskFilter struct {
+	// MemberID limits the tasks to the projects the user is a member of
+	MemberID string
+	// AllowedProjectIDs limits the tasks to these projects if it isn't nil
+	AllowedProjectIDs []uint
+
+	ProjectIDs []uint
+	Status     StatusFilter
+	AssignedTo []string
+	CreatedBy  []string
+	// Involves matches tasks created by or assigned to any of the users
+	Involves []string
+	// LabelGroups needs the task to have one of the labels in each group. Names are
+	// matched ignoring case.
+	LabelGroups [][]string
+	Priorities  []models.Priority
+	Done        *bool
+	Deadline    TimeRange
+	Created     TimeRange
+	Updated     TimeRange
+	// Text needs every term to be in the title or description, ignoring case
+	Text []string
+}
+
+// StatusFilter matches tasks in any of the statuses, by ID, name or category. Names are
+// matched ignoring case.
+type StatusFilter struct {
+	IDs        []uint
+	Names      []string
+	Categories []models.StatusCategory
+}
+
+func (f StatusFilter) isEmpty() bool {
+	return len(f.IDs) == 0 && len(f.Names) == 0 && len(f.Categories) == 0
+}
+
+// TimeRange matches times from From up to but not including To. Either end can be left
+// open. Missing matches tasks without a deadline instead.
+type TimeRange struct {
+	From    *time.Time
+	To      *time.Time
+	Missing bool
+}
+
+func (r TimeRange) isEmpty() bool {
+	return r.From == nil && r.To == nil && !r.Missing
+}
+
+// hasDeadline matches tasks with a deadline. Tasks without one have the zero time.
+const hasDeadline = "tasks.deadline >= '0001-01-02 00:00:00+00'"
+
+// scope restricts the query to tasks matching the filter.
+func (f TaskFilter) scope(db *gorm.DB) *gorm.DB {
+	if f.MemberID != "" {
+		db = db.Where("tasks.project_id IN (SELECT project_id FROM user_projects WHERE user_id = ?)", f.MemberID)
+	}
+	if f.AllowedProjectIDs != nil {
+		db = db.Where("tasks.project_id IN ?", f.AllowedProjectIDs)
+	}
+	if len(f.ProjectIDs) > 0 {
+		db = db.Where("tasks.project_id IN ?", f.ProjectIDs)
+	}
+
+	if !f.Status.isEmpty() {
+		var conditions []string
+		var args []interface{}
+		if len(f.Status.IDs) > 0 {
+			conditions = append(conditions, "tasks.status_id IN ?")
+			args = append(args, f.Status.IDs)
+		}
+		if len(f.Status.Names) > 0 {
+			conditions = append(conditions, "tasks.status_id IN (SELECT id FROM task_statuses WHERE LOWER(name) IN ?)")
+			args = append(args, lowerAll(f.Status.Names))
+		}
+		if len(f.Status.Categories) > 0 {
+			conditions = append(conditions, "tasks.status_id IN (SELECT id FROM task_statuses WHERE category IN ?)")
+			args = append(args, f.Status.Categories)
+		}
+		db = db.Where(strings.Join(conditions, " OR "), args...)
+	}
+
+	if len(f.AssignedTo) > 0 {
+		db = db.Where("COALESCE(tasks.assigned_to, '') IN ?", f.AssignedTo)
+	}
+	if len(f.CreatedBy) > 0 {
+		db = db.Where("tasks.created_by IN ?", f.CreatedBy)
+	}
+	if len(f.Involves) > 0 {
+		db = db.Where("tasks.created_by IN ? OR COALESCE(tasks.assigned_to, '') IN ?", f.Involves, f.Involves)
+	}
+
+	for _, group := range f.LabelGroups {
+		db = db.Where(`tasks.id IN (SELECT task_labels.task_id FROM task_labels
+			JOIN labels ON labels.id = task_labels.label_id WHERE LOWER(labels.name) IN ?)`, lowerAll(group))
+	}
+
+	if len(f.Priorities) > 0 {
+		db = db.Where("tasks.priority IN ?", f.Priorities)
+	}
+	if f.Done != nil {
+		db = db.Where("COALESCE(tasks.done, false) = ?", *f.Done)
+	}
+
+	if f.Deadline.Missing {
+		db = db.Where("NOT (" + hasDeadline + ")")
+	} else if !f.Deadline.isEmpty() {
+		db = db.Where(hasDeadline).Scopes(f.Deadline.scope("tasks.deadline"))
+	}
+	db = db.Scopes(f.Created.scope("tasks.created_at"), f.Updated.scope("tasks.updated_at"))
+
+	for _, term := range f.Text {
+		pattern := "%" + escapeLike(term) + "%"
+		db = db.Where("tasks.title ILIKE ? OR tasks.description ILIKE ?", pattern, pattern)
+	}
+	return db
+}
+
+func (r TimeRange) scope(column string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if r.From != nil {
+			db = db.Where(column+" >= ?", *r.From)
+		}
+		if r.To != nil {
+			db = db.Where(column+" < ?", *r.To)
+		}
+		return db
+	}
+}
+
+// TaskSortField is what tasks can be ordered by.
+type TaskSortField string
+
+const (
+	SortByPriority TaskSortField = "priority"
+	SortByDeadline TaskSortField = "deadline"
+	SortByCreated  TaskSortField = "created"
+	SortByUpdated  TaskSortField = "updated"
+	SortByTitle    TaskSortField = "title"
+	SortByID       TaskSortField = "id"
+)
+
+// TaskOrder orders tasks by the field, ascending unless Desc is set. Tasks without a
+// deadline come after those with one when sorting by deadline.
+type TaskOrder struct {
+	Field TaskSortField
+	Desc  bool
+}
+
+// TaskPage is a page of a list of tasks in Order, starting after the task the cursor
+// points at. A Limit of 0 returns every task.
+type TaskPage struct {
+	Order []TaskOrder
+	After *TaskCursor
+	Limit int
+}
+
+// TaskCursor points at a task in a list. It holds the task's value for each field of the
+// list's order, followed by its ID.
+type TaskCursor struct {
+	Values []string
+}
+
+// noDeadline is where tasks without a deadline sort
+var noDeadline = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// NewTaskCursor returns a cursor pointing at the task in a list with the order.
+func NewTaskCursor(task models.Task, order []TaskOrder) TaskCursor {
+	cursor := TaskCursor{}
+	for _, o := range order {
+		cursor.Values = append(cursor.Values, o.Field.value(task))
+	}
+	cursor.Values = append(cursor.Values, SortByID.value(task))
+	return cursor
+}
+
+func (f TaskSortField) expression() string {
+	switch f {
+	case SortByPriority:
+		return "tasks.priority"
+	case SortByDeadline:
+		return "CASE WHEN " + hasDeadline + " THEN tasks.deadline ELSE '9999-12-31 00:00:00+00' END"
+	case SortByCreated:
+		return "tasks.created_at"
+	case SortByUpdated:
+		return "tasks.updated_at"
+	case SortByTitle:
+		return `LOWER(tasks.title) COLLATE "C"`
+	default:
+		return "tasks.id"
+	}
+}
+
+// value is the task's value for the field, in the form it's kept in a cursor.
+func (f TaskSortField) value(task models.Task) string {
+	switch f {
+	case SortByPriority:
+		return string(task.Priority)
+	case SortByDeadline:
+		if task.Deadline.IsZero() {
+			return noDeadline.Format(time.RFC3339Nano)
+		}
+		return task.Deadline.UTC().Format(time.RFC3339Nano)
+	case SortByCreated:
+		return task.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortByUpdated:
+		return task.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case SortByTitle:
+		return strings.ToLower(task.Title)
+	default:
+		return strconv.FormatUint(uint64(task.ID), 10)
+	}
+}
+
+// parse turns a value from a cursor back into a query argument.
+func (f TaskSortField) parse(value string) (interface{}, error) {
+	switch f {
+	case SortByDeadline, SortByCreated, SortByUpdated:
+		return time.Parse(time.RFC3339Nano, value)
+	case SortByPriority, SortByTitle:
+		return value, nil
+	default:
+		return strconv.ParseUint(value, 10, 64)
+	}
+}
+
+func (o TaskOrder) orderBy() string {
+	if o.Desc {
+		return o.Field.expression() + " DESC"
+	}
+	return o.Field.expression()
+}
+
+// afterCursor returns the condition for tasks after the cursor in the order, which ends
+// in the ID. A task comes after the cursor if it's equal on the first few fields and then
+// past it on the next one.
+func afterCursor(order []TaskOrder, cursor TaskCursor) (string, []interface{}, error) {
+	if len(cursor.Values) != len(order) {
+		return "", nil, ErrInvalidCursor
+	}
+
+	var alternatives []string
+	var args []interface{}
+	for i, o := range order {
+		var terms []string
+		for j := 0; j < i; j++ {
+			value, err := order[j].Field.parse(cursor.Values[j])
+			if err != nil {
+				return "", nil, ErrInvalidCursor
+			}
+			terms = append(terms, order[j].Field.expression()+" = ?")
+			args = append(args, value)
+		}
+
+		value, err := o.Field.parse(cursor.Values[i])
+		if err != nil {
+			return "", nil, ErrInvalidCursor
+		}
+		comparison := " > ?"
+		if o.Desc {
+			comparison = " < ?"
+		}
+		terms = append(terms, o.Field.expression()+comparison)
+		args = append(args, value)
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return fmt.Sprintf("(%s)", strings.Join(alternatives, " OR ")), args, nil
+}
+
+func lowerAll(values []string) []string {
+	lower := make([]string, 0, len(values))
+	for _, value := range values {
+		lower = append(lower, strings.ToLower(value))
+	}
+	return lower
+}
+
+// escapeLike escapes the characters that are special in a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
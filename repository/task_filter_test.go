@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestAfterCursor(t *testing.T) {
+	order := []TaskOrder{{Field: SortByPriority}, {Field: SortByDeadline, Desc: true}}
+	task := models.Task{ID: 7, Priority: models.HighPriority}
+
+	cursor := NewTaskCursor(task, order)
+	require.Equal(t, []string{"P1", "9999-12-31T00:00:00Z", "7"}, cursor.Values)
+
+	condition, args, err := afterCursor(append(order, TaskOrder{Field: SortByID}), cursor)
+	require.NoError(t, err)
+
+	deadline := SortByDeadline.expression()
+	require.Equal(t, "((tasks.priority > ?) OR "+
+		"(tasks.priority = ? AND "+deadline+" < ?) OR "+
+		"(tasks.priority = ? AND "+deadline+" = ? AND tasks.id > ?))", condition)
+	require.Equal(t, []interface{}{"P1", "P1", noDeadline, "P1", noDeadline, uint64(7)}, args)
+
+	_, _, err = afterCursor(order, cursor)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+
+	cursor.Values[1] = "yesterday"
+	_, _, err = afterCursor(append(order, TaskOrder{Field: SortByID}), cursor)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestNewTaskCursor(t *testing.T) {
+	created := time.Date(2023, time.June, 1, 9, 30, 0, 123000, time.FixedZone("BST", 3600))
+	task := models.Task{ID: 3, Title: "Write Docs", CreatedAt: created}
+
+	cursor := NewTaskCursor(task, []TaskOrder{{Field: SortByTitle}, {Field: SortByCreated}})
+	require.Equal(t, []string{"write docs", "2023-06-01T08:30:00.000123Z", "3"}, cursor.Values)
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/rank"
+)
+
+var (
+	ErrInvalidNeighbour     = errors.New("after_id and before_id must be other tasks in the status")
+	ErrNeighboursOutOfOrder = errors.New("after_id must come before before_id")
+)
+
+// statusLockKey is paired with the status ID to serialise changes to the order of the
+// status's tasks, so two tasks can't be given the same rank.
+const statusLockKey = 7283044
+
+// TaskPosition is where to put a task in a status, after the task with AfterID and before
+// the one with BeforeID. Either can be left out to put the task directly after or before
+// the other, and both to put it at the end.
+type TaskPosition struct {
+	AfterID  *uint
+	BeforeID *uint
+}
+
+// lockStatusOrder holds the status's order until the transaction ends.
+func lockStatusOrder(tx *gorm.DB, statusID uint) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", statusLockKey, statusID).Error
+}
+
+// assignRank gives a task without a rank one at the end of its status.
+func assignRank(tx *gorm.DB, task *models.Task) error {
+	if task.StatusID == nil || task.Rank != "" {
+		return nil
+	}
+
+	var err error
+	task.Rank, err = rankAtEnd(tx, *task.StatusID, task.ID)
+	return err
+}
+
+// rankAtEnd locks the status's order and returns a rank after its last task, other than
+// the excluded one.
+func rankAtEnd(tx *gorm.DB, statusID uint, excludeID uint) (string, error) {
+	if err := lockStatusOrder(tx, statusID); err != nil {
+		return "", err
+	}
+
+	var ranks []string
+	result := tx.Model(&models.Task{}).
+		Where("status_id = ? AND id <> ?", statusID, excludeID).
+		Order(`rank COLLATE "C" DESC`).
+		Limit(1).
+		Pluck("rank", &ranks)
+	if result.Error != nil || len(ranks) == 0 {
+		return rank.Initial(), result.Error
+	}
+	return rank.After(ranks[0])
+}
+
+// rankAt locks the status's order and returns a rank that puts the task at the position.
+// If a neighbour shares its rank with another task, so there may be no room next to it,
+// the status's ranks are spread out first.
+func rankAt(tx *gorm.DB, statusID uint, taskID uint, position TaskPosition) (string, error) {
+	if position.AfterID == nil && position.BeforeID == nil {
+		return rankAtEnd(tx, statusID, taskID)
+	}
+	if err := lockStatusOrder(tx, statusID); err != nil {
+		return "", err
+	}
+
+	lower, upper, err := neighbourRanks(tx, statusID, taskID, position)
+	if err != nil {
+		return "", err
+	}
+
+	tied, err := hasTiedRanks(tx, statusID, taskID, lower, upper)
+	if err != nil {
+		return "", err
+	}
+	if tied {
+		if err := spreadRanks(tx, statusID); err != nil {
+			return "", err
+		}
+		if lower, upper, err = neighbourRanks(tx, statusID, taskID, position); err != nil {
+			return "", err
+		}
+	}
+
+	newRank, err := rank.Between(lower, upper)
+	if errors.Is(err, rank.ErrInvalidRange) {
+		return "", ErrNeighboursOutOfOrder
+	}
+	return newRank, err
+}
+
+// neighbourRanks returns the ranks the task has to go between to be at the position. An
+// empty rank is the start or end of the status.
+func neighbourRanks(tx *gorm.DB, statusID uint, taskID uint, position TaskPosition) (string, string, error) {
+	neighbour := func(id uint) (string, error) {
+		if id == taskID {
+			return "", ErrInvalidNeighbour
+		}
+		var ranks []string
+		result := tx.Model(&models.Task{}).Where("id = ? AND status_id = ?", id, statusID).Pluck("rank", &ranks)
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if len(ranks) == 0 {
+			return "", ErrInvalidNeighbour
+		}
+		return ranks[0], nil
+	}
+
+	var lower, upper string
+	var err error
+	if position.AfterID != nil {
+		if lower, err = neighbour(*position.AfterID); err != nil {
+			return "", "", err
+		}
+	}
+	if position.BeforeID != nil {
+		if upper, err = neighbour(*position.BeforeID); err != nil {
+			return "", "", err
+		}
+	}
+
+	switch {
+	case position.BeforeID == nil:
+		upper, err = adjacentRank(tx, statusID, lower, true, taskID)
+	case position.AfterID == nil:
+		lower, err = adjacentRank(tx, statusID, upper, false, taskID)
+	}
+	return lower, upper, err
+}
+
+// adjacentRank returns the rank of the task next to the given rank in the status, the
+// one after it if after is set and the one before it otherwise, ignoring the excluded
+// task. An empty rank is returned if there's no task there.
+func adjacentRank(tx *gorm.DB, statusID uint, current string, after bool, excludeID uint) (string, error) {
+	query := tx.Model(&models.Task{}).Where("status_id = ? AND id <> ?", statusID, excludeID)
+	if after {
+		query = query.Where(`rank COLLATE "C" > ?`, current).Order(`rank COLLATE "C"`)
+	} else {
+		query = query.Where(`rank COLLATE "C" < ?`, current).Order(`rank COLLATE "C" DESC`)
+	}
+
+	var ranks []string
+	result := query.Limit(1).Pluck("rank", &ranks)
+	if result.Error != nil || len(ranks) == 0 {
+		return "", result.Error
+	}
+	return ranks[0], nil
+}
+
+// hasTiedRanks returns whether more than one task in the status, other than the excluded
+// one, has any of the ranks.
+func hasTiedRanks(tx *gorm.DB, statusID uint, excludeID uint, ranks ...string) (bool, error) {
+	var tied int64
+	result := tx.Model(&models.Task{}).
+		Where("status_id = ? AND id <> ? AND rank IN ? AND rank <> ''", statusID, excludeID, ranks).
+		Group("rank").
+		Having("COUNT(*) > 1").
+		Count(&tied)
+	return tied > 0, result.Error
+}
+
+// spreadRanks gives every task in the status a new rank, keeping their order and spacing
+// them evenly. Tasks that share a rank are kept in the order they were created.
+func spreadRanks(tx *gorm.DB, statusID uint) error {
+	var ids []uint
+	result := tx.Model(&models.Task{}).
+		Where("status_id = ?", statusID).
+		Order(`rank COLLATE "C", id`).
+		Pluck("id", &ids)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for i, spread := range rank.Spread(len(ids)) {
+		result = tx.Model(&models.Task{}).Where("id = ?", ids[i]).Update("rank", spread)
+		if result.Error != nil {
+			return fmt.Errorf("spreading ranks in status %d: %w", statusID, result.Error)
+		}
+	}
+	return nil
+}
@@ -1,19 +1,34 @@
 package repository
 
 import (
+	"errors"
+
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	"github.com/todanni/api/models"
 )
 
+var (
+	ErrWIPLimitReached = errors.New("status is at its work in progress limit")
+)
+
 type TaskRepository interface {
 	CreateTask(task models.Task) (models.Task, error)
 	GetTaskByID(taskID string) (models.Task, error)
 	UpdateTask(task models.Task) (models.Task, error)
 	DeleteTask(taskID string) error
-	ListTasksByUser(userID string) ([]models.Task, error)
-	ListTasksByProject(projectID string) ([]models.Task, error)
+	ListTasks(filter TaskFilter, page TaskPage) ([]models.Task, bool, error)
+
+	ListRankedTasksByProject(projectID uint) ([]models.Task, error)
+	MoveTask(taskID uint, status models.TaskStatus, position TaskPosition) (models.Task, error)
+
+	ListSubtasks(taskID uint) ([]models.Task, error)
+	TaskDepth(taskID uint) (int, error)
+	SetTaskParent(taskID uint, parentID *uint) error
+	DeleteTaskTree(task models.Task, cascade bool) ([]models.Attachment, error)
+
+	SetTaskTriage(taskID uint, urgent *bool, important *bool) error
 }
 
 type taskRepo struct {
@@ -22,7 +37,7 @@ type taskRepo struct {
 
 func (r *taskRepo) GetTaskByID(taskID string) (models.Task, error) {
 	var task models.Task
-	result := r.db.First(&task, taskID)
+	result := r.db.Preload("Labels").First(&task, taskID)
 	return task, result.Error
 }
 
@@ -31,26 +46,232 @@ func (r *taskRepo) DeleteTask(taskID string) error {
 	return result.Error
 }
 
-func (r *taskRepo) ListTasksByProject(projectID string) ([]models.Task, error) {
+// ListTasks returns a page of the tasks matching the filter, and whether there are more
+// after it. Every task is returned if the page has no limit.
+func (r *taskRepo) ListTasks(filter TaskFilter, page TaskPage) ([]models.Task, bool, error) {
+	query := r.db.Preload("Labels").Scopes(filter.scope)
+
+	// The ID breaks ties, so every task has its own place in the order
+	order := append(append([]TaskOrder{}, page.Order...), TaskOrder{Field: SortByID})
+	if page.After != nil {
+		after, args, err := afterCursor(order, *page.After)
+		if err != nil {
+			return nil, false, err
+		}
+		query = query.Where(after, args...)
+	}
+	for _, o := range order {
+		query = query.Order(o.orderBy())
+	}
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit + 1)
+	}
+
+	var tasks []models.Task
+	result := query.Find(&tasks)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+
+	if page.Limit > 0 && len(tasks) > page.Limit {
+		return tasks[:page.Limit], true, nil
+	}
+	return tasks, false, nil
+}
+
+// CreateTask creates the task, putting it last in its status unless it already has a rank.
+// ErrWIPLimitReached is returned if the status is at its WIP limit.
+func (r *taskRepo) CreateTask(task models.Task) (models.Task, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := placeInStatus(tx, &task); err != nil {
+			return err
+		}
+		return tx.Omit("Labels").Create(&task).Error
+	})
+	return task, err
+}
+
+// UpdateTask saves the task's set fields. A task moved to another status without a new
+// rank goes to the bottom of it. ErrWIPLimitReached is returned if the status it's moved
+// to is at its WIP limit.
+func (r *taskRepo) UpdateTask(task models.Task) (models.Task, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if task.StatusID != nil {
+			var current models.Task
+			result := tx.Select("status_id").First(&current, task.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if current.StatusID == nil || *current.StatusID != *task.StatusID {
+				if err := placeInStatus(tx, &task); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&task).Omit("Labels").Clauses(clause.Returning{}).Updates(task).Error
+	})
+	return task, err
+}
+
+// ListRankedTasksByProject returns the project's tasks in their order on the board.
+// Ranks are compared byte by byte, whatever the database's collation.
+func (r *taskRepo) ListRankedTasksByProject(projectID uint) ([]models.Task, error) {
 	var tasks []models.Task
-	result := r.db.Where("project_id = ?", projectID).Find(&tasks)
+	result := r.db.Preload("Labels").
+		Where("project_id = ?", projectID).
+		Order(`rank COLLATE "C", id`).
+		Find(&tasks)
 	return tasks, result.Error
 }
 
-func (r *taskRepo) ListTasksByUser(userID string) ([]models.Task, error) {
+// MoveTask puts the task in the status at the position in one update, keeping Done in sync.
+// The rank is worked out while the status's order is locked, so concurrent moves can't
+// give two tasks the same rank. ErrWIPLimitReached is returned if the status is at its
+// WIP limit, and ErrInvalidNeighbour or ErrNeighboursOutOfOrder if the position doesn't
+// make sense.
+func (r *taskRepo) MoveTask(taskID uint, status models.TaskStatus, position TaskPosition) (models.Task, error) {
+	task := models.Task{ID: taskID}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkWIPLimit(tx, status.ID, taskID); err != nil {
+			return err
+		}
+
+		newRank, err := rankAt(tx, status.ID, taskID, position)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&task).Clauses(clause.Returning{}).Updates(map[string]interface{}{
+			"status_id": status.ID,
+			"rank":      newRank,
+			"done":      status.Category == models.ClosedCategory,
+		}).Error
+	})
+	return task, err
+}
+
+// placeInStatus checks there's room for a task going into its status, and puts it last in
+// the status unless it already has a rank.
+func placeInStatus(tx *gorm.DB, task *models.Task) error {
+	if task.StatusID == nil {
+		return nil
+	}
+	if err := checkWIPLimit(tx, *task.StatusID, task.ID); err != nil {
+		return err
+	}
+	return assignRank(tx, task)
+}
+
+// checkWIPLimit locks the status and checks it has room for the task under its WIP limit,
+// not counting the task itself. The status stays locked until the transaction ends, so
+// concurrent changes can't take it over the limit. ErrWIPLimitReached is returned if it's full.
+func checkWIPLimit(tx *gorm.DB, statusID uint, taskID uint) error {
+	var status models.TaskStatus
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&status, statusID)
+	if result.Error != nil || status.WIPLimit == nil {
+		return result.Error
+	}
+
+	var count int64
+	result = tx.Model(&models.Task{}).
+		Where("status_id = ? AND id <> ?", statusID, taskID).
+		Count(&count)
+	if result.Error != nil {
+		return result.Error
+	}
+	if count >= int64(*status.WIPLimit) {
+		return ErrWIPLimitReached
+	}
+	return nil
+}
+
+// ListSubtasks returns every task below the task, however deeply nested, in rank order.
+func (r *taskRepo) ListSubtasks(taskID uint) ([]models.Task, error) {
 	var tasks []models.Task
-	result := r.db.Where("created_by = ?", userID).Or("assigned_to = ?", userID).Find(&tasks)
+	result := r.db.Raw(`
+		WITH RECURSIVE subtasks AS (
+			SELECT * FROM tasks WHERE parent_id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT tasks.* FROM tasks JOIN subtasks ON tasks.parent_id = subtasks.id
+			WHERE tasks.deleted_at IS NULL
+		)
+		SELECT * FROM subtasks ORDER BY rank COLLATE "C", id`, taskID).
+		Scan(&tasks)
 	return tasks, result.Error
 }
 
-func (r *taskRepo) CreateTask(task models.Task) (models.Task, error) {
-	result := r.db.Create(&task)
-	return task, result.Error
+// TaskDepth returns how many parents the task has above it, 0 for a top level task.
+func (r *taskRepo) TaskDepth(taskID uint) (int, error) {
+	var depth int
+	result := r.db.Raw(`
+		WITH RECURSIVE parents AS (
+			SELECT id, parent_id, 0 AS depth FROM tasks WHERE id = ?
+			UNION ALL
+			SELECT tasks.id, tasks.parent_id, parents.depth + 1 FROM tasks
+			JOIN parents ON tasks.id = parents.parent_id
+			WHERE tasks.deleted_at IS NULL
+		)
+		SELECT MAX(depth) FROM parents`, taskID).
+		Scan(&depth)
+	return depth, result.Error
 }
 
-func (r *taskRepo) UpdateTask(task models.Task) (models.Task, error) {
-	result := r.db.Model(&task).Clauses(clause.Returning{}).Updates(task)
-	return task, result.Error
+// SetTaskParent makes the task a subtask of the parent, or a top level task if it's nil.
+func (r *taskRepo) SetTaskParent(taskID uint, parentID *uint) error {
+	return r.db.Model(&models.Task{ID: taskID}).Update("parent_id", parentID).Error
+}
+
+// SetTaskTriage sets whether the task is urgent and important. Either can be nil to work
+// it out from the task's deadline or priority.
+func (r *taskRepo) SetTaskTriage(taskID uint, urgent *bool, important *bool) error {
+	return r.db.Model(&models.Task{ID: taskID}).Updates(map[string]interface{}{
+		"urgent":    urgent,
+		"important": important,
+	}).Error
+}
+
+// DeleteTaskTree deletes the task. If cascade is set its subtasks are deleted with it,
+// otherwise its direct subtasks move up to the task's parent. The attachments of the
+// deleted tasks are removed too, and returned so their files can be deleted.
+func (r *taskRepo) DeleteTaskTree(task models.Task, cascade bool) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		deleted := []uint{task.ID}
+		if cascade {
+			var subtaskIDs []uint
+			result := tx.Raw(`
+				WITH RECURSIVE subtasks AS (
+					SELECT id FROM tasks WHERE parent_id = ? AND deleted_at IS NULL
+					UNION ALL
+					SELECT tasks.id FROM tasks JOIN subtasks ON tasks.parent_id = subtasks.id
+					WHERE tasks.deleted_at IS NULL
+				)
+				UPDATE tasks SET deleted_at = NOW() WHERE id IN (SELECT id FROM subtasks)
+				RETURNING id`, task.ID).
+				Scan(&subtaskIDs)
+			if result.Error != nil {
+				return result.Error
+			}
+			deleted = append(deleted, subtaskIDs...)
+		} else {
+			result := tx.Model(&models.Task{}).
+				Where("parent_id = ?", task.ID).
+				Update("parent_id", task.ParentID)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		result := tx.Clauses(clause.Returning{}).
+			Where("task_id IN ?", deleted).
+			Delete(&attachments)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Delete(&models.Task{}, task.ID).Error
+	})
+	return attachments, err
 }
 
 func NewTaskRepository(db *gorm.DB) TaskRepository {
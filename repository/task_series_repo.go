@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/todanni/api/models"
+)
+
+var (
+	ErrInstanceExists = errors.New("the series already has an instance at or after this deadline")
+)
+
+type TaskSeriesRepository interface {
+	CreateTaskSeries(series models.TaskSeries, task models.Task) (models.TaskSeries, models.Task, error)
+	GetTaskSeries(seriesID uint) (models.TaskSeries, error)
+	UpdateTaskSeries(series models.TaskSeries) (models.TaskSeries, error)
+	SplitTaskSeries(series models.TaskSeries, from models.Task, next models.TaskSeries) (models.TaskSeries, error)
+	EndTaskSeries(seriesID uint) error
+	ListDueTaskSeries(now time.Time) ([]models.TaskSeries, error)
+
+	LatestSeriesInstance(seriesID uint) (models.Task, error)
+	IsFirstSeriesInstance(task models.Task) (bool, error)
+	CreateSeriesInstance(from models.Task, next models.Task) (models.Task, error)
+	SetRecurrenceOverride(taskID uint, rule *string) error
+}
+
+type taskSeriesRepo struct {
+	db *gorm.DB
+}
+
+func NewTaskSeriesRepository(db *gorm.DB) TaskSeriesRepository {
+	return &taskSeriesRepo{
+		db: db,
+	}
+}
+
+// CreateTaskSeries creates the series and makes the task its first instance. The task is
+// created too if it doesn't have an ID yet, and ErrWIPLimitReached is returned if its
+// status is at its WIP limit.
+func (r *taskSeriesRepo) CreateTaskSeries(series models.TaskSeries, task models.Task) (models.TaskSeries, models.Task, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Create(&series)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		task.SeriesID = &series.ID
+		if task.ID == 0 {
+			if err := placeInStatus(tx, &task); err != nil {
+				return err
+			}
+			return tx.Create(&task).Error
+		}
+		return tx.Model(&task).Update("series_id", series.ID).Error
+	})
+	return series, task, err
+}
+
+func (r *taskSeriesRepo) GetTaskSeries(seriesID uint) (models.TaskSeries, error) {
+	var series models.TaskSeries
+	result := r.db.First(&series, seriesID)
+	return series, result.Error
+}
+
+func (r *taskSeriesRepo) UpdateTaskSeries(series models.TaskSeries) (models.TaskSeries, error) {
+	result := r.db.Model(&series).
+		Select("Rule", "TimeZone", "Start", "Trigger").
+		Updates(series)
+	return series, result.Error
+}
+
+// SplitTaskSeries ends the series and starts the next one from the given instance, which
+// moves to the new series along with any instances after it.
+func (r *taskSeriesRepo) SplitTaskSeries(series models.TaskSeries, from models.Task, next models.TaskSeries) (models.TaskSeries, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&series).Update("ended_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Create(&next)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&models.Task{}).
+			Where("series_id = ? AND (deadline >= ? OR id = ?)", series.ID, from.Deadline, from.ID).
+			Updates(map[string]interface{}{"series_id": next.ID, "recurrence_override": nil}).Error
+	})
+	return next, err
+}
+
+// EndTaskSeries stops the series creating instances. Existing instances are kept.
+func (r *taskSeriesRepo) EndTaskSeries(seriesID uint) error {
+	return r.db.Model(&models.TaskSeries{}).
+		Where("id = ? AND ended_at IS NULL", seriesID).
+		Update("ended_at", time.Now()).Error
+}
+
+// ListDueTaskSeries returns the scheduled series whose latest instance's deadline has passed.
+func (r *taskSeriesRepo) ListDueTaskSeries(now time.Time) ([]models.TaskSeries, error) {
+	var series []models.TaskSeries
+	result := r.db.
+		Where("trigger = ? AND ended_at IS NULL", models.ScheduleTrigger).
+		Where("(SELECT MAX(deadline) FROM tasks WHERE tasks.series_id = task_series.id AND tasks.deleted_at IS NULL) <= ?", now).
+		Find(&series)
+	return series, result.Error
+}
+
+func (r *taskSeriesRepo) LatestSeriesInstance(seriesID uint) (models.Task, error) {
+	var task models.Task
+	result := r.db.
+		Where("series_id = ?", seriesID).
+		Order("deadline DESC, id DESC").
+		First(&task)
+	return task, result.Error
+}
+
+// IsFirstSeriesInstance returns whether no instance of the task's series comes before it.
+func (r *taskSeriesRepo) IsFirstSeriesInstance(task models.Task) (bool, error) {
+	var count int64
+	result := r.db.Model(&models.Task{}).
+		Where("series_id = ? AND deadline < ?", task.SeriesID, task.Deadline).
+		Count(&count)
+	return count == 0, result.Error
+}
+
+// CreateSeriesInstance creates the next instance of the series with the previous instance's
+// labels, along with a copy of its checklist with nothing ticked off. The series is locked while it's
+// checked for an existing instance, so completing a task while the scheduler runs can't
+// create it twice. ErrInstanceExists is returned if it's already there, and
+// ErrWIPLimitReached if the instance's status is at its WIP limit.
+func (r *taskSeriesRepo) CreateSeriesInstance(from models.Task, next models.Task) (models.Task, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.TaskSeries{}, next.SeriesID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var count int64
+		result = tx.Model(&models.Task{}).
+			Where("series_id = ? AND deadline >= ?", next.SeriesID, next.Deadline).
+			Count(&count)
+		if result.Error != nil {
+			return result.Error
+		}
+		if count > 0 {
+			return ErrInstanceExists
+		}
+
+		if err := placeInStatus(tx, &next); err != nil {
+			return err
+		}
+		result = tx.Omit("Labels").Create(&next)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Exec(`INSERT INTO task_labels (task_id, label_id)
+			SELECT ?, label_id FROM task_labels WHERE task_id = ?`, next.ID, from.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var items []models.ChecklistItem
+		result = tx.Where("task_id = ?", from.ID).Order("position, id").Find(&items)
+		if result.Error != nil || len(items) == 0 {
+			return result.Error
+		}
+		for i := range items {
+			items[i] = models.ChecklistItem{
+				TaskID:   next.ID,
+				Text:     items[i].Text,
+				Position: items[i].Position,
+			}
+		}
+		return tx.Create(&items).Error
+	})
+	return next, err
+}
+
+func (r *taskSeriesRepo) SetRecurrenceOverride(taskID uint, rule *string) error {
+	return r.db.Model(&models.Task{ID: taskID}).Update("recurrence_override", rule).Error
+}
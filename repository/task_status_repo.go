@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+type TaskStatusRepository interface {
+	ListTaskStatuses(projectID uint) ([]models.TaskStatus, error)
+	GetTaskStatusByID(statusID uint) (models.TaskStatus, error)
+	CreateTaskStatus(status models.TaskStatus) (models.TaskStatus, error)
+	UpdateTaskStatus(status models.TaskStatus) (models.TaskStatus, error)
+	DeleteTaskStatus(status models.TaskStatus, moveTo models.TaskStatus) error
+
+	ListTaskStatusTransitions(projectID uint) ([]models.TaskStatusTransition, error)
+	ReplaceTaskStatusTransitions(projectID uint, transitions []models.TaskStatusTransition) error
+	IsTransitionAllowed(projectID uint, fromStatusID uint, toStatusID uint) (bool, error)
+}
+
+type taskStatusRepo struct {
+	db *gorm.DB
+}
+
+func NewTaskStatusRepository(db *gorm.DB) TaskStatusRepository {
+	return &taskStatusRepo{
+		db: db,
+	}
+}
+
+func (r *taskStatusRepo) ListTaskStatuses(projectID uint) ([]models.TaskStatus, error) {
+	var statuses []models.TaskStatus
+	result := r.db.
+		Where("project_id = ?", projectID).
+		Order("position, id").
+		Find(&statuses)
+	return statuses, result.Error
+}
+
+func (r *taskStatusRepo) GetTaskStatusByID(statusID uint) (models.TaskStatus, error) {
+	var status models.TaskStatus
+	result := r.db.First(&status, statusID)
+	return status, result.Error
+}
+
+func (r *taskStatusRepo) CreateTaskStatus(status models.TaskStatus) (models.TaskStatus, error) {
+	result := r.db.Create(&status)
+	return status, result.Error
+}
+
+// UpdateTaskStatus updates the status' name, position, category and WIP limit. If the category
+// changes, Done is updated on the tasks in the status to match.
+func (r *taskStatusRepo) UpdateTaskStatus(status models.TaskStatus) (models.TaskStatus, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&status).
+			Select("Name", "Position", "Category", "WIPLimit").
+			Updates(status)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&models.Task{}).
+			Where("status_id = ?", status.ID).
+			Update("done", status.Category == models.ClosedCategory).Error
+	})
+	return status, err
+}
+
+// DeleteTaskStatus deletes the status along with any transitions to or from it,
+// moving its tasks to another status of the same project.
+func (r *taskStatusRepo) DeleteTaskStatus(status models.TaskStatus, moveTo models.TaskStatus) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Task{}).
+			Where("status_id = ?", status.ID).
+			Updates(map[string]interface{}{
+				"status_id": moveTo.ID,
+				"done":      moveTo.Category == models.ClosedCategory,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.
+			Where("from_status_id = ? OR to_status_id = ?", status.ID, status.ID).
+			Delete(&models.TaskStatusTransition{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Delete(&status).Error
+	})
+}
+
+func (r *taskStatusRepo) ListTaskStatusTransitions(projectID uint) ([]models.TaskStatusTransition, error) {
+	var transitions []models.TaskStatusTransition
+	result := r.db.
+		Where("project_id = ?", projectID).
+		Order("from_status_id, to_status_id").
+		Find(&transitions)
+	return transitions, result.Error
+}
+
+// ReplaceTaskStatusTransitions replaces all of the project's transitions. An empty
+// list lets tasks move freely between statuses again.
+func (r *taskStatusRepo) ReplaceTaskStatusTransitions(projectID uint, transitions []models.TaskStatusTransition) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("project_id = ?", projectID).Delete(&models.TaskStatusTransition{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if len(transitions) == 0 {
+			return nil
+		}
+		return tx.Create(&transitions).Error
+	})
+}
+
+// IsTransitionAllowed returns whether a task can move between the statuses. Moves
+// from a status with no transitions configured are always allowed.
+func (r *taskStatusRepo) IsTransitionAllowed(projectID uint, fromStatusID uint, toStatusID uint) (bool, error) {
+	var transitions []models.TaskStatusTransition
+	result := r.db.
+		Where("project_id = ? AND from_status_id = ?", projectID, fromStatusID).
+		Find(&transitions)
+	if result.Error != nil || len(transitions) == 0 {
+		return result.Error == nil, result.Error
+	}
+
+	for _, transition := range transitions {
+		if transition.ToStatusID == toStatusID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/todanni/api/models"
+)
+
+type TodayRepository interface {
+	ListTodayTasks(userID string, day string) ([]models.Task, error)
+	AddTodayTask(userID string, day string, taskID uint) error
+	RemoveTodayTask(userID string, day string, taskID uint) error
+}
+
+type todayRepo struct {
+	db *gorm.DB
+}
+
+func NewTodayRepository(db *gorm.DB) TodayRepository {
+	return &todayRepo{
+		db: db,
+	}
+}
+
+// ListTodayTasks returns the tasks the user picked for the day in the order they were
+// picked, leaving out any in projects they're no longer a member of.
+func (r *todayRepo) ListTodayTasks(userID string, day string) ([]models.Task, error) {
+	var tasks []models.Task
+	result := r.db.Preload("Labels").
+		Joins("JOIN today_tasks ON today_tasks.task_id = tasks.id").
+		Where("today_tasks.user_id = ? AND today_tasks.day = ?", userID, day).
+		Where("tasks.project_id IN (SELECT project_id FROM user_projects WHERE user_id = ?)", userID).
+		Order("today_tasks.position, today_tasks.created_at").
+		Find(&tasks)
+	return tasks, result.Error
+}
+
+// AddTodayTask puts the task at the end of the user's list for the day, clearing out the
+// picks from other days. Adding a task that's already on the list leaves it where it is.
+func (r *todayRepo) AddTodayTask(userID string, day string, taskID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND day <> ?", userID, day).Delete(&models.TodayTask{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var last int
+		result = tx.Model(&models.TodayTask{}).
+			Where("user_id = ? AND day = ?", userID, day).
+			Select("COALESCE(MAX(position), 0)").
+			Scan(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.TodayTask{
+			UserID:   userID,
+			TaskID:   taskID,
+			Day:      day,
+			Position: last + 1,
+		}).Error
+	})
+}
+
+func (r *todayRepo) RemoveTodayTask(userID string, day string, taskID uint) error {
+	return r.db.Where("user_id = ? AND day = ? AND task_id = ?", userID, day, taskID).
+		Delete(&models.TodayTask{}).Error
+}
@@ -10,6 +10,10 @@ type UserRepository interface {
 	CreateUser(user models.User) (models.User, error)
 	GetUserByEmail(email string) (models.User, error)
 	GetUserByID(id string) (models.User, error)
+	UpdateUser(user models.User) (models.User, error)
+
+	GetUserByIdentity(provider, subject string) (models.User, error)
+	CreateIdentity(identity models.Identity) (models.Identity, error)
 }
 
 type userRepo struct {
@@ -38,3 +42,25 @@ func (r *userRepo) GetUserByID(id string) (models.User, error) {
 	result := r.db.Raw("SELECT * FROM users WHERE id = ?", id).Scan(&user)
 	return user, result.Error
 }
+
+// UpdateUser updates the user's display name and time zone.
+func (r *userRepo) UpdateUser(user models.User) (models.User, error) {
+	result := r.db.Model(&user).
+		Select("DisplayName", "TimeZone").
+		Updates(user)
+	return user, result.Error
+}
+
+func (r *userRepo) GetUserByIdentity(provider, subject string) (models.User, error) {
+	var user models.User
+	result := r.db.
+		Joins("INNER JOIN identities i ON i.user_id = users.id").
+		Where("i.provider = ? AND i.subject = ?", provider, subject).
+		First(&user)
+	return user, result.Error
+}
+
+func (r *userRepo) CreateIdentity(identity models.Identity) (models.Identity, error) {
+	result := r.db.Create(&identity)
+	return identity, result.Error
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	OAuthStateCookieName = "todanni-oauth-state"
+)
+
+var (
+	// OAuthStateExpirationTime is how long a user has to complete the provider's login
+	OAuthStateExpirationTime = 10 * time.Minute
+
+	ErrInvalidOAuthState = errors.New("invalid oauth state")
+	ErrExpiredOAuthState = errors.New("oauth state has expired")
+)
+
+// oauthState is what's kept in the state cookie between starting a login and the
+// provider calling back: the provider, the state parameter sent to it and the PKCE
+// code verifier, whose challenge was sent with it.
+type oauthState struct {
+	Provider  string `json:"provider"`
+	State     string `json:"state"`
+	Verifier  string `json:"verifier"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+func newOAuthState(provider string, now time.Time) (oauthState, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return oauthState{}, err
+	}
+
+	verifier, err := randomString(32)
+	if err != nil {
+		return oauthState{}, err
+	}
+
+	return oauthState{
+		Provider:  provider,
+		State:     state,
+		Verifier:  verifier,
+		ExpiresAt: now.Add(OAuthStateExpirationTime).Unix(),
+	}, nil
+}
+
+// codeChallenge returns the S256 PKCE challenge for the state's verifier.
+func (o oauthState) codeChallenge() string {
+	sum := sha256.Sum256([]byte(o.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// matches reports whether the callback is from the provider the login was started
+// with and the state parameter it returned is the one that was sent.
+func (o oauthState) matches(provider, state string) bool {
+	return o.Provider == provider && subtle.ConstantTimeCompare([]byte(o.State), []byte(state)) == 1
+}
+
+// signOAuthState encodes the state as a cookie value signed with the signing key.
+func signOAuthState(o oauthState, signingKey string) (string, error) {
+	payload, err := json.Marshal(o)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + oauthStateSignature(encoded, signingKey), nil
+}
+
+// verifyOAuthState checks the signature and expiry of a state cookie value and decodes it.
+func verifyOAuthState(value, signingKey string, now time.Time) (oauthState, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return oauthState{}, ErrInvalidOAuthState
+	}
+
+	expected := oauthStateSignature(parts[0], signingKey)
+	if !hmac.Equal([]byte(expected), []byte(parts[1])) {
+		return oauthState{}, ErrInvalidOAuthState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return oauthState{}, ErrInvalidOAuthState
+	}
+
+	var o oauthState
+	err = json.Unmarshal(payload, &o)
+	if err != nil {
+		return oauthState{}, ErrInvalidOAuthState
+	}
+
+	if now.Unix() > o.ExpiresAt {
+		return oauthState{}, ErrExpiredOAuthState
+	}
+	return o, nil
+}
+
+func oauthStateSignature(encoded, signingKey string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomString(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	signingKey = "examplesigningkey"
+)
+
+func TestOAuthState_RoundTrip(t *testing.T) {
+	now := time.Now()
+	state, err := newOAuthState("google", now)
+	require.NoError(t, err)
+
+	cookieValue, err := signOAuthState(state, signingKey)
+	require.NoError(t, err)
+
+	verified, err := verifyOAuthState(cookieValue, signingKey, now)
+	require.NoError(t, err)
+	require.Equal(t, state, verified)
+	require.True(t, verified.matches("google", state.State))
+	require.False(t, verified.matches("google", "some other state"))
+	require.False(t, verified.matches("github", state.State))
+}
+
+func TestOAuthState_Tampered(t *testing.T) {
+	now := time.Now()
+	state, err := newOAuthState("google", now)
+	require.NoError(t, err)
+
+	cookieValue, err := signOAuthState(state, "adifferentkey")
+	require.NoError(t, err)
+
+	_, err = verifyOAuthState(cookieValue, signingKey, now)
+	require.ErrorIs(t, err, ErrInvalidOAuthState)
+
+	_, err = verifyOAuthState("notastatecookie", signingKey, now)
+	require.ErrorIs(t, err, ErrInvalidOAuthState)
+}
+
+func TestOAuthState_Expired(t *testing.T) {
+	now := time.Now()
+	state, err := newOAuthState("google", now)
+	require.NoError(t, err)
+
+	cookieValue, err := signOAuthState(state, signingKey)
+	require.NoError(t, err)
+
+	_, err = verifyOAuthState(cookieValue, signingKey, now.Add(OAuthStateExpirationTime+time.Second))
+	require.ErrorIs(t, err, ErrExpiredOAuthState)
+}
+
+func TestOAuthState_CodeChallenge(t *testing.T) {
+	state := oauthState{Verifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"}
+
+	// Example from RFC 7636, appendix B
+	require.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", state.codeChallenge())
+
+	sum := sha256.Sum256([]byte(state.Verifier))
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), state.codeChallenge())
+}
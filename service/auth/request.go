@@ -1,7 +1,53 @@
 package auth
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/todanni/api/models"
+)
+
 type GetUserResponse struct {
 	ID          string `json:"id"`
 	DisplayName string `json:"display_name"`
 	ProfilePic  string `json:"profile_pic"`
+	TimeZone    string `json:"time_zone"`
+}
+
+// UpdateUserRequest changes the caller's profile. TimeZone is an IANA name like
+// Europe/London, used for deadlines of recurring tasks.
+type UpdateUserRequest struct {
+	DisplayName string  `json:"display_name"`
+	TimeZone    *string `json:"time_zone"`
+}
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+type CreatePersonalAccessTokenRequest struct {
+	Name  string            `json:"name"`
+	Scope models.TokenScope `json:"scope"`
+	// ProjectIDs limits the token to these projects. If empty, it can access all of the user's projects.
+	ProjectIDs []uint     `json:"project_ids"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type PersonalAccessTokenResponse struct {
+	ID         uint              `json:"id"`
+	Name       string            `json:"name"`
+	Prefix     string            `json:"prefix"`
+	Scope      models.TokenScope `json:"scope"`
+	ProjectIDs []uint            `json:"project_ids"`
+	ExpiresAt  *time.Time        `json:"expires_at"`
+	LastUsedAt *time.Time        `json:"last_used_at"`
+	CreatedAt  time.Time         `json:"created_at"`
+	// Token is only returned when the token is created
+	Token string `json:"token,omitempty"`
 }
@@ -3,14 +3,43 @@ package auth
 import "net/http"
 
 const (
+	LoginHandler    = "/auth/login"
 	CallbackHandler = "/auth/callback"
+
+	ProviderLoginHandler    = "/auth/{provider}/login"
+	ProviderCallbackHandler = "/auth/{provider}/callback"
+
+	RefreshHandler  = "/auth/refresh"
+	LogoutHandler   = "/auth/logout"
+	SessionsHandler = "/auth/sessions"
+	TokensHandler   = "/auth/tokens"
 	GetUserHandler  = "/user"
+
+	JWKSHandler = "/.well-known/jwks.json"
 )
 
 func (s *authService) routes() {
+	s.router.HandleFunc(LoginHandler, s.LoginHandler).Methods(http.MethodGet)
 	s.router.HandleFunc(CallbackHandler, s.CallbackHandler)
+	s.router.HandleFunc(ProviderLoginHandler, s.LoginHandler).Methods(http.MethodGet)
+	s.router.HandleFunc(ProviderCallbackHandler, s.CallbackHandler)
+	s.router.HandleFunc(RefreshHandler, s.RefreshHandler).Methods(http.MethodPost)
+	s.router.HandleFunc(LogoutHandler, s.LogoutHandler).Methods(http.MethodPost)
+	s.router.HandleFunc(JWKSHandler, s.JWKSHandler).Methods(http.MethodGet)
+
+	sessions := s.router.PathPrefix(SessionsHandler).Subrouter()
+	sessions.Use(s.middleware.JwtMiddleware)
+	sessions.HandleFunc("/", s.ListSessionsHandler).Methods(http.MethodGet)
+	sessions.HandleFunc("/{id}", s.RevokeSessionHandler).Methods(http.MethodDelete)
+
+	tokens := s.router.PathPrefix(TokensHandler).Subrouter()
+	tokens.Use(s.middleware.JwtMiddleware)
+	tokens.HandleFunc("/", s.ListPersonalAccessTokensHandler).Methods(http.MethodGet)
+	tokens.HandleFunc("/", s.CreatePersonalAccessTokenHandler).Methods(http.MethodPost)
+	tokens.HandleFunc("/{id:[0-9]+}", s.RevokePersonalAccessTokenHandler).Methods(http.MethodDelete)
 
 	r := s.router.PathPrefix(GetUserHandler).Subrouter()
 	r.Use(s.middleware.JwtMiddleware)
 	r.HandleFunc("/{id}", s.GetUserHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id}", s.UpdateUserHandler).Methods(http.MethodPatch)
 }
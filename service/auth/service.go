@@ -4,7 +4,9 @@ import (
 	"context"
 	b64 "encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,29 +14,46 @@ import (
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	scopes "google.golang.org/api/oauth2/v2"
 	"gorm.io/gorm"
 
 	"github.com/todanni/api/config"
+	"github.com/todanni/api/identity"
 	"github.com/todanni/api/models"
 	"github.com/todanni/api/repository"
 	"github.com/todanni/api/token"
 )
 
+var (
+	ErrEmailNotVerified = errors.New("email address not verified by identity provider")
+)
+
 type AuthService interface {
+	LoginHandler(w http.ResponseWriter, r *http.Request)
 	CallbackHandler(w http.ResponseWriter, r *http.Request)
 	GetUserHandler(w http.ResponseWriter, r *http.Request)
+	UpdateUserHandler(w http.ResponseWriter, r *http.Request)
+	RefreshHandler(w http.ResponseWriter, r *http.Request)
+	LogoutHandler(w http.ResponseWriter, r *http.Request)
+	ListSessionsHandler(w http.ResponseWriter, r *http.Request)
+	RevokeSessionHandler(w http.ResponseWriter, r *http.Request)
+	CreatePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request)
+	ListPersonalAccessTokensHandler(w http.ResponseWriter, r *http.Request)
+	RevokePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request)
+	JWKSHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type authService struct {
-	router        *mux.Router
-	userRepo      repository.UserRepository
-	dashboardRepo repository.DashboardRepository
-	projectRepo   repository.ProjectRepository
-	middleware    token.AuthMiddleware
-	config        config.Config
-	oauthConfig   *oauth2.Config
+	router            *mux.Router
+	userRepo          repository.UserRepository
+	dashboardRepo     repository.DashboardRepository
+	projectRepo       repository.ProjectRepository
+	refreshRepo       repository.RefreshTokenRepository
+	sessionRepo       repository.SessionRepository
+	personalTokenRepo repository.PersonalAccessTokenRepository
+	keys              *token.KeySet
+	middleware        token.AuthMiddleware
+	config            config.Config
+	providers         map[string]identity.IdentityProvider
 }
 
 func NewAuthService(
@@ -43,29 +62,79 @@ func NewAuthService(
 	userRepo repository.UserRepository,
 	dashboardRepo repository.DashboardRepository,
 	projectRepo repository.ProjectRepository,
+	refreshRepo repository.RefreshTokenRepository,
+	sessionRepo repository.SessionRepository,
+	personalTokenRepo repository.PersonalAccessTokenRepository,
+	keys *token.KeySet,
 	mw token.AuthMiddleware,
 ) AuthService {
 	server := &authService{
-		config:        cfg,
-		router:        router,
-		userRepo:      userRepo,
-		dashboardRepo: dashboardRepo,
-		projectRepo:   projectRepo,
-		middleware:    mw,
+		config:            cfg,
+		router:            router,
+		userRepo:          userRepo,
+		dashboardRepo:     dashboardRepo,
+		projectRepo:       projectRepo,
+		refreshRepo:       refreshRepo,
+		sessionRepo:       sessionRepo,
+		personalTokenRepo: personalTokenRepo,
+		keys:              keys,
+		middleware:        mw,
 	}
 	server.routes()
-	server.createOAuthConfig()
+	server.createProviders()
 	return server
 }
 
-func (s *authService) createOAuthConfig() {
+// createProviders sets up Google, which is always available, along with
+// GitHub and a generic OpenID Connect provider if they're configured.
+func (s *authService) createProviders() {
+	s.providers = make(map[string]identity.IdentityProvider)
+
 	decodedCredentials, err := b64.StdEncoding.DecodeString(s.config.GoogleCredentials)
+	if err != nil {
+		log.Fatalf("Unable to decode google credentials: %v", err)
+	}
 
-	oauthConfig, err := google.ConfigFromJSON(decodedCredentials, scopes.OpenIDScope, scopes.UserinfoEmailScope, scopes.UserinfoProfileScope)
+	google, err := identity.NewGoogleProvider(decodedCredentials)
 	if err != nil {
 		log.Fatalf("Unable to parse client secret file to oauthConfig: %v", err)
 	}
-	s.oauthConfig = oauthConfig
+	s.providers[google.Name()] = google
+
+	if s.config.GitHubClientID != "" {
+		github := identity.NewGitHubProvider(s.config.GitHubClientID, s.config.GitHubClientSecret,
+			s.callbackURL(identity.GitHubProviderName))
+		s.providers[github.Name()] = github
+	}
+
+	if s.config.OIDCIssuerURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		oidc, err := identity.NewOIDCProvider(ctx, s.config.OIDCProviderName, s.config.OIDCIssuerURL,
+			s.config.OIDCClientID, s.config.OIDCClientSecret, s.callbackURL(s.config.OIDCProviderName))
+		if err != nil {
+			log.Errorf("Couldn't set up %s identity provider: %v", s.config.OIDCProviderName, err)
+			return
+		}
+		s.providers[oidc.Name()] = oidc
+	}
+}
+
+func (s *authService) callbackURL(provider string) string {
+	return strings.TrimSuffix(s.config.APIURL, "/") + "/auth/" + provider + "/callback"
+}
+
+// provider returns the identity provider named in the request path, defaulting to
+// Google for the original /auth/login and /auth/callback routes.
+func (s *authService) provider(r *http.Request) (identity.IdentityProvider, bool) {
+	name, ok := mux.Vars(r)["provider"]
+	if !ok {
+		name = identity.GoogleProviderName
+	}
+
+	provider, ok := s.providers[name]
+	return provider, ok
 }
 
 func (s *authService) GetUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -83,6 +152,7 @@ func (s *authService) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 		ID:          user.ID,
 		DisplayName: user.DisplayName,
 		ProfilePic:  user.ProfilePic,
+		TimeZone:    user.TimeZone,
 	}
 
 	responseBody, err := json.Marshal(response)
@@ -95,15 +165,158 @@ func (s *authService) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(responseBody)
 }
 
+// UpdateUserHandler lets users change their own display name and time zone.
+func (s *authService) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	if accessToken.GetUserID() == "" || accessToken.GetUserID() != params["id"] {
+		http.Error(w, "you can only update your own profile", http.StatusForbidden)
+		return
+	}
+
+	var updateRequest UpdateUserRequest
+	err := json.NewDecoder(r.Body).Decode(&updateRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.userRepo.GetUserByID(params["id"])
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if updateRequest.DisplayName != "" {
+		user.DisplayName = updateRequest.DisplayName
+	}
+	if updateRequest.TimeZone != nil {
+		if _, err = time.LoadLocation(*updateRequest.TimeZone); err != nil {
+			http.Error(w, "unknown time zone", http.StatusBadRequest)
+			return
+		}
+		user.TimeZone = *updateRequest.TimeZone
+	}
+
+	user, err = s.userRepo.UpdateUser(user)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update user", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(GetUserResponse{
+		ID:          user.ID,
+		DisplayName: user.DisplayName,
+		ProfilePic:  user.ProfilePic,
+		TimeZone:    user.TimeZone,
+	})
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// JWKSHandler publishes the public keys access tokens can be verified with,
+// so other services don't need to hold the signing key.
+func (s *authService) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	responseBody, err := json.Marshal(s.keys)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Cache-Control", "public, max-age=300")
+	w.Write(responseBody)
+}
+
+// LoginHandler starts the OAuth login by redirecting to the provider. The state and PKCE
+// verifier it generates are kept in a short-lived signed cookie for the callback to check.
+func (s *authService) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.provider(r)
+	if !ok {
+		http.Error(w, "unknown identity provider", http.StatusNotFound)
+		return
+	}
+
+	state, err := newOAuthState(provider.Name(), time.Now())
+	if err != nil {
+		log.Errorf("Couldn't generate oauth state: %v", err)
+		http.Error(w, "couldn't start login", http.StatusInternalServerError)
+		return
+	}
+
+	cookieValue, err := signOAuthState(state, s.config.SigningKey)
+	if err != nil {
+		log.Errorf("Couldn't sign oauth state: %v", err)
+		http.Error(w, "couldn't start login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OAuthStateCookieName,
+		Value:    cookieValue,
+		Path:     "/auth",
+		HttpOnly: true,
+		Domain:   s.config.Domain,
+		MaxAge:   int(OAuthStateExpirationTime.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authURL := provider.AuthCodeURL(state.State,
+		oauth2.SetAuthURLParam("code_challenge", state.codeChallenge()),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
 func (s *authService) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	log.Info("Received callback request")
 	ctx := context.Background()
 
-	code := r.URL.Query().Get("code")
-	log.Info(s.oauthConfig)
-	log.Info(s.oauthConfig.RedirectURL)
+	provider, ok := s.provider(r)
+	if !ok {
+		http.Error(w, "unknown identity provider", http.StatusNotFound)
+		return
+	}
+
+	// Check the callback belongs to a login started by this browser
+	stateCookie, err := r.Cookie(OAuthStateCookieName)
+	if err != nil {
+		http.Error(w, "login state not present", http.StatusBadRequest)
+		return
+	}
+
+	state, err := verifyOAuthState(stateCookie.Value, s.config.SigningKey, time.Now())
+	if err != nil {
+		log.Errorf("Couldn't verify oauth state: %v", err)
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	if !state.matches(provider.Name(), r.URL.Query().Get("state")) {
+		http.Error(w, "login state doesn't match", http.StatusBadRequest)
+		return
+	}
+
+	// The state can only be used once
+	http.SetCookie(w, &http.Cookie{
+		Name:     OAuthStateCookieName,
+		Value:    "",
+		Path:     "/auth",
+		HttpOnly: true,
+		Domain:   s.config.Domain,
+		MaxAge:   -1,
+	})
 
-	tok, err := s.oauthConfig.Exchange(ctx, code)
+	code := r.URL.Query().Get("code")
+	tok, err := provider.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", state.Verifier))
 	if err != nil {
 		log.Errorf("Couldn't exchange keys: %v", err)
 		http.Error(w, "couldn't exchange keys for code", http.StatusInternalServerError)
@@ -111,54 +324,186 @@ func (s *authService) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// use the token to request the details about the user
-	userInfo, err := s.getUserInfo(tok.AccessToken)
+	userInfo, err := provider.UserInfo(ctx, tok)
 	if err != nil {
-		log.Errorf("couldn't get user info from google: %v", err)
+		log.Errorf("couldn't get user info from %s: %v", provider.Name(), err)
 		http.Error(w, "couldn't get user info", http.StatusInternalServerError)
+		return
 	}
 
-	// Check if user exists
-	userRecord, err := s.userRepo.GetUserByEmail(userInfo.Email)
-	switch err {
-	case gorm.ErrRecordNotFound:
-		userRecord, err = s.userRepo.CreateUser(s.generateNewUserRecord(userInfo.Email, userInfo.ProfilePic))
-		if err != nil {
-			log.Errorf("Couldn't create user: %v", err)
-			http.Error(w, "couldn't create new user", http.StatusInternalServerError)
-			return
-		}
-	case nil:
-		break
-	default:
-		log.Errorf("Couldn't check if user exists: %v", err)
+	userRecord, err := s.findOrCreateUser(provider.Name(), userInfo)
+	if errors.Is(err, ErrEmailNotVerified) {
+		http.Error(w, "your email address isn't verified with "+provider.Name(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Errorf("Couldn't find or create user: %v", err)
 		http.Error(w, "some error with user", http.StatusInternalServerError)
+		return
 	}
 
-	dashboards := make([]models.Dashboard, 0)
-	projects := make([]models.Project, 0)
+	// Every login starts a new session, which is also the family of its refresh tokens
+	now := time.Now()
+	session, err := s.sessionRepo.CreateSession(models.Session{
+		ID:         uuid.New(),
+		UserID:     userRecord.ID,
+		UserAgent:  r.UserAgent(),
+		IPAddress:  token.ClientIP(r),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(token.RefreshExpirationTime),
+	})
+	if err != nil {
+		log.Errorf("Couldn't create session: %v", err)
+		http.Error(w, "couldn't create session", http.StatusInternalServerError)
+		return
+	}
 
-	if userRecord.ID != "" {
-		dashboards, err = s.dashboardRepo.ListDashboardsByUser(userRecord.ID)
-		if err != nil {
-			log.Error("couldn't look up user dashboards")
-		}
+	err = s.issueTokens(w, userRecord.ID, session.ID)
+	if err != nil {
+		log.Errorf("Couldn't issue tokens: %v", err)
+		http.Error(w, "couldn't create access token", http.StatusInternalServerError)
+		return
+	}
 
-		projects, err = s.projectRepo.ListProjectsByUser(userRecord.ID)
-		if err != nil {
-			log.Error("couldn't look up user dashboards")
-		}
+	w.Header().Set("Content-Type", "application/json")
+	http.Redirect(w, r, s.config.RedirectURL, http.StatusFound)
+}
+
+// RefreshHandler exchanges a refresh token cookie for a new access token. The refresh
+// token is rotated on every use, and presenting one that has already been used
+// revokes every token descended from the same login.
+func (s *authService) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	refreshCookie, err := r.Cookie(token.RefreshTokenCookieName)
+	if err != nil {
+		http.Error(w, "refresh token not present", http.StatusUnauthorized)
+		return
+	}
+
+	refreshToken, err := s.refreshRepo.GetRefreshTokenByHash(token.HashRefreshToken(refreshCookie.Value))
+	if err != nil {
+		log.Error(err)
+		s.clearTokenCookies(w)
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	if refreshToken.RevokedAt != nil {
+		s.clearTokenCookies(w)
+		http.Error(w, "refresh token has been revoked", http.StatusUnauthorized)
+		return
+	}
+
+	if refreshToken.UsedAt != nil {
+		s.revokeReusedRefreshToken(w, refreshToken)
+		return
+	}
+
+	if time.Now().After(refreshToken.ExpiresAt) {
+		s.clearTokenCookies(w)
+		http.Error(w, "refresh token has expired", http.StatusUnauthorized)
+		return
+	}
+
+	err = s.issueTokensWithRotation(w, refreshToken)
+	if errors.Is(err, repository.ErrRefreshTokenReused) {
+		s.revokeReusedRefreshToken(w, refreshToken)
+		return
+	}
+	if err != nil {
+		log.Errorf("Couldn't refresh tokens: %v", err)
+		http.Error(w, "couldn't refresh access token", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.sessionRepo.ExtendSession(refreshToken.FamilyID, time.Now().Add(token.RefreshExpirationTime))
+	if err != nil {
+		log.Error(err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *authService) revokeReusedRefreshToken(w http.ResponseWriter, refreshToken models.RefreshToken) {
+	log.Warnf("refresh token reuse detected for user %s, revoking session %s",
+		refreshToken.UserID, refreshToken.FamilyID)
+
+	// Revoking the session revokes the whole refresh token family and any access tokens issued for it
+	err := s.sessionRepo.RevokeSession(refreshToken.FamilyID)
+	if err != nil {
+		log.Error(err)
+	}
+
+	s.clearTokenCookies(w)
+	http.Error(w, "refresh token has already been used", http.StatusUnauthorized)
+}
+
+// issueTokens signs a new access token for the user and stores a new refresh token
+// in the given family, setting both as cookies.
+func (s *authService) issueTokens(w http.ResponseWriter, userID string, familyID uuid.UUID) error {
+	refreshToken, next, err := s.newRefreshToken(userID, familyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.refreshRepo.CreateRefreshToken(next)
+	if err != nil {
+		return err
+	}
+
+	return s.setTokenCookies(w, userID, familyID, refreshToken)
+}
+
+// issueTokensWithRotation replaces a refresh token with a new one from the same family
+// and signs a new access token alongside it.
+func (s *authService) issueTokensWithRotation(w http.ResponseWriter, used models.RefreshToken) error {
+	refreshToken, next, err := s.newRefreshToken(used.UserID, used.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.refreshRepo.RotateRefreshToken(used, next)
+	if err != nil {
+		return err
+	}
+
+	return s.setTokenCookies(w, used.UserID, used.FamilyID, refreshToken)
+}
+
+func (s *authService) newRefreshToken(userID string, familyID uuid.UUID) (string, models.RefreshToken, error) {
+	refreshToken, hash, err := token.NewRefreshToken()
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+
+	return refreshToken, models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(token.RefreshExpirationTime),
+	}, nil
+}
+
+// setTokenCookies signs an access token carrying the user's current project and
+// dashboard permissions and sets it, along with the refresh token, as cookies.
+func (s *authService) setTokenCookies(w http.ResponseWriter, userID string, sessionID uuid.UUID, refreshToken string) error {
+	dashboards, err := s.dashboardRepo.ListDashboardsByUser(userID)
+	if err != nil {
+		log.Error("couldn't look up user dashboards")
+	}
+
+	projects, err := s.projectRepo.ListProjectsByUser(userID)
+	if err != nil {
+		log.Error("couldn't look up user projects")
 	}
 
 	accessToken := token.NewAccessToken()
-	accessToken.SetUserID(userRecord.ID)
+	accessToken.SetUserID(userID)
+	accessToken.SetSessionID(sessionID.String())
 	accessToken.SetProjectsPermissions(projects)
 	accessToken.SetDashboardPermissions(dashboards)
 
-	signedToken, err := accessToken.SignToken([]byte(s.config.SigningKey))
+	signedToken, err := accessToken.SignToken(s.keys)
 	if err != nil {
-		log.Errorf("Couldn't sign access token: %v", err)
-		http.Error(w, "couldn't create access token", http.StatusInternalServerError)
-		return
+		return err
 	}
 
 	// Set access and refresh keys cookies
@@ -170,32 +515,74 @@ func (s *authService) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 		Domain:   s.config.Domain,
 	})
 
-	w.Header().Set("Content-Type", "application/json")
-	http.Redirect(w, r, s.config.RedirectURL, http.StatusFound)
+	http.SetCookie(w, &http.Cookie{
+		Name:     token.RefreshTokenCookieName,
+		Value:    refreshToken,
+		Path:     "/auth",
+		HttpOnly: true,
+		Domain:   s.config.Domain,
+		Expires:  time.Now().Add(token.RefreshExpirationTime),
+	})
+	return nil
 }
 
-type GoogleUserInfo struct {
-	Email      string `json:"email"`
-	ProfilePic string `json:"picture"`
+func (s *authService) clearTokenCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     token.AccessTokenCookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Domain:   s.config.Domain,
+		MaxAge:   -1,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     token.RefreshTokenCookieName,
+		Value:    "",
+		Path:     "/auth",
+		HttpOnly: true,
+		Domain:   s.config.Domain,
+		MaxAge:   -1,
+	})
 }
 
-func (s *authService) getUserInfo(accessToken string) (*GoogleUserInfo, error) {
-	request, _ := http.NewRequest(http.MethodGet, "https://openidconnect.googleapis.com/v1/userinfo", nil)
-	request.Header.Add("Authorization", "Bearer "+accessToken)
+// findOrCreateUser returns the user the provider identity belongs to. An identity that
+// hasn't been seen before is linked to the user with the same email address, as long as
+// the provider has verified it, and a new user is created if there isn't one.
+func (s *authService) findOrCreateUser(provider string, userInfo *identity.UserInfo) (models.User, error) {
+	userRecord, err := s.userRepo.GetUserByIdentity(provider, userInfo.Subject)
+	if err == nil {
+		return userRecord, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return userRecord, err
+	}
 
-	client := http.Client{}
-	response, err := client.Do(request)
-	if err != nil {
-		return nil, err
+	if !userInfo.EmailVerified || userInfo.Email == "" {
+		return userRecord, ErrEmailNotVerified
 	}
 
-	var userInfo GoogleUserInfo
-	err = json.NewDecoder(response.Body).Decode(&userInfo)
-	if err != nil {
-		return nil, err
+	// Check if user exists
+	userRecord, err = s.userRepo.GetUserByEmail(userInfo.Email)
+	switch err {
+	case gorm.ErrRecordNotFound:
+		userRecord, err = s.userRepo.CreateUser(s.generateNewUserRecord(userInfo.Email, userInfo.Picture))
+		if err != nil {
+			return userRecord, err
+		}
+	case nil:
+		break
+	default:
+		return userRecord, err
 	}
 
-	return &userInfo, nil
+	_, err = s.userRepo.CreateIdentity(models.Identity{
+		Provider: provider,
+		Subject:  userInfo.Subject,
+		UserID:   userRecord.ID,
+		Email:    userInfo.Email,
+	})
+	return userRecord, err
 }
 
 func (s *authService) generateNewUserRecord(email, pic string) models.User {
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/token"
+)
+
+// LogoutHandler revokes the caller's session and clears the token cookies. It doesn't
+// require a valid access token, so a user whose access token has expired can still
+// log out using their refresh token.
+func (s *authService) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := uuid.Nil
+
+	accessCookie, err := r.Cookie(token.AccessTokenCookieName)
+	if err == nil {
+		accessToken := &token.ToDanniToken{}
+		err = accessToken.Parse(accessCookie.Value, s.keys)
+		if err == nil {
+			err = s.sessionRepo.RevokeToken(accessToken.GetTokenID(), accessToken.GetExpiration())
+			if err != nil {
+				log.Error(err)
+			}
+
+			if id, err := uuid.Parse(accessToken.GetSessionID()); err == nil {
+				sessionID = id
+			}
+		}
+	}
+
+	if sessionID == uuid.Nil {
+		refreshCookie, err := r.Cookie(token.RefreshTokenCookieName)
+		if err == nil {
+			refreshToken, err := s.refreshRepo.GetRefreshTokenByHash(token.HashRefreshToken(refreshCookie.Value))
+			if err == nil {
+				sessionID = refreshToken.FamilyID
+			}
+		}
+	}
+
+	if sessionID != uuid.Nil {
+		err = s.sessionRepo.RevokeSession(sessionID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't revoke session", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.clearTokenCookies(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *authService) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := s.sessionRepo.ListActiveSessionsByUser(userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID.String() == accessToken.GetSessionID(),
+		})
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *authService) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	sessionID, err := uuid.Parse(params["id"])
+	if err != nil {
+		http.Error(w, "invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := s.sessionRepo.GetSessionByID(sessionID)
+	if err != nil || session.UserID != userID {
+		http.Error(w, "couldn't find session", http.StatusNotFound)
+		return
+	}
+
+	err = s.sessionRepo.RevokeSession(sessionID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't revoke session", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/token"
+)
+
+func (s *authService) CreatePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.personalAccessTokenOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var createRequest CreatePersonalAccessTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = validation.ValidateStruct(&createRequest,
+		validation.Field(&createRequest.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&createRequest.Scope, validation.Required, validation.In(models.ReadScope, models.ReadWriteScope)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if createRequest.ExpiresAt != nil && !createRequest.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	for _, projectID := range createRequest.ProjectIDs {
+		isMember, err := s.projectRepo.IsProjectMember(userID, projectID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't check project membership", http.StatusInternalServerError)
+			return
+		}
+		if !isMember {
+			http.Error(w, fmt.Sprintf("you aren't a member of project %d", projectID), http.StatusForbidden)
+			return
+		}
+	}
+
+	value, hash, prefix, err := token.NewPersonalAccessToken()
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't create personal access token", http.StatusInternalServerError)
+		return
+	}
+
+	pat, err := s.personalTokenRepo.CreatePersonalAccessToken(models.PersonalAccessToken{
+		UserID:     userID,
+		Name:       createRequest.Name,
+		Prefix:     prefix,
+		TokenHash:  hash,
+		Scope:      createRequest.Scope,
+		ProjectIDs: createRequest.ProjectIDs,
+		ExpiresAt:  createRequest.ExpiresAt,
+	})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't create personal access token", http.StatusInternalServerError)
+		return
+	}
+
+	// This is the only time the token itself is returned
+	response := toPersonalAccessTokenResponse(pat)
+	response.Token = value
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+func (s *authService) ListPersonalAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.personalAccessTokenOwner(w, r)
+	if !ok {
+		return
+	}
+
+	pats, err := s.personalTokenRepo.ListPersonalAccessTokensByUser(userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't retrieve personal access tokens", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]PersonalAccessTokenResponse, 0, len(pats))
+	for _, pat := range pats {
+		response = append(response, toPersonalAccessTokenResponse(pat))
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *authService) RevokePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.personalAccessTokenOwner(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	patID, err := strconv.ParseUint(params["id"], 10, 0)
+	if err != nil {
+		http.Error(w, "invalid personal access token ID", http.StatusBadRequest)
+		return
+	}
+
+	err = s.personalTokenRepo.RevokePersonalAccessToken(uint(patID), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "couldn't find personal access token", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't revoke personal access token", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// personalAccessTokenOwner returns the caller's user ID. Personal access tokens can't
+// be used to create or revoke other tokens, so it writes an error response and returns
+// false if the caller authenticated with one.
+func (s *authService) personalAccessTokenOwner(w http.ResponseWriter, r *http.Request) (string, bool) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return "", false
+	}
+
+	if accessToken.IsPersonalAccessToken() {
+		http.Error(w, "personal access tokens can't be used to manage personal access tokens", http.StatusForbidden)
+		return "", false
+	}
+	return userID, true
+}
+
+func toPersonalAccessTokenResponse(pat models.PersonalAccessToken) PersonalAccessTokenResponse {
+	return PersonalAccessTokenResponse{
+		ID:         pat.ID,
+		Name:       pat.Name,
+		Prefix:     pat.Prefix,
+		Scope:      pat.Scope,
+		ProjectIDs: pat.ProjectIDs,
+		ExpiresAt:  pat.ExpiresAt,
+		LastUsedAt: pat.LastUsedAt,
+		CreatedAt:  pat.CreatedAt,
+	}
+}
@@ -1 +1,53 @@
 package dashboard
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/todanni/api/models"
+)
+
+type CreateDashboardRequest struct {
+	Name     string `json:"name"`
+	Projects []uint `json:"projects"`
+}
+
+type UpdateDashboardRequest struct {
+	Name     string `json:"name"`
+	Projects []uint `json:"projects"`
+}
+
+type DashboardResponse struct {
+	ID        uuid.UUID                  `json:"id"`
+	Name      string                     `json:"name"`
+	Owner     string                     `json:"owner"`
+	CreatedAt time.Time                  `json:"created_at"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+	Members   []DashboardMemberResponse  `json:"members"`
+	Projects  []DashboardProjectResponse `json:"projects"`
+}
+
+type DashboardMemberResponse struct {
+	ID          string        `json:"id"`
+	DisplayName string        `json:"display_name"`
+	ProfilePic  string        `json:"profile_pic"`
+	Status      models.Status `json:"status"`
+}
+
+type DashboardProjectResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type InviteDashboardMemberRequest struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+type DashboardInviteResponse struct {
+	DashboardID   uuid.UUID `json:"dashboard_id"`
+	DashboardName string    `json:"dashboard_name"`
+	InvitedBy     string    `json:"invited_by"`
+	InvitedAt     time.Time `json:"invited_at"`
+}
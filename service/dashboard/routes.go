@@ -8,10 +8,17 @@ const (
 
 func (s *dashboardService) routes() {
 	r := s.router.PathPrefix(APIPath).Subrouter()
+	r.Use(s.middleware.JwtMiddleware)
 
 	r.HandleFunc("/", s.ListDashboardsHandler).Methods(http.MethodGet)
 	r.HandleFunc("/", s.CreateDashboardHandler).Methods(http.MethodPost)
+	r.HandleFunc("/invites", s.ListDashboardInvitesHandler).Methods(http.MethodGet)
 	r.HandleFunc("/{id}", s.GetDashboardHandler).Methods(http.MethodGet)
 	r.HandleFunc("/{id}", s.UpdateDashboardHandler).Methods(http.MethodPatch)
 	r.HandleFunc("/{id}", s.DeleteDashboardHandler).Methods(http.MethodDelete)
+
+	r.HandleFunc("/{id}/members", s.InviteDashboardMemberHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id}/members/{member_id}", s.RemoveDashboardMemberHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/{id}/accept", s.AcceptDashboardInviteHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id}/reject", s.RejectDashboardInviteHandler).Methods(http.MethodPost)
 }
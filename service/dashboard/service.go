@@ -1,11 +1,21 @@
 package dashboard
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 
+	"github.com/todanni/api/email"
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
 	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
 )
 
 type DashboardsService interface {
@@ -14,43 +24,565 @@ type DashboardsService interface {
 	UpdateDashboardHandler(w http.ResponseWriter, r *http.Request)
 	ListDashboardsHandler(w http.ResponseWriter, r *http.Request)
 	DeleteDashboardHandler(w http.ResponseWriter, r *http.Request)
+
+	InviteDashboardMemberHandler(w http.ResponseWriter, r *http.Request)
+	RemoveDashboardMemberHandler(w http.ResponseWriter, r *http.Request)
+	ListDashboardInvitesHandler(w http.ResponseWriter, r *http.Request)
+	AcceptDashboardInviteHandler(w http.ResponseWriter, r *http.Request)
+	RejectDashboardInviteHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type dashboardService struct {
-	router *mux.Router
-	repo   repository.DashboardRepository
+	router      *mux.Router
+	repo        repository.DashboardRepository
+	userRepo    repository.UserRepository
+	emailClient email.SenderClient
+	middleware  token.AuthMiddleware
+	permissions permission.Checker
 }
 
-func NewDashboardService(r *mux.Router, repo repository.DashboardRepository) DashboardsService {
+func NewDashboardService(
+	r *mux.Router,
+	repo repository.DashboardRepository,
+	userRepo repository.UserRepository,
+	emailClient email.SenderClient,
+	mw token.AuthMiddleware,
+	permissions permission.Checker,
+) DashboardsService {
 	service := &dashboardService{
-		router: r,
-		repo:   repo,
+		router:      r,
+		repo:        repo,
+		userRepo:    userRepo,
+		emailClient: emailClient,
+		middleware:  mw,
+		permissions: permissions,
 	}
 	service.routes()
 	return service
 }
 
 func (s *dashboardService) CreateDashboardHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO implement me
-	panic("implement me")
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	var createRequest CreateDashboardRequest
+	err := json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = validation.ValidateStruct(&createRequest,
+		validation.Field(&createRequest.Name, validation.Required),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A dashboard can only group projects the user is a member of
+	projects, ok := s.projectsFromIDs(accessToken, createRequest.Projects)
+	if !ok {
+		http.Error(w, "you don't have access to one or more of the projects", http.StatusForbidden)
+		return
+	}
+
+	dashboard, err := s.repo.CreateDashboard(models.Dashboard{
+		ID:    uuid.New(),
+		Name:  createRequest.Name,
+		Owner: userID,
+		Members: []models.User{
+			{
+				ID: userID,
+			},
+		},
+		Projects: projects,
+	})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't create dashboard", http.StatusInternalServerError)
+		return
+	}
+	s.permissions.Invalidate(userID)
+
+	// Re-read the dashboard so members and projects are fully populated
+	dashboard, err = s.repo.GetDashboardByID(dashboard.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't retrieve dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(toDashboardResponse(dashboard))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
 }
 
 func (s *dashboardService) GetDashboardHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO implement me
-	panic("implement me")
+	params := mux.Vars(r)
+	dashboardID, err := uuid.Parse(params["id"])
+	if err != nil {
+		http.Error(w, "invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	if !s.permissions.HasDashboardPermission(accessToken, dashboardID) {
+		http.Error(w, "you don't have access to this dashboard", http.StatusForbidden)
+		return
+	}
+
+	dashboard, err := s.repo.GetDashboardByID(dashboardID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't find dashboard", http.StatusNotFound)
+		return
+	}
+
+	responseBody, err := json.Marshal(toDashboardResponse(dashboard))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
 }
 
 func (s *dashboardService) UpdateDashboardHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO implement me
-	panic("implement me")
+	params := mux.Vars(r)
+	dashboardID, err := uuid.Parse(params["id"])
+	if err != nil {
+		http.Error(w, "invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	if !s.permissions.HasDashboardPermission(accessToken, dashboardID) {
+		http.Error(w, "you don't have access to this dashboard", http.StatusForbidden)
+		return
+	}
+
+	var updateRequest UpdateDashboardRequest
+	err = json.NewDecoder(r.Body).Decode(&updateRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	projects, ok := s.projectsFromIDs(accessToken, updateRequest.Projects)
+	if !ok {
+		http.Error(w, "you don't have access to one or more of the projects", http.StatusForbidden)
+		return
+	}
+
+	updatedDashboard, err := s.repo.UpdateDashboard(models.Dashboard{
+		ID:       dashboardID,
+		Name:     updateRequest.Name,
+		Projects: projects,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "couldn't find dashboard", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(toDashboardResponse(updatedDashboard))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
 }
 
 func (s *dashboardService) ListDashboardsHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO implement me
-	panic("implement me")
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	dashboards, err := s.repo.ListDashboardsByUser(userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't retrieve dashboards", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]DashboardResponse, 0, len(dashboards))
+	for _, dashboard := range dashboards {
+		response = append(response, toDashboardResponse(dashboard))
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
 }
 
 func (s *dashboardService) DeleteDashboardHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO implement me
-	panic("implement me")
+	params := mux.Vars(r)
+	dashboardID, err := uuid.Parse(params["id"])
+	if err != nil {
+		http.Error(w, "invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	if !s.permissions.HasDashboardPermission(accessToken, dashboardID) {
+		http.Error(w, "you don't have access to this dashboard", http.StatusForbidden)
+		return
+	}
+
+	dashboard, err := s.repo.GetDashboardByID(dashboardID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't find dashboard", http.StatusNotFound)
+		return
+	}
+
+	if dashboard.Owner != userID {
+		http.Error(w, "only the dashboard owner can delete a dashboard", http.StatusForbidden)
+		return
+	}
+
+	_, err = s.repo.DeleteDashboard(dashboardID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't delete dashboard", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *dashboardService) InviteDashboardMemberHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	dashboardID, err := uuid.Parse(params["id"])
+	if err != nil {
+		http.Error(w, "invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	if !s.permissions.HasDashboardPermission(accessToken, dashboardID) {
+		http.Error(w, "you don't have access to this dashboard", http.StatusForbidden)
+		return
+	}
+
+	var inviteRequest InviteDashboardMemberRequest
+	err = json.NewDecoder(r.Body).Decode(&inviteRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if inviteRequest.UserID == "" && inviteRequest.Email == "" {
+		http.Error(w, "either user_id or email must be set", http.StatusBadRequest)
+		return
+	}
+
+	// Look up the person being invited
+	var invitee models.User
+	if inviteRequest.UserID != "" {
+		invitee, err = s.userRepo.GetUserByID(inviteRequest.UserID)
+	} else {
+		invitee, err = s.userRepo.GetUserByEmail(inviteRequest.Email)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && invitee.ID == "") {
+		http.Error(w, "couldn't find user to invite", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up user to invite", http.StatusInternalServerError)
+		return
+	}
+
+	// Don't invite people who are already members or have an invitation waiting
+	existing, err := s.repo.GetDashboardMember(dashboardID, invitee.ID)
+	switch {
+	case err == nil && existing.Status == models.AcceptedStatus:
+		http.Error(w, "user is already a member of this dashboard", http.StatusConflict)
+		return
+	case err == nil && existing.Status == models.PendingStatus:
+		http.Error(w, "user has already been invited to this dashboard", http.StatusConflict)
+		return
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		log.Error(err)
+		http.Error(w, "couldn't check dashboard membership", http.StatusInternalServerError)
+		return
+	}
+
+	dashboard, err := s.repo.GetDashboardByID(dashboardID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't find dashboard", http.StatusNotFound)
+		return
+	}
+
+	member, err := s.repo.InviteDashboardMember(dashboardID, invitee.ID, userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't invite user to dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	// The invitation can still be seen through the API, so a failed email isn't fatal
+	inviter, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		log.Error(err)
+	}
+	err = s.emailClient.SendDashboardInvitationEmail(email.DashboardInviteEmail{
+		DashboardName:  dashboard.Name,
+		InviterName:    inviter.DisplayName,
+		RecipientName:  invitee.DisplayName,
+		RecipientEmail: invitee.Email,
+	})
+	if err != nil {
+		log.Errorf("couldn't send dashboard invitation email: %v", err)
+	}
+
+	responseBody, err := json.Marshal(DashboardMemberResponse{
+		ID:          invitee.ID,
+		DisplayName: invitee.DisplayName,
+		ProfilePic:  invitee.ProfilePic,
+		Status:      member.Status,
+	})
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+func (s *dashboardService) RemoveDashboardMemberHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	memberID := params["member_id"]
+	dashboardID, err := uuid.Parse(params["id"])
+	if err != nil {
+		http.Error(w, "invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	if !s.permissions.HasDashboardPermission(accessToken, dashboardID) {
+		http.Error(w, "you don't have access to this dashboard", http.StatusForbidden)
+		return
+	}
+
+	dashboard, err := s.repo.GetDashboardByID(dashboardID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't find dashboard", http.StatusNotFound)
+		return
+	}
+
+	// Members can leave a dashboard, but only the owner can remove other people
+	if memberID != userID && dashboard.Owner != userID {
+		http.Error(w, "only the dashboard owner can remove members from a dashboard", http.StatusForbidden)
+		return
+	}
+
+	if memberID == dashboard.Owner {
+		http.Error(w, "the dashboard owner can't be removed from the dashboard", http.StatusBadRequest)
+		return
+	}
+
+	err = s.repo.RemoveDashboardMember(dashboardID, memberID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't remove member from dashboard", http.StatusInternalServerError)
+		return
+	}
+	s.permissions.Invalidate(memberID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *dashboardService) ListDashboardInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	dashboards, err := s.repo.ListDashboardInvitesByUser(userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't retrieve dashboard invites", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]DashboardInviteResponse, 0, len(dashboards))
+	for _, dashboard := range dashboards {
+		for _, membership := range dashboard.Memberships {
+			if membership.UserID != userID {
+				continue
+			}
+			response = append(response, DashboardInviteResponse{
+				DashboardID:   dashboard.ID,
+				DashboardName: dashboard.Name,
+				InvitedBy:     membership.InvitedBy,
+				InvitedAt:     membership.UpdatedAt,
+			})
+		}
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *dashboardService) AcceptDashboardInviteHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondToInvite(w, r, models.AcceptedStatus)
+}
+
+func (s *dashboardService) RejectDashboardInviteHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondToInvite(w, r, models.RejectedStatus)
+}
+
+// respondToInvite moves the caller's pending membership of a dashboard to the given status.
+func (s *dashboardService) respondToInvite(w http.ResponseWriter, r *http.Request, status models.Status) {
+	params := mux.Vars(r)
+	dashboardID, err := uuid.Parse(params["id"])
+	if err != nil {
+		http.Error(w, "invalid dashboard ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	member, err := s.repo.GetDashboardMember(dashboardID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "couldn't find an invitation to this dashboard", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up dashboard invitation", http.StatusInternalServerError)
+		return
+	}
+
+	if member.Status != models.PendingStatus {
+		http.Error(w, "the invitation to this dashboard has already been answered", http.StatusConflict)
+		return
+	}
+
+	err = s.repo.UpdateDashboardMemberStatus(dashboardID, userID, status)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't respond to dashboard invitation", http.StatusInternalServerError)
+		return
+	}
+	s.permissions.Invalidate(userID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// projectsFromIDs turns the project IDs from a request into project records,
+// returning false if the user isn't a member of any of them.
+// A nil slice of IDs is returned as nil projects so updates leave them unchanged.
+func (s *dashboardService) projectsFromIDs(accessToken *token.ToDanniToken, projectIDs []uint) ([]models.Project, bool) {
+	if projectIDs == nil {
+		return nil, true
+	}
+
+	projects := make([]models.Project, 0, len(projectIDs))
+	for _, projectID := range projectIDs {
+		if !s.permissions.HasProjectPermission(accessToken, projectID) {
+			return nil, false
+		}
+		projects = append(projects, models.Project{Model: gorm.Model{ID: projectID}})
+	}
+	return projects, true
+}
+
+func toDashboardResponse(dashboard models.Dashboard) DashboardResponse {
+	response := DashboardResponse{
+		ID:        dashboard.ID,
+		Name:      dashboard.Name,
+		Owner:     dashboard.Owner,
+		CreatedAt: dashboard.CreatedAt,
+		UpdatedAt: dashboard.UpdatedAt,
+		Members:   make([]DashboardMemberResponse, 0, len(dashboard.Members)),
+		Projects:  make([]DashboardProjectResponse, 0, len(dashboard.Projects)),
+	}
+
+	statuses := make(map[string]models.Status, len(dashboard.Memberships))
+	for _, membership := range dashboard.Memberships {
+		statuses[membership.UserID] = membership.Status
+	}
+
+	for _, member := range dashboard.Members {
+		// People who turned the invitation down aren't shown as members
+		if statuses[member.ID] == models.RejectedStatus {
+			continue
+		}
+		response.Members = append(response.Members, DashboardMemberResponse{
+			ID:          member.ID,
+			DisplayName: member.DisplayName,
+			ProfilePic:  member.ProfilePic,
+			Status:      statuses[member.ID],
+		})
+	}
+
+	for _, project := range dashboard.Projects {
+		response.Projects = append(response.Projects, DashboardProjectResponse{
+			ID:   project.ID,
+			Name: project.Name,
+		})
+	}
+	return response
 }
@@ -0,0 +1,77 @@
+package project
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+)
+
+// GetBoardHandler returns the project's statuses in order as board columns, each with
+// its tasks in rank order.
+func (s *projectService) GetBoardHandler(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	statuses, err := s.statusRepo.ListTaskStatuses(projectID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list task statuses", http.StatusInternalServerError)
+		return
+	}
+
+	tasks, err := s.taskRepo.ListRankedTasksByProject(projectID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list tasks", http.StatusInternalServerError)
+		return
+	}
+
+	response := BoardResponse{
+		ProjectID: projectID,
+		Columns:   buildBoardColumns(statuses, tasks),
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// buildBoardColumns groups the already ordered tasks by status. Tasks without a status
+// of the project aren't shown.
+func buildBoardColumns(statuses []models.TaskStatus, tasks []models.Task) []BoardColumn {
+	columns := make([]BoardColumn, len(statuses))
+	columnByStatus := make(map[uint]*BoardColumn, len(statuses))
+	for i, status := range statuses {
+		columns[i] = BoardColumn{Status: status, Tasks: []models.Task{}}
+		columnByStatus[status.ID] = &columns[i]
+	}
+
+	for _, task := range tasks {
+		if task.StatusID == nil {
+			continue
+		}
+		column, ok := columnByStatus[*task.StatusID]
+		if !ok {
+			continue
+		}
+		column.Tasks = append(column.Tasks, task)
+	}
+
+	for i := range columns {
+		columns[i].Count = len(columns[i].Tasks)
+		limit := columns[i].Status.WIPLimit
+		columns[i].OverLimit = limit != nil && columns[i].Count > *limit
+	}
+	return columns
+}
@@ -0,0 +1,375 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/email"
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+var (
+	// InviteExpirationTime is how long an invited user has to accept a project invite.
+	InviteExpirationTime = 7 * 24 * time.Hour
+
+	errInvalidRole     = errors.New("invalid role")
+	errRoleAboveCaller = errors.New("you don't have permission to invite members with that role")
+	errInviteExpired   = errors.New("the project invite has expired")
+	errInviteAnswered  = errors.New("the project invite has already been answered or revoked")
+)
+
+func (s *projectService) CreateProjectInviteHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	projectIDStr := params["id"]
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	project, err := s.repo.GetProjectByID(projectIDStr)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't find project", http.StatusNotFound)
+		return
+	}
+
+	if !s.permissions.Can(accessToken, project.ID, permission.ManageMembers) {
+		http.Error(w, "you don't have permission to invite members to this project", http.StatusForbidden)
+		return
+	}
+
+	var inviteRequest CreateProjectInviteRequest
+	err = json.NewDecoder(r.Body).Decode(&inviteRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if inviteRequest.UserID == "" && inviteRequest.Email == "" {
+		http.Error(w, "either user_id or email must be set", http.StatusBadRequest)
+		return
+	}
+
+	role, err := inviteRole(inviteRequest.Role, s.permissions.ProjectRole(accessToken, project.ID))
+	if errors.Is(err, errInvalidRole) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Look up the person being invited
+	var invitee models.User
+	if inviteRequest.UserID != "" {
+		invitee, err = s.userRepo.GetUserByID(inviteRequest.UserID)
+	} else {
+		invitee, err = s.userRepo.GetUserByEmail(inviteRequest.Email)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && invitee.ID == "") {
+		http.Error(w, "couldn't find user to invite", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up user to invite", http.StatusInternalServerError)
+		return
+	}
+
+	isMember, err := s.repo.IsProjectMember(invitee.ID, project.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't check project membership", http.StatusInternalServerError)
+		return
+	}
+	if isMember {
+		http.Error(w, "user is already a member of this project", http.StatusConflict)
+		return
+	}
+
+	_, err = s.repo.GetPendingProjectInvite(invitee.ID, project.ID)
+	switch {
+	case err == nil:
+		http.Error(w, "user has already been invited to this project", http.StatusConflict)
+		return
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		log.Error(err)
+		http.Error(w, "couldn't check existing invites", http.StatusInternalServerError)
+		return
+	}
+
+	invite, err := s.repo.CreateProjectInvite(newProjectInvite(project.ID, invitee.ID, userID, role, time.Now()))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't create project invite", http.StatusInternalServerError)
+		return
+	}
+	invite.Project = project
+
+	// The invite can still be seen through the API, so a failed email isn't fatal
+	err = s.emailClient.SendProjectInvitationEmail(email.ProjectInviteEmail{
+		ProjectName:    project.Name,
+		RecipientName:  invitee.DisplayName,
+		RecipientEmail: invitee.Email,
+	})
+	if err != nil {
+		log.Errorf("couldn't send project invitation email: %v", err)
+	}
+
+	responseBody, err := json.Marshal(toProjectInviteResponse(invite))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+func (s *projectService) ListProjectInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	projectIDStr := params["id"]
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	project, err := s.repo.GetProjectByID(projectIDStr)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't find project", http.StatusNotFound)
+		return
+	}
+
+	if !s.permissions.Can(accessToken, project.ID, permission.ManageMembers) {
+		http.Error(w, "you don't have permission to see this project's invites", http.StatusForbidden)
+		return
+	}
+
+	invites, err := s.repo.ListProjectInvitesByProject(project.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list project invites", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]ProjectInviteResponse, 0, len(invites))
+	for _, invite := range invites {
+		response = append(response, toProjectInviteResponse(invite))
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *projectService) RevokeProjectInviteHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	projectIDStr := params["id"]
+	inviteID := params["invite_id"]
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	invite, err := s.repo.GetProjectInviteByID(inviteID)
+	if err != nil || strconv.FormatUint(uint64(invite.ProjectID), 10) != projectIDStr {
+		http.Error(w, "couldn't find project invite", http.StatusNotFound)
+		return
+	}
+
+	if invite.InvitedBy != userID && !s.permissions.Can(accessToken, invite.ProjectID, permission.ManageMembers) {
+		http.Error(w, "you don't have permission to revoke this project invite", http.StatusForbidden)
+		return
+	}
+
+	if checkInvitePending(invite, time.Now()) != nil {
+		http.Error(w, "only pending invites can be revoked", http.StatusConflict)
+		return
+	}
+
+	err = s.repo.UpdateProjectInviteStatus(invite.ID, models.RevokedStatus)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't revoke project invite", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *projectService) ListMyProjectInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	invites, err := s.repo.ListPendingProjectInvitesByUser(userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list project invites", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]ProjectInviteResponse, 0, len(invites))
+	for _, invite := range invites {
+		response = append(response, toProjectInviteResponse(invite))
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *projectService) AcceptProjectInviteHandler(w http.ResponseWriter, r *http.Request) {
+	invite, ok := s.getPendingInviteForCaller(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.repo.AcceptProjectInvite(invite)
+	if errors.Is(err, repository.ErrInviteNotPending) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't accept project invite", http.StatusInternalServerError)
+		return
+	}
+	s.permissions.Invalidate(invite.UserID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *projectService) DeclineProjectInviteHandler(w http.ResponseWriter, r *http.Request) {
+	invite, ok := s.getPendingInviteForCaller(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.repo.UpdateProjectInviteStatus(invite.ID, models.RejectedStatus)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't decline project invite", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// getPendingInviteForCaller looks up the invite from the request path and checks that
+// it was sent to the caller and can still be answered. It writes the error response
+// and returns false if it can't.
+func (s *projectService) getPendingInviteForCaller(w http.ResponseWriter, r *http.Request) (models.ProjectInvite, bool) {
+	params := mux.Vars(r)
+	inviteID := params["invite_id"]
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return models.ProjectInvite{}, false
+	}
+
+	invite, err := s.repo.GetProjectInviteByID(inviteID)
+	if err != nil || invite.UserID != userID {
+		http.Error(w, "couldn't find project invite", http.StatusNotFound)
+		return models.ProjectInvite{}, false
+	}
+
+	err = checkInvitePending(invite, time.Now())
+	switch {
+	case errors.Is(err, errInviteExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+		return models.ProjectInvite{}, false
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return models.ProjectInvite{}, false
+	}
+	return invite, true
+}
+
+// inviteRole returns the role to invite someone with, a member if none was asked for.
+// Callers can only invite people with roles they're allowed to assign.
+func inviteRole(requested models.ProjectRole, callerRole models.ProjectRole) (models.ProjectRole, error) {
+	if requested == "" {
+		requested = models.MemberRole
+	}
+	if !permission.IsValidRole(requested) {
+		return "", errInvalidRole
+	}
+	if !permission.CanAssignRole(callerRole, requested) {
+		return "", errRoleAboveCaller
+	}
+	return requested, nil
+}
+
+// newProjectInvite returns a pending invite to the project, which the user has until
+// InviteExpirationTime after now to accept.
+func newProjectInvite(projectID uint, userID string, invitedBy string, role models.ProjectRole, now time.Time) models.ProjectInvite {
+	return models.ProjectInvite{
+		ProjectID: projectID,
+		UserID:    userID,
+		InvitedBy: invitedBy,
+		Role:      role,
+		Status:    models.PendingStatus,
+		ExpiresAt: now.Add(InviteExpirationTime),
+	}
+}
+
+// checkInvitePending returns an error if the invite can no longer be answered or revoked.
+func checkInvitePending(invite models.ProjectInvite, now time.Time) error {
+	switch invite.CurrentStatus(now) {
+	case models.PendingStatus:
+		return nil
+	case models.ExpiredStatus:
+		return errInviteExpired
+	default:
+		return errInviteAnswered
+	}
+}
+
+func toProjectInviteResponse(invite models.ProjectInvite) ProjectInviteResponse {
+	return ProjectInviteResponse{
+		ID:          invite.ID,
+		ProjectID:   invite.ProjectID,
+		ProjectName: invite.Project.Name,
+		UserID:      invite.UserID,
+		InvitedBy:   invite.InvitedBy,
+		Role:        invite.Role,
+		Status:      invite.CurrentStatus(time.Now()),
+		ExpiresAt:   invite.ExpiresAt,
+		CreatedAt:   invite.CreatedAt,
+	}
+}
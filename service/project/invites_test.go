@@ -0,0 +1,55 @@
+package project
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestInviteRole(t *testing.T) {
+	role, err := inviteRole("", models.AdminRole)
+	require.NoError(t, err)
+	require.Equal(t, models.MemberRole, role)
+
+	role, err = inviteRole(models.ViewerRole, models.AdminRole)
+	require.NoError(t, err)
+	require.Equal(t, models.ViewerRole, role)
+
+	role, err = inviteRole(models.AdminRole, models.OwnerRole)
+	require.NoError(t, err)
+	require.Equal(t, models.AdminRole, role)
+
+	_, err = inviteRole("superuser", models.OwnerRole)
+	require.ErrorIs(t, err, errInvalidRole)
+
+	// Nobody can invite people with a role above the ones they can give out
+	_, err = inviteRole(models.AdminRole, models.AdminRole)
+	require.ErrorIs(t, err, errRoleAboveCaller)
+	_, err = inviteRole(models.OwnerRole, models.OwnerRole)
+	require.ErrorIs(t, err, errRoleAboveCaller)
+	_, err = inviteRole(models.ViewerRole, models.MemberRole)
+	require.ErrorIs(t, err, errRoleAboveCaller)
+}
+
+func TestCheckInvitePending(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	invite := newProjectInvite(1, "invitee", "admin", models.MemberRole, now)
+
+	require.Equal(t, uint(1), invite.ProjectID)
+	require.Equal(t, "invitee", invite.UserID)
+	require.Equal(t, "admin", invite.InvitedBy)
+	require.Equal(t, models.MemberRole, invite.Role)
+
+	require.NoError(t, checkInvitePending(invite, now))
+	require.NoError(t, checkInvitePending(invite, now.Add(InviteExpirationTime)))
+	require.ErrorIs(t, checkInvitePending(invite, now.Add(InviteExpirationTime+time.Second)), errInviteExpired)
+
+	// Answered and revoked invites can't be answered or revoked again, even before they'd expire
+	for _, status := range []models.Status{models.AcceptedStatus, models.RejectedStatus, models.RevokedStatus} {
+		invite.Status = status
+		require.ErrorIs(t, checkInvitePending(invite, now), errInviteAnswered)
+	}
+}
@@ -0,0 +1,183 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/repository"
+)
+
+// labelColor matches hex colors like #d73a4a
+var labelColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// maxLabelNameLength keeps labels short enough to show on a task card
+const maxLabelNameLength = 50
+
+func (s *projectService) ListLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	labels, err := s.labelRepo.ListLabels(projectID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list labels", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(labels)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *projectService) CreateLabelHandler(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.EditProject)
+	if !ok {
+		return
+	}
+
+	var createRequest CreateLabelRequest
+	err := json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	createRequest.Name = strings.TrimSpace(createRequest.Name)
+
+	if err = validation.ValidateStruct(&createRequest,
+		validation.Field(&createRequest.Name, validation.Required, validation.Length(1, maxLabelNameLength)),
+		validation.Field(&createRequest.Color, validation.Required, validation.Match(labelColor)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	label, err := s.labelRepo.CreateLabel(models.Label{
+		ProjectID: projectID,
+		Name:      createRequest.Name,
+		Color:     strings.ToLower(createRequest.Color),
+	})
+	if errors.Is(err, repository.ErrLabelExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't create label", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(label)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+func (s *projectService) UpdateLabelHandler(w http.ResponseWriter, r *http.Request) {
+	label, ok := s.getLabel(w, r)
+	if !ok {
+		return
+	}
+
+	var updateRequest UpdateLabelRequest
+	err := json.NewDecoder(r.Body).Decode(&updateRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updateRequest.Name = strings.TrimSpace(updateRequest.Name)
+
+	if err = validation.ValidateStruct(&updateRequest,
+		validation.Field(&updateRequest.Name, validation.Length(1, maxLabelNameLength)),
+		validation.Field(&updateRequest.Color, validation.Match(labelColor)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if updateRequest.Name != "" {
+		label.Name = updateRequest.Name
+	}
+	if updateRequest.Color != "" {
+		label.Color = strings.ToLower(updateRequest.Color)
+	}
+
+	label, err = s.labelRepo.UpdateLabel(label)
+	if errors.Is(err, repository.ErrLabelExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update label", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(label)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// DeleteLabelHandler deletes the label, taking it off any tasks it's on.
+func (s *projectService) DeleteLabelHandler(w http.ResponseWriter, r *http.Request) {
+	label, ok := s.getLabel(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.labelRepo.DeleteLabel(label)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't delete label", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// getLabel returns the label from the request path, checking the caller can edit the
+// project. It writes the error response and returns false if it can't.
+func (s *projectService) getLabel(w http.ResponseWriter, r *http.Request) (models.Label, bool) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.EditProject)
+	if !ok {
+		return models.Label{}, false
+	}
+
+	labelID, err := strconv.ParseUint(mux.Vars(r)["label_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid label ID", http.StatusBadRequest)
+		return models.Label{}, false
+	}
+
+	label, err := s.labelRepo.GetLabel(projectID, uint(labelID))
+	if err != nil {
+		http.Error(w, "couldn't find label", http.StatusNotFound)
+		return models.Label{}, false
+	}
+	return label, true
+}
@@ -2,6 +2,8 @@ package project
 
 import (
 	"time"
+
+	"github.com/todanni/api/models"
 )
 
 type CreateProjectRequest struct {
@@ -24,13 +26,100 @@ type ListProjectsResponse struct {
 	Owner     string    `json:"owner"`
 }
 
+// UpdateProjectRequest can't change the owner, which is done through a transfer
 type UpdateProjectRequest struct {
-	Name  string `json:"name"`
-	Owner string `json:"owner"`
+	Name string `json:"name"`
+}
+
+type TransferProjectRequest struct {
+	UserID string `json:"user_id"`
+	// RequireAcceptance leaves the transfer pending until the new owner accepts it
+	RequireAcceptance bool `json:"require_acceptance"`
+}
+
+type ProjectTransferResponse struct {
+	ID         uint          `json:"id"`
+	ProjectID  uint          `json:"project_id"`
+	FromUserID string        `json:"from_user_id"`
+	ToUserID   string        `json:"to_user_id"`
+	Status     models.Status `json:"status"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+	CreatedAt  time.Time     `json:"created_at"`
 }
 
 type ListProjectMembersResponse struct {
-	ID          string `json:"id"`
-	ProfilePic  string `json:"profile_pic"`
-	DisplayName string `json:"display_name"`
+	ID          string             `json:"id"`
+	ProfilePic  string             `json:"profile_pic"`
+	DisplayName string             `json:"display_name"`
+	Role        models.ProjectRole `json:"role"`
+}
+
+type UpdateProjectMemberRoleRequest struct {
+	Role models.ProjectRole `json:"role"`
+}
+
+type CreateProjectInviteRequest struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	// Role defaults to member
+	Role models.ProjectRole `json:"role"`
+}
+
+type ProjectInviteResponse struct {
+	ID          uint               `json:"id"`
+	ProjectID   uint               `json:"project_id"`
+	ProjectName string             `json:"project_name"`
+	UserID      string             `json:"user_id"`
+	InvitedBy   string             `json:"invited_by"`
+	Role        models.ProjectRole `json:"role"`
+	Status      models.Status      `json:"status"`
+	ExpiresAt   time.Time          `json:"expires_at"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+type CreateTaskStatusRequest struct {
+	Name     string                `json:"name"`
+	Category models.StatusCategory `json:"category"`
+	// Position defaults to after the project's last status
+	Position *int `json:"position"`
+	// WIPLimit is the most tasks the status can hold, if set
+	WIPLimit *int `json:"wip_limit"`
+}
+
+type UpdateTaskStatusRequest struct {
+	Name     string                `json:"name"`
+	Category models.StatusCategory `json:"category"`
+	Position *int                  `json:"position"`
+	// WIPLimit of 0 removes the limit
+	WIPLimit *int `json:"wip_limit"`
+}
+
+type TaskStatusTransitionRequest struct {
+	FromStatusID uint `json:"from_status_id"`
+	ToStatusID   uint `json:"to_status_id"`
+}
+
+type CreateLabelRequest struct {
+	Name string `json:"name"`
+	// Color is a hex color like #d73a4a
+	Color string `json:"color"`
+}
+
+type UpdateLabelRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type BoardResponse struct {
+	ProjectID uint          `json:"project_id"`
+	Columns   []BoardColumn `json:"columns"`
+}
+
+// BoardColumn is a status with its tasks in rank order. OverLimit is set when the
+// status holds more tasks than its WIP limit, which happens if the limit is lowered.
+type BoardColumn struct {
+	Status    models.TaskStatus `json:"status"`
+	Tasks     []models.Task     `json:"tasks"`
+	Count     int               `json:"count"`
+	OverLimit bool              `json:"over_limit"`
 }
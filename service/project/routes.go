@@ -12,11 +12,38 @@ func (s *projectService) routes() {
 
 	r.HandleFunc("/", s.ListProjectsHandler).Methods(http.MethodGet)
 	r.HandleFunc("/", s.CreateProjectHandler).Methods(http.MethodPost)
+	r.HandleFunc("/invites", s.ListMyProjectInvitesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/invites/{invite_id:[0-9]+}/accept", s.AcceptProjectInviteHandler).Methods(http.MethodPost)
+	r.HandleFunc("/invites/{invite_id:[0-9]+}/decline", s.DeclineProjectInviteHandler).Methods(http.MethodPost)
 	r.HandleFunc("/{id}", s.GetProjectHandler).Methods(http.MethodGet)
 	r.HandleFunc("/{id}", s.UpdateProjectHandler).Methods(http.MethodPatch)
 	r.HandleFunc("/{id}", s.DeleteProjectHandler).Methods(http.MethodDelete)
 
 	r.HandleFunc("/{id}/members", s.ListProjectMembers).Methods(http.MethodGet)
-	r.HandleFunc("/{project_id}/members/{member_id}", s.AddProjectMember).Methods(http.MethodPut)
 	r.HandleFunc("/{project_id}/members/{member_id}", s.RemoveProjectMember).Methods(http.MethodDelete)
+	r.HandleFunc("/{id}/members/{member_id}", s.UpdateProjectMemberRoleHandler).Methods(http.MethodPatch)
+
+	r.HandleFunc("/{id:[0-9]+}/transfer", s.TransferProjectHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/transfer", s.GetProjectTransferHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/transfer", s.CancelProjectTransferHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/{id:[0-9]+}/transfer/accept", s.AcceptProjectTransferHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/transfer/decline", s.DeclineProjectTransferHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/history", s.ListProjectHistoryHandler).Methods(http.MethodGet)
+
+	r.HandleFunc("/{id:[0-9]+}/statuses", s.ListTaskStatusesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/statuses", s.CreateTaskStatusHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/statuses/{status_id:[0-9]+}", s.UpdateTaskStatusHandler).Methods(http.MethodPatch)
+	r.HandleFunc("/{id:[0-9]+}/statuses/{status_id:[0-9]+}", s.DeleteTaskStatusHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/{id:[0-9]+}/transitions", s.ListTaskStatusTransitionsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/transitions", s.ReplaceTaskStatusTransitionsHandler).Methods(http.MethodPut)
+	r.HandleFunc("/{id:[0-9]+}/board", s.GetBoardHandler).Methods(http.MethodGet)
+
+	r.HandleFunc("/{id:[0-9]+}/labels", s.ListLabelsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/labels", s.CreateLabelHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/labels/{label_id:[0-9]+}", s.UpdateLabelHandler).Methods(http.MethodPatch)
+	r.HandleFunc("/{id:[0-9]+}/labels/{label_id:[0-9]+}", s.DeleteLabelHandler).Methods(http.MethodDelete)
+
+	r.HandleFunc("/{id}/invites", s.CreateProjectInviteHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id}/invites", s.ListProjectInvitesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id}/invites/{invite_id:[0-9]+}", s.RevokeProjectInviteHandler).Methods(http.MethodDelete)
 }
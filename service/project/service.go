@@ -10,7 +10,9 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 
+	"github.com/todanni/api/email"
 	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
 	"github.com/todanni/api/repository"
 	"github.com/todanni/api/token"
 )
@@ -23,21 +25,70 @@ type ProjectsService interface {
 	DeleteProjectHandler(w http.ResponseWriter, r *http.Request)
 
 	ListProjectMembers(w http.ResponseWriter, r *http.Request)
-	AddProjectMember(w http.ResponseWriter, r *http.Request)
 	RemoveProjectMember(w http.ResponseWriter, r *http.Request)
+	UpdateProjectMemberRoleHandler(w http.ResponseWriter, r *http.Request)
+
+	TransferProjectHandler(w http.ResponseWriter, r *http.Request)
+	GetProjectTransferHandler(w http.ResponseWriter, r *http.Request)
+	AcceptProjectTransferHandler(w http.ResponseWriter, r *http.Request)
+	DeclineProjectTransferHandler(w http.ResponseWriter, r *http.Request)
+	CancelProjectTransferHandler(w http.ResponseWriter, r *http.Request)
+	ListProjectHistoryHandler(w http.ResponseWriter, r *http.Request)
+
+	ListTaskStatusesHandler(w http.ResponseWriter, r *http.Request)
+	CreateTaskStatusHandler(w http.ResponseWriter, r *http.Request)
+	UpdateTaskStatusHandler(w http.ResponseWriter, r *http.Request)
+	DeleteTaskStatusHandler(w http.ResponseWriter, r *http.Request)
+	ListTaskStatusTransitionsHandler(w http.ResponseWriter, r *http.Request)
+	ReplaceTaskStatusTransitionsHandler(w http.ResponseWriter, r *http.Request)
+	GetBoardHandler(w http.ResponseWriter, r *http.Request)
+
+	ListLabelsHandler(w http.ResponseWriter, r *http.Request)
+	CreateLabelHandler(w http.ResponseWriter, r *http.Request)
+	UpdateLabelHandler(w http.ResponseWriter, r *http.Request)
+	DeleteLabelHandler(w http.ResponseWriter, r *http.Request)
+
+	CreateProjectInviteHandler(w http.ResponseWriter, r *http.Request)
+	ListProjectInvitesHandler(w http.ResponseWriter, r *http.Request)
+	RevokeProjectInviteHandler(w http.ResponseWriter, r *http.Request)
+	ListMyProjectInvitesHandler(w http.ResponseWriter, r *http.Request)
+	AcceptProjectInviteHandler(w http.ResponseWriter, r *http.Request)
+	DeclineProjectInviteHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type projectService struct {
-	router     *mux.Router
-	repo       repository.ProjectRepository
-	middleware token.AuthMiddleware
+	router      *mux.Router
+	repo        repository.ProjectRepository
+	userRepo    repository.UserRepository
+	statusRepo  repository.TaskStatusRepository
+	taskRepo    repository.TaskRepository
+	labelRepo   repository.LabelRepository
+	emailClient email.SenderClient
+	middleware  token.AuthMiddleware
+	permissions permission.Checker
 }
 
-func NewProjectService(router *mux.Router, mw token.AuthMiddleware, repo repository.ProjectRepository) ProjectsService {
+func NewProjectService(
+	router *mux.Router,
+	mw token.AuthMiddleware,
+	repo repository.ProjectRepository,
+	userRepo repository.UserRepository,
+	statusRepo repository.TaskStatusRepository,
+	taskRepo repository.TaskRepository,
+	labelRepo repository.LabelRepository,
+	emailClient email.SenderClient,
+	permissions permission.Checker,
+) ProjectsService {
 	service := &projectService{
-		router:     router,
-		repo:       repo,
-		middleware: mw,
+		router:      router,
+		repo:        repo,
+		userRepo:    userRepo,
+		statusRepo:  statusRepo,
+		taskRepo:    taskRepo,
+		labelRepo:   labelRepo,
+		emailClient: emailClient,
+		middleware:  mw,
+		permissions: permissions,
 	}
 	service.routes()
 	return service
@@ -60,6 +111,9 @@ func (s *projectService) ListProjectsHandler(w http.ResponseWriter, r *http.Requ
 
 	var response []ListProjectsResponse
 	for _, project := range projects {
+		if !accessToken.AllowsProject(project.ID) {
+			continue
+		}
 		response = append(response, ListProjectsResponse{
 			ID:        project.ID,
 			Name:      project.Name,
@@ -105,16 +159,12 @@ func (s *projectService) CreateProjectHandler(w http.ResponseWriter, r *http.Req
 	project, err := s.repo.CreateProject(models.Project{
 		Name:  createRequest.Name,
 		Owner: userID,
-		Members: []models.User{
-			{
-				ID: userID,
-			},
-		},
 	})
 	if err != nil {
 		http.Error(w, "couldn't create project", http.StatusInternalServerError)
 		return
 	}
+	s.permissions.Invalidate(userID)
 
 	response := CreateProjectResponse{
 		ID:        project.ID,
@@ -147,7 +197,7 @@ func (s *projectService) GetProjectHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
-	if !accessToken.HasProjectPermission(uint(projectIDStr)) {
+	if !s.permissions.HasProjectPermission(accessToken, uint(projectIDStr)) {
 		http.Error(w, "you don't have access to this project", http.StatusForbidden)
 		return
 	}
@@ -186,7 +236,7 @@ func (s *projectService) DeleteProjectHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if project.Owner != userID {
+	if !s.permissions.Can(accessToken, project.ID, permission.DeleteProject) {
 		http.Error(w, "only the project owner can delete a project", http.StatusForbidden)
 		return
 	}
@@ -212,7 +262,7 @@ func (s *projectService) ListProjectMembers(w http.ResponseWriter, r *http.Reque
 	}
 
 	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
-	if !accessToken.HasProjectPermission(uint(projectIDStr)) {
+	if !s.permissions.HasProjectPermission(accessToken, uint(projectIDStr)) {
 		http.Error(w, "you don't have access to this project", http.StatusForbidden)
 		return
 	}
@@ -227,9 +277,10 @@ func (s *projectService) ListProjectMembers(w http.ResponseWriter, r *http.Reque
 	var response []ListProjectMembersResponse
 	for _, member := range projectMembers {
 		response = append(response, ListProjectMembersResponse{
-			ID:          member.ID,
-			ProfilePic:  member.ProfilePic,
-			DisplayName: member.DisplayName,
+			ID:          member.User.ID,
+			ProfilePic:  member.User.ProfilePic,
+			DisplayName: member.User.DisplayName,
+			Role:        member.Role,
 		})
 	}
 
@@ -243,7 +294,7 @@ func (s *projectService) ListProjectMembers(w http.ResponseWriter, r *http.Reque
 	w.Write(responseBody)
 }
 
-func (s *projectService) AddProjectMember(w http.ResponseWriter, r *http.Request) {
+func (s *projectService) RemoveProjectMember(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	projectIDStr := params["project_id"]
 	memberID := params["member_id"]
@@ -262,36 +313,40 @@ func (s *projectService) AddProjectMember(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if project.Owner != userID {
-		http.Error(w, "only the project owner can add members to a project", http.StatusForbidden)
+	memberRole, err := s.repo.GetProjectRole(memberID, project.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up project member", http.StatusInternalServerError)
 		return
 	}
-
-	if userID == memberID {
-		http.Error(w, "you're already a part of this project", http.StatusBadRequest)
+	if memberRole == "" {
+		http.Error(w, "couldn't find project member", http.StatusNotFound)
 		return
 	}
 
-	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
-	if err != nil {
-		log.Error(err)
-		http.Error(w, "invalid member ID", http.StatusBadRequest)
+	// Anyone but the owner can leave a project, otherwise it depends on the roles
+	switch {
+	case memberRole == models.OwnerRole:
+		http.Error(w, "the project owner can't be removed from a project", http.StatusForbidden)
+		return
+	case userID != memberID && !permission.CanAssignRole(s.permissions.ProjectRole(accessToken, project.ID), memberRole):
+		http.Error(w, "you don't have permission to remove this member", http.StatusForbidden)
 		return
 	}
 
-	err = s.repo.AddProjectMember(memberID, uint(projectID))
+	err = s.repo.RemoveProjectMember(memberID, project.ID)
 	if err != nil {
 		log.Error(err)
-		http.Error(w, "couldn't add member to project", http.StatusInternalServerError)
+		http.Error(w, "couldn't remove member from project", http.StatusInternalServerError)
 		return
 	}
+	s.permissions.Invalidate(memberID)
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *projectService) RemoveProjectMember(w http.ResponseWriter, r *http.Request) {
+func (s *projectService) UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
-	projectIDStr := params["project_id"]
-	memberID := params["member_id"]
+	projectIDStr := params["id"]
 
 	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
 	userID := accessToken.GetUserID()
@@ -307,35 +362,52 @@ func (s *projectService) RemoveProjectMember(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if project.Owner != userID {
-		http.Error(w, "only the project owner can add members to a project", http.StatusForbidden)
+	if !s.permissions.Can(accessToken, project.ID, permission.EditProject) {
+		http.Error(w, "you don't have permission to update this project", http.StatusForbidden)
+		return
+	}
+
+	var updateRequest UpdateProjectRequest
+	err = json.NewDecoder(r.Body).Decode(&updateRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if userID == memberID {
-		http.Error(w, "you're already a part of this project", http.StatusBadRequest)
+	if err = validation.ValidateStruct(&updateRequest,
+		validation.Field(&updateRequest.Name, validation.Required),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
+	updatedProject, err := s.repo.UpdateProject(models.Project{
+		Model: gorm.Model{
+			ID: project.ID,
+		},
+		Name: updateRequest.Name,
+	})
+
 	if err != nil {
 		log.Error(err)
-		http.Error(w, "invalid member ID", http.StatusBadRequest)
+		http.Error(w, "couldn't update project", http.StatusInternalServerError)
 		return
 	}
 
-	err = s.repo.RemoveProjectMember(memberID, uint(projectID))
+	responseBody, err := json.Marshal(updatedProject)
 	if err != nil {
-		log.Error(err)
-		http.Error(w, "couldn't remove member from project", http.StatusInternalServerError)
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
 }
 
-func (s *projectService) UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
+func (s *projectService) UpdateProjectMemberRoleHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
-	projectIDStr := params["project_id"]
+	projectIDStr := params["id"]
+	memberID := params["member_id"]
 
 	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
 	userID := accessToken.GetUserID()
@@ -351,44 +423,42 @@ func (s *projectService) UpdateProjectHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if project.Owner != userID {
-		http.Error(w, "only the project owner can update a project", http.StatusForbidden)
-		return
-	}
-
-	var updateRequest UpdateProjectRequest
+	var updateRequest UpdateProjectMemberRoleRequest
 	err = json.NewDecoder(r.Body).Decode(&updateRequest)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
-	if err != nil {
-		log.Error(err)
-		http.Error(w, "invalid member ID", http.StatusBadRequest)
+
+	if !permission.IsValidRole(updateRequest.Role) {
+		http.Error(w, "invalid role", http.StatusBadRequest)
 		return
 	}
 
-	updatedProject, err := s.repo.UpdateProject(models.Project{
-		Model: gorm.Model{
-			ID: uint(projectID),
-		},
-		Name:  updateRequest.Name,
-		Owner: updateRequest.Owner,
-	})
-
+	memberRole, err := s.repo.GetProjectRole(memberID, project.ID)
 	if err != nil {
 		log.Error(err)
-		http.Error(w, "couldn't update project", http.StatusInternalServerError)
+		http.Error(w, "couldn't look up project member", http.StatusInternalServerError)
+		return
+	}
+	if memberRole == "" {
+		http.Error(w, "couldn't find project member", http.StatusNotFound)
 		return
 	}
 
-	responseBody, err := json.Marshal(updatedProject)
-	if err != nil {
-		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+	// The caller has to be allowed to manage both the member's current role and the new one
+	callerRole := s.permissions.ProjectRole(accessToken, project.ID)
+	if !permission.CanAssignRole(callerRole, memberRole) || !permission.CanAssignRole(callerRole, updateRequest.Role) {
+		http.Error(w, "you don't have permission to give this member that role", http.StatusForbidden)
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.Write(responseBody)
+	err = s.repo.UpdateProjectMemberRole(memberID, project.ID, updateRequest.Role)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update member role", http.StatusInternalServerError)
+		return
+	}
+	s.permissions.Invalidate(memberID)
+	w.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,354 @@
+package project
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/token"
+)
+
+func (s *projectService) ListTaskStatusesHandler(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	statuses, err := s.statusRepo.ListTaskStatuses(projectID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list task statuses", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(statuses)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *projectService) CreateTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.EditProject)
+	if !ok {
+		return
+	}
+
+	var createRequest CreateTaskStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = validation.ValidateStruct(&createRequest,
+		validation.Field(&createRequest.Name, validation.Required),
+		validation.Field(&createRequest.Category, validation.Required, validation.In(
+			models.OpenCategory, models.ActiveCategory, models.ClosedCategory)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if createRequest.WIPLimit != nil && *createRequest.WIPLimit < 1 {
+		http.Error(w, "wip_limit must be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	// New statuses go at the end unless a position is given
+	position := 0
+	if createRequest.Position != nil {
+		position = *createRequest.Position
+	} else {
+		statuses, err := s.statusRepo.ListTaskStatuses(projectID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't list task statuses", http.StatusInternalServerError)
+			return
+		}
+		for _, status := range statuses {
+			if status.Position >= position {
+				position = status.Position + 1
+			}
+		}
+	}
+
+	status, err := s.statusRepo.CreateTaskStatus(models.TaskStatus{
+		ProjectID: projectID,
+		Name:      createRequest.Name,
+		Position:  position,
+		Category:  createRequest.Category,
+		WIPLimit:  createRequest.WIPLimit,
+	})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't create task status", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+func (s *projectService) UpdateTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
+	status, statuses, ok := s.getTaskStatus(w, r)
+	if !ok {
+		return
+	}
+
+	var updateRequest UpdateTaskStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&updateRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = validation.ValidateStruct(&updateRequest,
+		validation.Field(&updateRequest.Category, validation.In(
+			models.OpenCategory, models.ActiveCategory, models.ClosedCategory)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if updateRequest.Category != "" && updateRequest.Category != status.Category {
+		if !hasOtherStatusInCategory(statuses, status) {
+			http.Error(w, "a project needs at least one open and one closed status", http.StatusConflict)
+			return
+		}
+		status.Category = updateRequest.Category
+	}
+	if updateRequest.Name != "" {
+		status.Name = updateRequest.Name
+	}
+	if updateRequest.Position != nil {
+		status.Position = *updateRequest.Position
+	}
+	if updateRequest.WIPLimit != nil {
+		switch {
+		case *updateRequest.WIPLimit < 0:
+			http.Error(w, "wip_limit can't be negative", http.StatusBadRequest)
+			return
+		case *updateRequest.WIPLimit == 0:
+			status.WIPLimit = nil
+		default:
+			status.WIPLimit = updateRequest.WIPLimit
+		}
+	}
+
+	status, err = s.statusRepo.UpdateTaskStatus(status)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update task status", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// DeleteTaskStatusHandler deletes a status, moving its tasks to the status given in the
+// move_to query parameter. Without it, they're moved to another status in the same category.
+func (s *projectService) DeleteTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
+	status, statuses, ok := s.getTaskStatus(w, r)
+	if !ok {
+		return
+	}
+
+	if !hasOtherStatusInCategory(statuses, status) {
+		http.Error(w, "a project needs at least one open and one closed status", http.StatusConflict)
+		return
+	}
+
+	var moveTo *models.TaskStatus
+	moveToStr := r.URL.Query().Get("move_to")
+	for i, other := range statuses {
+		if other.ID == status.ID {
+			continue
+		}
+		if (moveToStr != "" && strconv.FormatUint(uint64(other.ID), 10) == moveToStr) ||
+			(moveToStr == "" && moveTo == nil && other.Category == status.Category) {
+			moveTo = &statuses[i]
+		}
+	}
+	if moveTo == nil {
+		http.Error(w, "invalid move_to status", http.StatusBadRequest)
+		return
+	}
+
+	err := s.statusRepo.DeleteTaskStatus(status, *moveTo)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't delete task status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *projectService) ListTaskStatusTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	transitions, err := s.statusRepo.ListTaskStatusTransitions(projectID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list task status transitions", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]TaskStatusTransitionRequest, 0, len(transitions))
+	for _, transition := range transitions {
+		response = append(response, TaskStatusTransitionRequest{
+			FromStatusID: transition.FromStatusID,
+			ToStatusID:   transition.ToStatusID,
+		})
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// ReplaceTaskStatusTransitionsHandler sets which moves between statuses are allowed.
+// Statuses with no transitions from them allow moving to any status.
+func (s *projectService) ReplaceTaskStatusTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.EditProject)
+	if !ok {
+		return
+	}
+
+	var replaceRequest []TaskStatusTransitionRequest
+	err := json.NewDecoder(r.Body).Decode(&replaceRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statuses, err := s.statusRepo.ListTaskStatuses(projectID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list task statuses", http.StatusInternalServerError)
+		return
+	}
+
+	projectStatuses := make(map[uint]bool)
+	for _, status := range statuses {
+		projectStatuses[status.ID] = true
+	}
+
+	transitions := make([]models.TaskStatusTransition, 0, len(replaceRequest))
+	for _, transition := range replaceRequest {
+		if !projectStatuses[transition.FromStatusID] || !projectStatuses[transition.ToStatusID] {
+			http.Error(w, "transitions must be between statuses of this project", http.StatusBadRequest)
+			return
+		}
+		transitions = append(transitions, models.TaskStatusTransition{
+			ProjectID:    projectID,
+			FromStatusID: transition.FromStatusID,
+			ToStatusID:   transition.ToStatusID,
+		})
+	}
+
+	err = s.statusRepo.ReplaceTaskStatusTransitions(projectID, transitions)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update task status transitions", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// projectIDWithPermission returns the project ID from the request path, checking the
+// caller's role allows the action. It writes the error response and returns false if not.
+func (s *projectService) projectIDWithPermission(w http.ResponseWriter, r *http.Request, action permission.Action) (uint, bool) {
+	params := mux.Vars(r)
+	projectID, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid project ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	if accessToken.GetUserID() == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	if !s.permissions.Can(accessToken, uint(projectID), action) {
+		http.Error(w, "you don't have permission to do this in this project", http.StatusForbidden)
+		return 0, false
+	}
+	return uint(projectID), true
+}
+
+// getTaskStatus returns the status from the request path along with all of the project's
+// statuses, checking the caller can edit the project. It writes the error response and
+// returns false if it can't.
+func (s *projectService) getTaskStatus(w http.ResponseWriter, r *http.Request) (models.TaskStatus, []models.TaskStatus, bool) {
+	projectID, ok := s.projectIDWithPermission(w, r, permission.EditProject)
+	if !ok {
+		return models.TaskStatus{}, nil, false
+	}
+
+	statuses, err := s.statusRepo.ListTaskStatuses(projectID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't list task statuses", http.StatusInternalServerError)
+		return models.TaskStatus{}, nil, false
+	}
+
+	statusID := mux.Vars(r)["status_id"]
+	for _, status := range statuses {
+		if strconv.FormatUint(uint64(status.ID), 10) == statusID {
+			return status, statuses, true
+		}
+	}
+
+	http.Error(w, "couldn't find task status", http.StatusNotFound)
+	return models.TaskStatus{}, nil, false
+}
+
+// hasOtherStatusInCategory returns whether the status can be removed from its category.
+// Only the open and closed categories need to keep at least one status, so that tasks
+// can still be marked as done or not done.
+func hasOtherStatusInCategory(statuses []models.TaskStatus, status models.TaskStatus) bool {
+	if status.Category == models.ActiveCategory {
+		return true
+	}
+
+	for _, other := range statuses {
+		if other.ID != status.ID && other.Category == status.Category {
+			return true
+		}
+	}
+	return false
+}
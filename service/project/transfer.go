@@ -0,0 +1,299 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+var (
+	// TransferExpirationTime is how long the new owner has to accept a project transfer.
+	TransferExpirationTime = 7 * 24 * time.Hour
+
+	errNoTransferTarget    = errors.New("user_id must be set")
+	errTransferToOwner     = errors.New("you already own this project")
+	errTransferToNonMember = errors.New("the new owner must be a member of the project")
+)
+
+// TransferProjectHandler hands the project over to another member. The previous owner
+// stays on as an admin. If the request asks for it, the new owner has to accept first.
+func (s *projectService) TransferProjectHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	projectIDStr := params["id"]
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	project, err := s.repo.GetProjectByID(projectIDStr)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't find project", http.StatusNotFound)
+		return
+	}
+
+	if project.Owner != userID || !s.permissions.Can(accessToken, project.ID, permission.TransferProject) {
+		http.Error(w, "only the project owner can transfer a project", http.StatusForbidden)
+		return
+	}
+
+	var transferRequest TransferProjectRequest
+	err = json.NewDecoder(r.Body).Decode(&transferRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var role models.ProjectRole
+	if transferRequest.UserID != "" && transferRequest.UserID != userID {
+		role, err = s.repo.GetProjectRole(transferRequest.UserID, project.ID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't look up project member", http.StatusInternalServerError)
+			return
+		}
+	}
+	err = checkTransferTarget(userID, transferRequest.UserID, role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transfer := newProjectTransfer(project.ID, userID, transferRequest.UserID, time.Now())
+
+	if transferRequest.RequireAcceptance {
+		transfer, err = s.repo.CreateProjectTransfer(transfer)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't create project transfer", http.StatusInternalServerError)
+			return
+		}
+
+		responseBody, err := json.Marshal(toProjectTransferResponse(transfer))
+		if err != nil {
+			http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(responseBody)
+		return
+	}
+
+	if !s.transferOwnership(w, transfer, userID) {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *projectService) GetProjectTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, ok := s.getPendingTransfer(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	responseBody, err := json.Marshal(toProjectTransferResponse(transfer))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *projectService) AcceptProjectTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, ok := s.getPendingTransfer(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	userID := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken).GetUserID()
+	if transfer.ToUserID != userID {
+		http.Error(w, "only the new owner can accept a project transfer", http.StatusForbidden)
+		return
+	}
+
+	if !s.transferOwnership(w, transfer, userID) {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *projectService) DeclineProjectTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, ok := s.getPendingTransfer(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	userID := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken).GetUserID()
+	if transfer.ToUserID != userID {
+		http.Error(w, "only the new owner can decline a project transfer", http.StatusForbidden)
+		return
+	}
+
+	err := s.repo.CloseProjectTransfer(transfer, models.RejectedStatus, userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't decline project transfer", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *projectService) CancelProjectTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, ok := s.getPendingTransfer(w, r, permission.TransferProject)
+	if !ok {
+		return
+	}
+
+	userID := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken).GetUserID()
+	err := s.repo.CloseProjectTransfer(transfer, models.RevokedStatus, userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't cancel project transfer", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *projectService) ListProjectHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	projectID, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	if !s.permissions.HasProjectPermission(accessToken, uint(projectID)) {
+		http.Error(w, "you don't have access to this project", http.StatusForbidden)
+		return
+	}
+
+	events, err := s.repo.ListProjectEvents(uint(projectID))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't retrieve project history", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(events)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// getPendingTransfer looks up the pending transfer of the project in the request path,
+// checking the caller's role in the project allows the action. It writes the error
+// response and returns false if it can't.
+func (s *projectService) getPendingTransfer(w http.ResponseWriter, r *http.Request, action permission.Action) (models.ProjectTransfer, bool) {
+	params := mux.Vars(r)
+	projectID, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid project ID", http.StatusBadRequest)
+		return models.ProjectTransfer{}, false
+	}
+
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	if accessToken.GetUserID() == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return models.ProjectTransfer{}, false
+	}
+
+	if !s.permissions.Can(accessToken, uint(projectID), action) {
+		http.Error(w, "you don't have access to this project", http.StatusForbidden)
+		return models.ProjectTransfer{}, false
+	}
+
+	transfer, err := s.repo.GetPendingProjectTransfer(uint(projectID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "there's no pending transfer for this project", http.StatusNotFound)
+		return models.ProjectTransfer{}, false
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up project transfer", http.StatusInternalServerError)
+		return models.ProjectTransfer{}, false
+	}
+	return transfer, true
+}
+
+// transferOwnership makes the transfer's new owner the owner of the project. It writes
+// the error response and returns false if it can't.
+func (s *projectService) transferOwnership(w http.ResponseWriter, transfer models.ProjectTransfer, actorID string) bool {
+	err := s.repo.TransferProjectOwnership(transfer, actorID)
+	switch {
+	case errors.Is(err, repository.ErrProjectOwnerChanged):
+		http.Error(w, "the project has changed owner since the transfer was requested", http.StatusConflict)
+		return false
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "the new owner is no longer a member of the project", http.StatusConflict)
+		return false
+	case err != nil:
+		log.Error(err)
+		http.Error(w, "couldn't transfer project", http.StatusInternalServerError)
+		return false
+	}
+
+	s.permissions.Invalidate(transfer.FromUserID)
+	s.permissions.Invalidate(transfer.ToUserID)
+	return true
+}
+
+// checkTransferTarget checks the owner can hand the project over to the user, whose role
+// in the project is empty if they aren't a member. The new owner has to already be one.
+func checkTransferTarget(ownerID string, userID string, role models.ProjectRole) error {
+	switch {
+	case userID == "":
+		return errNoTransferTarget
+	case userID == ownerID:
+		return errTransferToOwner
+	case role == "":
+		return errTransferToNonMember
+	}
+	return nil
+}
+
+// newProjectTransfer returns a pending transfer of the project, which the new owner has
+// until TransferExpirationTime after now to accept.
+func newProjectTransfer(projectID uint, fromUserID string, toUserID string, now time.Time) models.ProjectTransfer {
+	return models.ProjectTransfer{
+		ProjectID:  projectID,
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		Status:     models.PendingStatus,
+		ExpiresAt:  now.Add(TransferExpirationTime),
+	}
+}
+
+func toProjectTransferResponse(transfer models.ProjectTransfer) ProjectTransferResponse {
+	return ProjectTransferResponse{
+		ID:         transfer.ID,
+		ProjectID:  transfer.ProjectID,
+		FromUserID: transfer.FromUserID,
+		ToUserID:   transfer.ToUserID,
+		Status:     transfer.CurrentStatus(time.Now()),
+		ExpiresAt:  transfer.ExpiresAt,
+		CreatedAt:  transfer.CreatedAt,
+	}
+}
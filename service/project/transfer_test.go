@@ -0,0 +1,64 @@
+package project
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestCheckTransferTarget(t *testing.T) {
+	require.NoError(t, checkTransferTarget("owner", "member", models.MemberRole))
+	require.NoError(t, checkTransferTarget("owner", "viewer", models.ViewerRole))
+
+	require.ErrorIs(t, checkTransferTarget("owner", "", ""), errNoTransferTarget)
+	require.ErrorIs(t, checkTransferTarget("owner", "owner", models.OwnerRole), errTransferToOwner)
+
+	// Only members can be handed the project
+	require.ErrorIs(t, checkTransferTarget("owner", "stranger", ""), errTransferToNonMember)
+}
+
+func TestNewProjectTransfer(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	transfer := newProjectTransfer(1, "owner", "member", now)
+
+	require.Equal(t, uint(1), transfer.ProjectID)
+	require.Equal(t, "owner", transfer.FromUserID)
+	require.Equal(t, "member", transfer.ToUserID)
+
+	// Transfers that need accepting stay pending until they run out
+	require.Equal(t, models.PendingStatus, transfer.CurrentStatus(now))
+	require.Equal(t, models.PendingStatus, transfer.CurrentStatus(now.Add(TransferExpirationTime)))
+	require.Equal(t, models.ExpiredStatus, transfer.CurrentStatus(now.Add(TransferExpirationTime+time.Second)))
+
+	transfer.Status = models.AcceptedStatus
+	require.Equal(t, models.AcceptedStatus, transfer.CurrentStatus(now.Add(TransferExpirationTime+time.Second)))
+}
+
+func TestProjectTransferRoleChanges(t *testing.T) {
+	transfer := newProjectTransfer(1, "owner", "member", time.Now())
+
+	// The new owner comes first, so the transfer fails if they've left the project
+	require.Equal(t, []models.ProjectMember{
+		{ProjectID: 1, UserID: "member", Role: models.OwnerRole},
+		{ProjectID: 1, UserID: "owner", Role: models.AdminRole},
+	}, transfer.RoleChanges())
+}
+
+func TestProjectTransferEvent(t *testing.T) {
+	transfer := newProjectTransfer(1, "owner", "member", time.Now())
+
+	require.Equal(t, models.ProjectEvent{
+		ProjectID:  1,
+		Type:       models.OwnerChangedEvent,
+		ActorID:    "member",
+		FromUserID: "owner",
+		ToUserID:   "member",
+	}, transfer.Event(models.OwnerChangedEvent, "member"))
+
+	event := transfer.Event(models.TransferCancelledEvent, "owner")
+	require.Equal(t, models.TransferCancelledEvent, event.Type)
+	require.Equal(t, "owner", event.ActorID)
+}
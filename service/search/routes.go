@@ -0,0 +1,14 @@
+package search
+
+import "net/http"
+
+const (
+	APIPath = "/search"
+)
+
+func (s *searchService) routes() {
+	r := s.router.PathPrefix(APIPath).Subrouter()
+	r.Use(s.middleware.JwtMiddleware)
+
+	r.HandleFunc("/", s.SearchHandler).Methods(http.MethodGet)
+}
@@ -0,0 +1,125 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+	// MaxQueryLength keeps searches to something a person would type
+	MaxQueryLength = 256
+)
+
+type SearchService interface {
+	SearchHandler(w http.ResponseWriter, r *http.Request)
+}
+
+type searchService struct {
+	router     *mux.Router
+	repo       repository.SearchRepository
+	middleware token.AuthMiddleware
+}
+
+func NewSearchService(r *mux.Router, repo repository.SearchRepository, mw token.AuthMiddleware) SearchService {
+	service := &searchService{
+		router:     r,
+		repo:       repo,
+		middleware: mw,
+	}
+	service.routes()
+	return service
+}
+
+// SearchHandler searches the tasks, comments and projects the caller can see for the
+// text in q, best matches first. The results can be narrowed with a comma separated list
+// of types and project_id, and limit caps how many are returned.
+func (s *searchService) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	query, ok := parseSearchQuery(w, r)
+	if !ok {
+		return
+	}
+	query.MemberID = accessToken.GetUserID()
+	query.AllowedProjectIDs = accessToken.AllowedProjects()
+
+	results, err := s.repo.Search(query)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't search", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(results)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// parseSearchQuery reads the search from the request's parameters. It writes the error
+// response and returns false if they're invalid.
+func parseSearchQuery(w http.ResponseWriter, r *http.Request) (repository.SearchQuery, bool) {
+	params := r.URL.Query()
+	query := repository.SearchQuery{
+		Text:  strings.TrimSpace(params.Get("q")),
+		Limit: DefaultLimit,
+	}
+
+	if query.Text == "" {
+		http.Error(w, "the search needs some text", http.StatusBadRequest)
+		return repository.SearchQuery{}, false
+	}
+	if len(query.Text) > MaxQueryLength {
+		http.Error(w, fmt.Sprintf("searches can't be longer than %d characters", MaxQueryLength), http.StatusBadRequest)
+		return repository.SearchQuery{}, false
+	}
+
+	for _, value := range strings.Split(params.Get("types"), ",") {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		searchType := repository.SearchType(value)
+		switch searchType {
+		case repository.TaskResult, repository.CommentResult, repository.ProjectResult:
+			query.Types = append(query.Types, searchType)
+		default:
+			http.Error(w, "types must be task, comment or project", http.StatusBadRequest)
+			return repository.SearchQuery{}, false
+		}
+	}
+
+	if projectID := params.Get("project_id"); projectID != "" {
+		id, err := strconv.ParseUint(projectID, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid project ID", http.StatusBadRequest)
+			return repository.SearchQuery{}, false
+		}
+		query.ProjectIDs = []uint{uint(id)}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > MaxLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", MaxLimit), http.StatusBadRequest)
+			return repository.SearchQuery{}, false
+		}
+	}
+	return query, true
+}
@@ -0,0 +1,50 @@
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/repository"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	parse := func(target string) (repository.SearchQuery, int) {
+		w := httptest.NewRecorder()
+		query, ok := parseSearchQuery(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if !ok {
+			return query, w.Code
+		}
+		return query, http.StatusOK
+	}
+
+	query, code := parse("/search/?q=+login+bug+&types=task,Comment&project_id=3&limit=5")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, repository.SearchQuery{
+		Text:       "login bug",
+		Types:      []repository.SearchType{repository.TaskResult, repository.CommentResult},
+		ProjectIDs: []uint{3},
+		Limit:      5,
+	}, query)
+
+	query, code = parse("/search/?q=login")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, DefaultLimit, query.Limit)
+	require.Empty(t, query.Types)
+
+	for _, target := range []string{
+		"/search/",
+		"/search/?q=+",
+		"/search/?q=" + strings.Repeat("a", MaxQueryLength+1),
+		"/search/?q=login&types=dashboard",
+		"/search/?q=login&project_id=abc",
+		"/search/?q=login&limit=0",
+		"/search/?q=login&limit=1000",
+	} {
+		_, code = parse(target)
+		require.Equal(t, http.StatusBadRequest, code, target)
+	}
+}
@@ -0,0 +1,232 @@
+package task
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+// agendaDays is how many days after today the agenda looks ahead
+const agendaDays = 7
+
+// AgendaHandler returns the caller's open tasks that are overdue, due today, due in the
+// next week or have no deadline, along with the tasks they picked for today.
+func (s *taskService) AgendaHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	today, ok := s.startOfToday(w, userID)
+	if !ok {
+		return
+	}
+
+	open := false
+	weekEnd := today.AddDate(0, 0, agendaDays+1)
+	filter := repository.TaskFilter{
+		MemberID:          userID,
+		AllowedProjectIDs: accessToken.AllowedProjects(),
+		Involves:          []string{userID},
+		Done:              &open,
+		Deadline:          repository.TimeRange{To: &weekEnd},
+	}
+	dated, _, err := s.taskRepo.ListTasks(filter, repository.TaskPage{
+		Order: []repository.TaskOrder{{Field: repository.SortByDeadline}, {Field: repository.SortByPriority}},
+	})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up tasks for user", http.StatusInternalServerError)
+		return
+	}
+
+	filter.Deadline = repository.TimeRange{Missing: true}
+	undated, _, err := s.taskRepo.ListTasks(filter, repository.TaskPage{
+		Order: []repository.TaskOrder{{Field: repository.SortByPriority}},
+	})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up tasks for user", http.StatusInternalServerError)
+		return
+	}
+
+	picked, ok := s.todayTasks(w, accessToken, today)
+	if !ok {
+		return
+	}
+
+	response := buildAgenda(dated, undated, today)
+	response.Picked = picked
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// ListTodayHandler lists the tasks the caller picked for today, in the order they were picked.
+func (s *taskService) ListTodayHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	today, ok := s.startOfToday(w, userID)
+	if !ok {
+		return
+	}
+	s.writeTodayTasks(w, accessToken, today)
+}
+
+// AddTodayHandler adds the task to the end of the caller's list for today. The list
+// starts again empty each day in the caller's time zone.
+func (s *taskService) AddTodayHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	today, ok := s.startOfToday(w, accessToken.GetUserID())
+	if !ok {
+		return
+	}
+
+	err := s.today.AddTodayTask(accessToken.GetUserID(), dayKey(today), task.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't add task to today", http.StatusInternalServerError)
+		return
+	}
+	s.writeTodayTasks(w, accessToken, today)
+}
+
+// RemoveTodayHandler takes the task off the caller's list for today.
+func (s *taskService) RemoveTodayHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	taskID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	today, ok := s.startOfToday(w, userID)
+	if !ok {
+		return
+	}
+
+	err = s.today.RemoveTodayTask(userID, dayKey(today), uint(taskID))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't remove task from today", http.StatusInternalServerError)
+		return
+	}
+	s.writeTodayTasks(w, accessToken, today)
+}
+
+func (s *taskService) writeTodayTasks(w http.ResponseWriter, accessToken *token.ToDanniToken, today time.Time) {
+	tasks, ok := s.todayTasks(w, accessToken, today)
+	if !ok {
+		return
+	}
+
+	responseBody, err := json.Marshal(tasks)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// todayTasks returns the tasks the caller picked for the day that their token can see.
+// It writes the error response and returns false if they can't be looked up.
+func (s *taskService) todayTasks(w http.ResponseWriter, accessToken *token.ToDanniToken, today time.Time) ([]models.Task, bool) {
+	tasks, err := s.today.ListTodayTasks(accessToken.GetUserID(), dayKey(today))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up today's tasks", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	allowed := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if accessToken.AllowsProject(task.ProjectID) {
+			allowed = append(allowed, task)
+		}
+	}
+	return allowed, true
+}
+
+// startOfToday returns midnight at the start of the day in the user's time zone. It
+// writes the error response and returns false if the user can't be looked up.
+func (s *taskService) startOfToday(w http.ResponseWriter, userID string) (time.Time, bool) {
+	location, ok := s.userLocation(w, userID)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	now := time.Now().In(location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location), true
+}
+
+// dayKey is how days are kept in a user's list for today
+func dayKey(day time.Time) string {
+	return day.Format("2006-01-02")
+}
+
+// buildAgenda sorts tasks with a deadline into the days they're due, relative to the
+// start of today, and keeps their order within each bucket.
+func buildAgenda(dated, undated []models.Task, today time.Time) AgendaResponse {
+	response := AgendaResponse{
+		Overdue:   []models.Task{},
+		Today:     []models.Task{},
+		Next7Days: []models.Task{},
+		NoDate:    []models.Task{},
+		Picked:    []models.Task{},
+	}
+
+	tomorrow := today.AddDate(0, 0, 1)
+	weekEnd := today.AddDate(0, 0, agendaDays+1)
+	for _, task := range dated {
+		switch {
+		case task.Deadline.IsZero() || !task.Deadline.Before(weekEnd):
+			continue
+		case task.Deadline.Before(today):
+			response.Overdue = append(response.Overdue, task)
+		case task.Deadline.Before(tomorrow):
+			response.Today = append(response.Today, task)
+		default:
+			response.Next7Days = append(response.Next7Days, task)
+		}
+	}
+	response.NoDate = append(response.NoDate, undated...)
+	return response
+}
@@ -0,0 +1,45 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestBuildAgenda(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	today := time.Date(2023, time.March, 6, 0, 0, 0, 0, location)
+
+	dated := []models.Task{
+		{ID: 1, Deadline: today.Add(-time.Minute)},
+		// Just after midnight UTC, but still the evening before in New York
+		{ID: 2, Deadline: time.Date(2023, time.March, 6, 1, 0, 0, 0, time.UTC)},
+		{ID: 3, Deadline: today},
+		{ID: 4, Deadline: today.Add(23 * time.Hour)},
+		{ID: 5, Deadline: today.AddDate(0, 0, 1)},
+		{ID: 6, Deadline: today.AddDate(0, 0, 8).Add(-time.Minute)},
+		{ID: 7, Deadline: today.AddDate(0, 0, 8)},
+	}
+	undated := []models.Task{{ID: 8}, {ID: 9}}
+
+	agenda := buildAgenda(dated, undated, today)
+	require.Equal(t, []uint{1, 2}, taskIDs(agenda.Overdue))
+	require.Equal(t, []uint{3, 4}, taskIDs(agenda.Today))
+	require.Equal(t, []uint{5, 6}, taskIDs(agenda.Next7Days))
+	require.Equal(t, []uint{8, 9}, taskIDs(agenda.NoDate))
+
+	empty, err := json.Marshal(buildAgenda(nil, nil, today))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"overdue":[],"today":[],"next_7_days":[],"no_date":[],"picked":[]}`, string(empty))
+}
+
+func TestDayKey(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	require.Equal(t, "2023-03-07", dayKey(time.Date(2023, time.March, 6, 20, 0, 0, 0, time.UTC).In(location)))
+}
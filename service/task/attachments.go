@@ -0,0 +1,379 @@
+package task
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/blob"
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+// maxFileNameLength keeps file names within what file systems and object stores accept
+const maxFileNameLength = 255
+
+// multipartMemory is how much of an upload is held in memory before it's buffered to disk
+const multipartMemory = 8 << 20
+
+func (s *taskService) ListAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	attachments, err := s.attachments.ListAttachments(task.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up attachments", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]AttachmentResponse, 0, len(attachments))
+	for _, attachment := range attachments {
+		signed, err := s.signAttachment(attachment)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't sign attachment links", http.StatusInternalServerError)
+			return
+		}
+		response = append(response, signed)
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// UploadAttachmentHandler stores the multipart form's "file" and attaches it to the task.
+func (s *taskService) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.EditTask)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	// Leave room for the rest of the form around the file
+	r.Body = http.MaxBytesReader(w, r.Body, s.options.AttachmentMaxSize+multipartMemory)
+	err := r.ParseMultipartForm(multipartMemory)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("files can't be larger than %d bytes", s.options.AttachmentMaxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "the form needs a file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > s.options.AttachmentMaxSize {
+		http.Error(w, fmt.Sprintf("files can't be larger than %d bytes", s.options.AttachmentMaxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	fileName := cleanFileName(header.Filename)
+	if fileName == "" {
+		http.Error(w, "the file needs a name", http.StatusBadRequest)
+		return
+	}
+
+	contentType, err := attachmentContentType(header.Header.Get("Content-Type"), file)
+	if errors.Is(err, errContentTypeMismatch) {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't read file", http.StatusBadRequest)
+		return
+	}
+	if !contentTypeAllowed(contentType, s.options.AttachmentContentTypes) {
+		http.Error(w, fmt.Sprintf("%s files can't be attached", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Turn away uploads that can't fit before storing them. The quota is checked again
+	// when the attachment is saved, in case another upload got there first.
+	if s.options.AttachmentProjectQuota > 0 {
+		used, err := s.attachments.ProjectStorageUsed(task.ProjectID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't look up project storage", http.StatusInternalServerError)
+			return
+		}
+		if used+header.Size > s.options.AttachmentProjectQuota {
+			http.Error(w, repository.ErrQuotaExceeded.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	key, err := newStorageKey(task)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't store file", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.blobs.Put(r.Context(), key, file, header.Size, contentType)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't store file", http.StatusInternalServerError)
+		return
+	}
+
+	attachment, err := s.attachments.CreateAttachment(models.Attachment{
+		TaskID:      task.ID,
+		ProjectID:   task.ProjectID,
+		UploadedBy:  accessToken.GetUserID(),
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        header.Size,
+		StorageKey:  key,
+	}, s.options.AttachmentProjectQuota)
+	if err != nil {
+		s.deleteBlob(key)
+		if errors.Is(err, repository.ErrQuotaExceeded) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		log.Error(err)
+		http.Error(w, "couldn't save attachment", http.StatusInternalServerError)
+		return
+	}
+
+	response, err := s.signAttachment(attachment)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't sign attachment link", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+// GetAttachmentHandler returns the attachment with a fresh download link.
+func (s *taskService) GetAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	_, attachment, ok := s.getAttachment(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	response, err := s.signAttachment(attachment)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't sign attachment link", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// DeleteAttachmentHandler lets members delete the files they uploaded, and admins any file.
+func (s *taskService) DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	task, attachment, ok := s.getAttachment(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	canDelete := s.permissions.Can(accessToken, task.ProjectID, permission.DeleteAnyTask) ||
+		(attachment.UploadedBy == accessToken.GetUserID() && s.permissions.Can(accessToken, task.ProjectID, permission.EditTask))
+	if !canDelete {
+		http.Error(w, "you don't have permission to delete this attachment", http.StatusForbidden)
+		return
+	}
+
+	err := s.attachments.DeleteAttachment(attachment)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't delete attachment", http.StatusInternalServerError)
+		return
+	}
+
+	s.deleteBlob(attachment.StorageKey)
+	w.WriteHeader(http.StatusOK)
+}
+
+// getAttachment returns the task and attachment from the request path, checking the
+// caller can do the action in the task's project. It writes the error response and
+// returns false if it can't.
+func (s *taskService) getAttachment(w http.ResponseWriter, r *http.Request, action permission.Action) (models.Task, models.Attachment, bool) {
+	task, ok := s.getTaskWithPermission(w, r, action)
+	if !ok {
+		return models.Task{}, models.Attachment{}, false
+	}
+
+	attachmentID, err := strconv.ParseUint(mux.Vars(r)["attachment_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid attachment ID", http.StatusBadRequest)
+		return models.Task{}, models.Attachment{}, false
+	}
+
+	attachment, err := s.attachments.GetAttachment(task.ID, uint(attachmentID))
+	if err != nil {
+		http.Error(w, "couldn't find attachment", http.StatusNotFound)
+		return models.Task{}, models.Attachment{}, false
+	}
+	return task, attachment, true
+}
+
+func (s *taskService) signAttachment(attachment models.Attachment) (AttachmentResponse, error) {
+	expiresAt := time.Now().Add(s.options.AttachmentURLExpiry).Truncate(time.Second)
+	url, err := s.blobs.SignedURL(attachment.StorageKey, blob.Download{
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+	}, s.options.AttachmentURLExpiry)
+	if err != nil {
+		return AttachmentResponse{}, err
+	}
+
+	return AttachmentResponse{
+		Attachment: attachment,
+		URL:        url,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// deleteBlob removes a file that's no longer attached to anything. It's only logged if
+// that fails, since the attachment is already gone as far as users are concerned.
+func (s *taskService) deleteBlob(key string) {
+	err := s.blobs.Delete(context.Background(), key)
+	if err != nil {
+		log.Errorf("couldn't delete blob %s: %v", key, err)
+	}
+}
+
+// newStorageKey picks a random key under the task's project. The file name isn't part of
+// it, so names with odd characters can't cause trouble in the store.
+func newStorageKey(task models.Task) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("projects/%d/tasks/%d/%s", task.ProjectID, task.ID, hex.EncodeToString(random)), nil
+}
+
+// cleanFileName drops any directories and control characters from an uploaded file name.
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+
+	if len(name) > maxFileNameLength {
+		extension := path.Ext(name)
+		if len(extension) > 16 {
+			extension = ""
+		}
+		name = strings.ToValidUTF8(name[:maxFileNameLength-len(extension)], "") + extension
+	}
+	return name
+}
+
+// errContentTypeMismatch is returned for uploads whose contents aren't what they claim to be
+var errContentTypeMismatch = errors.New("the file's contents don't match its content type")
+
+// attachmentContentType works out the upload's media type from its contents. The type it
+// was sent with is only used to tell apart types the contents can't, like Markdown and
+// CSV, which look like plain text, or office documents, which look like zip files. It
+// returns errContentTypeMismatch if the declared type contradicts the contents.
+func attachmentContentType(declared string, file io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+
+	if declared == "" {
+		return sniffed, nil
+	}
+	declared, _, err = mime.ParseMediaType(declared)
+	if err != nil || declared == "application/octet-stream" {
+		return sniffed, nil
+	}
+	declared = strings.ToLower(declared)
+
+	switch {
+	case declared == sniffed:
+		return declared, nil
+	case sniffed == "text/plain" && strings.HasPrefix(declared, "text/") && declared != "text/html":
+		return declared, nil
+	case sniffed == "application/zip" && strings.HasPrefix(declared, "application/"):
+		return declared, nil
+	case sniffed == "application/octet-stream":
+		// The contents weren't recognised, so there's nothing to back up the declared type
+		return sniffed, nil
+	}
+	return "", errContentTypeMismatch
+}
+
+// contentTypeAllowed returns whether the media type is in the allow-list, either exactly
+// or through a wildcard like image/*.
+func contentTypeAllowed(mediaType string, allowed []string) bool {
+	mediaType = strings.ToLower(mediaType)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*/*" || pattern == mediaType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
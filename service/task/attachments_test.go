@@ -0,0 +1,77 @@
+package task
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanFileName(t *testing.T) {
+	require.Equal(t, "spec.pdf", cleanFileName("spec.pdf"))
+	require.Equal(t, "passwd", cleanFileName("../../etc/passwd"))
+	require.Equal(t, "shot.png", cleanFileName(`C:\Users\dan\shot.png`))
+	require.Equal(t, "evil.txt", cleanFileName("evil\r\n.txt"))
+	require.Equal(t, "", cleanFileName(".."))
+	require.Equal(t, "", cleanFileName("  "))
+
+	long := cleanFileName(strings.Repeat("a", 300) + ".png")
+	require.Len(t, long, maxFileNameLength)
+	require.True(t, strings.HasSuffix(long, ".png"))
+}
+
+func TestContentTypeAllowed(t *testing.T) {
+	allowed := []string{"image/*", "application/pdf"}
+
+	require.True(t, contentTypeAllowed("image/png", allowed))
+	require.True(t, contentTypeAllowed("IMAGE/JPEG", allowed))
+	require.True(t, contentTypeAllowed("application/pdf", allowed))
+	require.False(t, contentTypeAllowed("text/html", allowed))
+	require.False(t, contentTypeAllowed("imagex/png", allowed))
+	require.True(t, contentTypeAllowed("text/html", []string{"*/*"}))
+}
+
+func TestAttachmentContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n rest of the image")
+
+	contentType, err := attachmentContentType("image/png; charset=binary", bytes.NewReader(png))
+	require.NoError(t, err)
+	require.Equal(t, "image/png", contentType)
+
+	// Without a useful declared type the contents are sniffed, and the file is left ready to read
+	file := bytes.NewReader(png)
+	contentType, err = attachmentContentType("application/octet-stream", file)
+	require.NoError(t, err)
+	require.Equal(t, "image/png", contentType)
+	read, err := io.ReadAll(file)
+	require.NoError(t, err)
+	require.Equal(t, png, read)
+
+	contentType, err = attachmentContentType("", strings.NewReader("just some notes"))
+	require.NoError(t, err)
+	require.Equal(t, "text/plain", contentType)
+
+	// Plain text and zip files can be given a more specific type
+	contentType, err = attachmentContentType("text/markdown", strings.NewReader("# Notes"))
+	require.NoError(t, err)
+	require.Equal(t, "text/markdown", contentType)
+	docx := "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	contentType, err = attachmentContentType(docx, strings.NewReader("PK\x03\x04 rest of the document"))
+	require.NoError(t, err)
+	require.Equal(t, docx, contentType)
+
+	// Contents that aren't recognised don't get the declared type
+	contentType, err = attachmentContentType("image/png", bytes.NewReader([]byte{0x7f, 'E', 'L', 'F', 0, 1, 2}))
+	require.NoError(t, err)
+	require.Equal(t, "application/octet-stream", contentType)
+
+	// A declared type that contradicts the contents is turned away
+	_, err = attachmentContentType("image/png", strings.NewReader("<html><script>alert(1)</script></html>"))
+	require.ErrorIs(t, err, errContentTypeMismatch)
+	_, err = attachmentContentType("text/html", strings.NewReader("just some notes"))
+	require.ErrorIs(t, err, errContentTypeMismatch)
+	_, err = attachmentContentType("application/pdf", bytes.NewReader(png))
+	require.ErrorIs(t, err, errContentTypeMismatch)
+}
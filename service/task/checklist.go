@@ -0,0 +1,198 @@
+package task
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/token"
+)
+
+func (s *taskService) ListChecklistItemsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	items, err := s.checklistRepo.ListChecklistItems(task.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up checklist", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(items)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *taskService) CreateChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.EditTask)
+	if !ok {
+		return
+	}
+
+	var createRequest CreateChecklistItemRequest
+	err := json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = validation.ValidateStruct(&createRequest,
+		validation.Field(&createRequest.Text, validation.Required),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// New items go at the end unless a position is given
+	position := 0
+	if createRequest.Position != nil {
+		position = *createRequest.Position
+	} else {
+		items, err := s.checklistRepo.ListChecklistItems(task.ID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't look up checklist", http.StatusInternalServerError)
+			return
+		}
+		for _, item := range items {
+			if item.Position >= position {
+				position = item.Position + 1
+			}
+		}
+	}
+
+	item, err := s.checklistRepo.CreateChecklistItem(models.ChecklistItem{
+		TaskID:   task.ID,
+		Text:     createRequest.Text,
+		Position: position,
+	})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't create checklist item", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(item)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+func (s *taskService) UpdateChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.getChecklistItem(w, r)
+	if !ok {
+		return
+	}
+
+	var updateRequest UpdateChecklistItemRequest
+	err := json.NewDecoder(r.Body).Decode(&updateRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if updateRequest.Text != "" {
+		item.Text = updateRequest.Text
+	}
+	if updateRequest.Done != nil {
+		item.Done = *updateRequest.Done
+	}
+	if updateRequest.Position != nil {
+		item.Position = *updateRequest.Position
+	}
+
+	item, err = s.checklistRepo.UpdateChecklistItem(item)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update checklist item", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(item)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *taskService) DeleteChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.getChecklistItem(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.checklistRepo.DeleteChecklistItem(item)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't delete checklist item", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// getTaskWithPermission returns the task from the request path, checking the caller's
+// role in its project allows the action. It writes the error response and returns false
+// if not.
+func (s *taskService) getTaskWithPermission(w http.ResponseWriter, r *http.Request, action permission.Action) (models.Task, bool) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+	if accessToken.GetUserID() == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return models.Task{}, false
+	}
+
+	task, err := s.taskRepo.GetTaskByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "couldn't find task", http.StatusNotFound)
+		return models.Task{}, false
+	}
+
+	if !s.permissions.Can(accessToken, task.ProjectID, action) {
+		http.Error(w, "you don't have permission to do this in this project", http.StatusForbidden)
+		return models.Task{}, false
+	}
+	return task, true
+}
+
+// getChecklistItem returns the checklist item from the request path, checking the caller
+// can edit its task. It writes the error response and returns false if it can't.
+func (s *taskService) getChecklistItem(w http.ResponseWriter, r *http.Request) (models.ChecklistItem, bool) {
+	task, ok := s.getTaskWithPermission(w, r, permission.EditTask)
+	if !ok {
+		return models.ChecklistItem{}, false
+	}
+
+	itemID, err := strconv.ParseUint(mux.Vars(r)["item_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid checklist item ID", http.StatusBadRequest)
+		return models.ChecklistItem{}, false
+	}
+
+	item, err := s.checklistRepo.GetChecklistItem(task.ID, uint(itemID))
+	if err != nil {
+		http.Error(w, "couldn't find checklist item", http.StatusNotFound)
+		return models.ChecklistItem{}, false
+	}
+	return item, true
+}
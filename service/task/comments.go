@@ -0,0 +1,348 @@
+package task
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/email"
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/token"
+)
+
+func (s *taskService) ListCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	comments, err := s.commentRepo.ListTaskComments(task.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up comments", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(buildCommentThreads(comments))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *taskService) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.CommentOnTask)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	var createRequest CreateCommentRequest
+	err := json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = validation.ValidateStruct(&createRequest,
+		validation.Field(&createRequest.Body, validation.Required),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if createRequest.ParentID != nil {
+		if _, err = s.commentRepo.GetTaskComment(task.ID, *createRequest.ParentID); err != nil {
+			http.Error(w, "parent_id must be a comment on this task", http.StatusBadRequest)
+			return
+		}
+	}
+
+	members, ok := s.projectMembers(w, task.ProjectID)
+	if !ok {
+		return
+	}
+	mentioned := resolveMentions(parseMentions(createRequest.Body), members)
+
+	comment, err := s.commentRepo.CreateTaskComment(models.TaskComment{
+		TaskID:   task.ID,
+		ParentID: createRequest.ParentID,
+		AuthorID: accessToken.GetUserID(),
+		Body:     createRequest.Body,
+		Mentions: mentionIDs(mentioned),
+	})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't create comment", http.StatusInternalServerError)
+		return
+	}
+
+	s.notifyMentions(task, comment, members, mentioned)
+
+	responseBody, err := json.Marshal(toCommentResponse(comment))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+// UpdateCommentHandler lets authors edit their comments while they can still comment on
+// the task. Only members mentioned for the first time in the edit are notified.
+func (s *taskService) UpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	task, comment, ok := s.getTaskComment(w, r, permission.CommentOnTask)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	if comment.AuthorID != accessToken.GetUserID() {
+		http.Error(w, "you can only edit your own comments", http.StatusForbidden)
+		return
+	}
+
+	var updateRequest UpdateCommentRequest
+	err := json.NewDecoder(r.Body).Decode(&updateRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = validation.ValidateStruct(&updateRequest,
+		validation.Field(&updateRequest.Body, validation.Required),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	members, ok := s.projectMembers(w, task.ProjectID)
+	if !ok {
+		return
+	}
+	mentioned := resolveMentions(parseMentions(updateRequest.Body), members)
+
+	alreadyMentioned := make(map[string]bool)
+	for _, userID := range comment.Mentions {
+		alreadyMentioned[userID] = true
+	}
+	var newlyMentioned []models.User
+	for _, user := range mentioned {
+		if !alreadyMentioned[user.ID] {
+			newlyMentioned = append(newlyMentioned, user)
+		}
+	}
+
+	previousBody := comment.Body
+	comment.Body = updateRequest.Body
+	comment.Mentions = mentionIDs(mentioned)
+
+	comment, err = s.commentRepo.UpdateTaskComment(comment, previousBody)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update comment", http.StatusInternalServerError)
+		return
+	}
+
+	s.notifyMentions(task, comment, members, newlyMentioned)
+
+	responseBody, err := json.Marshal(toCommentResponse(comment))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *taskService) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	task, comment, ok := s.getTaskComment(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	if comment.AuthorID != accessToken.GetUserID() &&
+		!s.permissions.Can(accessToken, task.ProjectID, permission.DeleteAnyComment) {
+		http.Error(w, "you don't have permission to delete this comment", http.StatusForbidden)
+		return
+	}
+
+	err := s.commentRepo.DeleteTaskComment(comment)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't delete comment", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListCommentRevisionsHandler returns what the comment said before each of its edits.
+func (s *taskService) ListCommentRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, comment, ok := s.getTaskComment(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	revisions, err := s.commentRepo.ListTaskCommentRevisions(comment.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up comment history", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(revisions)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// getTaskComment returns the task and comment from the request path, checking the caller's
+// role in the task's project allows the action. It writes the error response and returns
+// false if it can't.
+func (s *taskService) getTaskComment(w http.ResponseWriter, r *http.Request, action permission.Action) (models.Task, models.TaskComment, bool) {
+	task, ok := s.getTaskWithPermission(w, r, action)
+	if !ok {
+		return models.Task{}, models.TaskComment{}, false
+	}
+
+	commentID, err := strconv.ParseUint(mux.Vars(r)["comment_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid comment ID", http.StatusBadRequest)
+		return models.Task{}, models.TaskComment{}, false
+	}
+
+	comment, err := s.commentRepo.GetTaskComment(task.ID, uint(commentID))
+	if err != nil {
+		http.Error(w, "couldn't find comment", http.StatusNotFound)
+		return models.Task{}, models.TaskComment{}, false
+	}
+	return task, comment, true
+}
+
+// projectMembers returns the members of the project, who are the people who can be
+// mentioned. It writes the error response and returns false if it can't.
+func (s *taskService) projectMembers(w http.ResponseWriter, projectID uint) ([]models.ProjectMember, bool) {
+	members, err := s.projectRepo.ListProjectMembers(strconv.FormatUint(uint64(projectID), 10))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up project members", http.StatusInternalServerError)
+		return nil, false
+	}
+	return members, true
+}
+
+// notifyMentions emails the mentioned users, other than the comment's author. The
+// comment is saved whether or not the emails go out, so failures are only logged.
+func (s *taskService) notifyMentions(task models.Task, comment models.TaskComment, members []models.ProjectMember, mentioned []models.User) {
+	if len(mentioned) == 0 {
+		return
+	}
+
+	authorName := comment.AuthorID
+	for _, member := range members {
+		if member.UserID == comment.AuthorID {
+			authorName = member.User.DisplayName
+		}
+	}
+
+	project, err := s.projectRepo.GetProjectByID(strconv.FormatUint(uint64(task.ProjectID), 10))
+	if err != nil {
+		log.Errorf("couldn't look up project for mention emails: %v", err)
+		return
+	}
+
+	for _, user := range mentioned {
+		if user.ID == comment.AuthorID || user.Email == "" {
+			continue
+		}
+
+		err = s.emailClient.SendMentionEmail(email.MentionEmail{
+			AuthorName:     authorName,
+			TaskTitle:      task.Title,
+			ProjectName:    project.Name,
+			CommentBody:    comment.Body,
+			RecipientName:  user.DisplayName,
+			RecipientEmail: user.Email,
+		})
+		if err != nil {
+			log.Errorf("couldn't send mention email: %v", err)
+		}
+	}
+}
+
+// buildCommentThreads nests the replies under the comments they answer, given the task's
+// comments oldest first.
+func buildCommentThreads(comments []models.TaskComment) []CommentResponse {
+	replies := make(map[uint][]models.TaskComment)
+	var topLevel []models.TaskComment
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			topLevel = append(topLevel, comment)
+		} else {
+			replies[*comment.ParentID] = append(replies[*comment.ParentID], comment)
+		}
+	}
+
+	var build func(comments []models.TaskComment) []CommentResponse
+	build = func(comments []models.TaskComment) []CommentResponse {
+		threads := []CommentResponse{}
+		for _, comment := range comments {
+			response := toCommentResponse(comment)
+			response.Replies = build(replies[comment.ID])
+			if response.Deleted && len(response.Replies) == 0 {
+				continue
+			}
+			threads = append(threads, response)
+		}
+		return threads
+	}
+	return build(topLevel)
+}
+
+func toCommentResponse(comment models.TaskComment) CommentResponse {
+	response := CommentResponse{
+		ID:        comment.ID,
+		TaskID:    comment.TaskID,
+		ParentID:  comment.ParentID,
+		AuthorID:  comment.AuthorID,
+		Body:      comment.Body,
+		Mentions:  comment.Mentions,
+		EditedAt:  comment.EditedAt,
+		CreatedAt: comment.CreatedAt,
+		Replies:   []CommentResponse{},
+	}
+	if response.Mentions == nil {
+		response.Mentions = []string{}
+	}
+
+	if comment.DeletedAt.Valid {
+		response.Deleted = true
+		response.Body = ""
+		response.Mentions = []string{}
+	}
+	return response
+}
+
+func mentionIDs(users []models.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
@@ -0,0 +1,37 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+)
+
+func TestBuildCommentThreads(t *testing.T) {
+	id := func(id uint) *uint { return &id }
+	deleted := gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	threads := buildCommentThreads([]models.TaskComment{
+		{ID: 1, Body: "first"},
+		{ID: 2, Body: "gone", DeletedAt: deleted},
+		{ID: 3, ParentID: id(1), Body: "reply"},
+		{ID: 4, Body: "also gone", DeletedAt: deleted, Mentions: []string{"1"}},
+		{ID: 5, ParentID: id(4), Body: "reply to a deleted comment"},
+		{ID: 6, ParentID: id(3), Body: "nested reply"},
+	})
+
+	// Deleted comments without replies are dropped, and those with replies are emptied
+	require.Len(t, threads, 2)
+	require.Equal(t, uint(1), threads[0].ID)
+	require.Equal(t, uint(4), threads[1].ID)
+	require.True(t, threads[1].Deleted)
+	require.Empty(t, threads[1].Body)
+	require.Empty(t, threads[1].Mentions)
+	require.Equal(t, "reply to a deleted comment", threads[1].Replies[0].Body)
+
+	require.Len(t, threads[0].Replies, 1)
+	require.Equal(t, uint(6), threads[0].Replies[0].Replies[0].ID)
+}
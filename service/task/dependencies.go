@@ -0,0 +1,210 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+func (s *taskService) ListTaskDependenciesHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	blockers, err := s.dependencies.ListBlockers(task.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up task dependencies", http.StatusInternalServerError)
+		return
+	}
+
+	blocked, err := s.dependencies.ListBlockedTasks(task.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up task dependencies", http.StatusInternalServerError)
+		return
+	}
+
+	response := TaskDependenciesResponse{
+		BlockedBy: s.visibleTasks(accessToken, blockers),
+		Blocks:    s.visibleTasks(accessToken, blocked),
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// CreateTaskDependencyHandler links the task to another. The caller has to be able to
+// edit the task that will be waiting, and see the one it waits on.
+func (s *taskService) CreateTaskDependencyHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	var createRequest CreateTaskDependencyRequest
+	err := json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if (createRequest.BlockedByID == nil) == (createRequest.BlocksID == nil) {
+		http.Error(w, "exactly one of blocked_by_id or blocks_id must be set", http.StatusBadRequest)
+		return
+	}
+
+	otherID := createRequest.BlockedByID
+	if otherID == nil {
+		otherID = createRequest.BlocksID
+	}
+	other, err := s.taskRepo.GetTaskByID(strconv.FormatUint(uint64(*otherID), 10))
+	if err != nil || !s.permissions.HasProjectPermission(accessToken, other.ProjectID) {
+		http.Error(w, "couldn't find the other task", http.StatusNotFound)
+		return
+	}
+
+	waiting, blocker := task, other
+	if createRequest.BlocksID != nil {
+		waiting, blocker = other, task
+	}
+
+	if !s.permissions.Can(accessToken, waiting.ProjectID, permission.EditTask) {
+		http.Error(w, "you don't have permission to edit the blocked task", http.StatusForbidden)
+		return
+	}
+
+	dependency, err := s.dependencies.CreateTaskDependency(models.TaskDependency{
+		TaskID:      waiting.ID,
+		BlockedByID: blocker.ID,
+		CreatedBy:   accessToken.GetUserID(),
+	})
+	switch {
+	case errors.Is(err, repository.ErrDependencyCycle):
+		http.Error(w, "the tasks would end up waiting on each other", http.StatusConflict)
+		return
+	case errors.Is(err, repository.ErrDependencyExists):
+		http.Error(w, "the tasks are already linked", http.StatusConflict)
+		return
+	case err != nil:
+		log.Error(err)
+		http.Error(w, "couldn't create task dependency", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(dependency)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+// DeleteTaskDependencyHandler stops the task waiting on the task in the path.
+func (s *taskService) DeleteTaskDependencyHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.EditTask)
+	if !ok {
+		return
+	}
+
+	blockerID, err := strconv.ParseUint(mux.Vars(r)["blocker_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	err = s.dependencies.DeleteTaskDependency(task.ID, uint(blockerID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "couldn't find task dependency", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't delete task dependency", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// openBlockers returns the tasks the task is waiting on that aren't done. It writes the
+// error response and returns false if it can't look them up.
+func (s *taskService) openBlockers(w http.ResponseWriter, task models.Task) ([]models.Task, bool) {
+	blockers, err := s.dependencies.ListBlockers(task.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up task dependencies", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	open := make([]models.Task, 0, len(blockers))
+	for _, blocker := range blockers {
+		if !isDone(blocker) {
+			open = append(open, blocker)
+		}
+	}
+	return open, true
+}
+
+// checkNotBlocked checks the task isn't waiting on any open tasks before it's closed.
+// It writes the error response and returns false if it is.
+func (s *taskService) checkNotBlocked(w http.ResponseWriter, task models.Task) bool {
+	blockers, ok := s.openBlockers(w, task)
+	if !ok {
+		return false
+	}
+	if len(blockers) > 0 {
+		http.Error(w, fmt.Sprintf("task is blocked by %d open tasks, set force to close it anyway", len(blockers)), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// visibleTasks leaves out the tasks in projects the caller can't see.
+func (s *taskService) visibleTasks(accessToken *token.ToDanniToken, tasks []models.Task) []models.Task {
+	visible := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if s.permissions.HasProjectPermission(accessToken, task.ProjectID) {
+			visible = append(visible, task)
+		}
+	}
+	return visible
+}
+
+// closesTask returns whether an update moving the task to the status, or setting done
+// for projects without statuses, takes it from open to done.
+func closesTask(task models.Task, status *models.TaskStatus, done *bool) bool {
+	if isDone(task) {
+		return false
+	}
+	if status != nil {
+		return status.Category == models.ClosedCategory
+	}
+	return done != nil && *done
+}
+
+func isDone(task models.Task) bool {
+	return task.Done != nil && *task.Done
+}
@@ -0,0 +1,26 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestClosesTask(t *testing.T) {
+	done := func(done bool) *bool { return &done }
+	open := models.Task{Done: done(false)}
+	closed := models.Task{Done: done(true)}
+	closedStatus := &models.TaskStatus{Category: models.ClosedCategory}
+	activeStatus := &models.TaskStatus{Category: models.ActiveCategory}
+
+	require.True(t, closesTask(open, closedStatus, nil))
+	require.True(t, closesTask(models.Task{}, nil, done(true)))
+	require.False(t, closesTask(open, activeStatus, nil))
+	require.False(t, closesTask(open, nil, done(false)))
+	require.False(t, closesTask(open, nil, nil))
+
+	// Tasks that are already done can move between closed statuses
+	require.False(t, closesTask(closed, closedStatus, nil))
+}
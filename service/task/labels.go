@@ -0,0 +1,81 @@
+package task
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+)
+
+// resolveLabels looks up the labels with the IDs, which have to belong to the project.
+// It writes the error response and returns false if they can't be found.
+func (s *taskService) resolveLabels(w http.ResponseWriter, projectID uint, labelIDs []uint) ([]models.Label, bool) {
+	labels := make([]models.Label, 0, len(labelIDs))
+	if len(labelIDs) == 0 {
+		return labels, true
+	}
+
+	projectLabels, err := s.labelRepo.ListLabels(projectID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up labels", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	added := make(map[uint]bool, len(labelIDs))
+	for _, id := range labelIDs {
+		if added[id] {
+			continue
+		}
+
+		found := false
+		for _, label := range projectLabels {
+			if label.ID == id {
+				labels = append(labels, label)
+				found = true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, fmt.Sprintf("label %d isn't one of the project's labels", id), http.StatusBadRequest)
+			return nil, false
+		}
+		added[id] = true
+	}
+	return labels, true
+}
+
+// parseLabelGroups reads the label filter from the query parameters, for clients that
+// predate the query language. Each label parameter can hold several comma separated
+// names, and label_match says whether tasks need any of them, which is the default, or
+// all of them. Tasks need a label from each of the groups returned.
+func parseLabelGroups(query url.Values) ([][]string, error) {
+	var names []string
+	for _, value := range query["label"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	switch query.Get("label_match") {
+	case "", "any":
+		return [][]string{names}, nil
+	case "all":
+		groups := make([][]string, 0, len(names))
+		for _, name := range names {
+			groups = append(groups, []string{name})
+		}
+		return groups, nil
+	default:
+		return nil, fmt.Errorf("label_match must be any or all")
+	}
+}
@@ -0,0 +1,25 @@
+package task
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabelGroups(t *testing.T) {
+	groups, err := parseLabelGroups(url.Values{})
+	require.NoError(t, err)
+	require.Empty(t, groups)
+
+	groups, err = parseLabelGroups(url.Values{"label": {"bug, design", "urgent"}})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"bug", "design", "urgent"}}, groups)
+
+	groups, err = parseLabelGroups(url.Values{"label": {"bug,,design"}, "label_match": {"all"}})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"bug"}, {"design"}}, groups)
+
+	_, err = parseLabelGroups(url.Values{"label": {"bug"}, "label_match": {"some"}})
+	require.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/todanni/api/models"
+)
+
+// mentionPattern matches @handles that aren't part of a word or an email address. A
+// handle can be an email address itself.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// parseMentions returns the handles mentioned in the comment body, in order and without
+// repeats.
+func parseMentions(body string) []string {
+	var handles []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		// Mentions at the end of a sentence shouldn't take the full stop with them
+		handle := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if handle != "" && !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// resolveMentions returns the project members the handles refer to. A handle matches a
+// member's user ID, email address, the part of it before the @, or their display name
+// without spaces, ignoring case.
+func resolveMentions(handles []string, members []models.ProjectMember) []models.User {
+	var mentioned []models.User
+	seen := make(map[string]bool)
+	for _, handle := range handles {
+		for _, member := range members {
+			if seen[member.UserID] || !mentionMatches(handle, member.User) {
+				continue
+			}
+			seen[member.UserID] = true
+			mentioned = append(mentioned, member.User)
+		}
+	}
+	return mentioned
+}
+
+func mentionMatches(handle string, user models.User) bool {
+	email := strings.ToLower(user.Email)
+	local, _, _ := strings.Cut(email, "@")
+	name := strings.ToLower(strings.Join(strings.Fields(user.DisplayName), ""))
+
+	return handle == strings.ToLower(user.ID) ||
+		(email != "" && (handle == email || handle == local)) ||
+		(name != "" && handle == name)
+}
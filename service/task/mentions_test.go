@@ -0,0 +1,31 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestParseMentions(t *testing.T) {
+	require.Equal(t, []string{"jane", "bob.smith@example.com", "sam"},
+		parseMentions("@jane can you check with @Bob.Smith@example.com? Thanks @sam. And @jane again"))
+
+	// Email addresses and lone @ signs aren't mentions
+	require.Empty(t, parseMentions("send it to jane@example.com @ noon"))
+}
+
+func TestResolveMentions(t *testing.T) {
+	jane := models.User{ID: "1", DisplayName: "Jane Doe", Email: "jane@example.com"}
+	bob := models.User{ID: "2", DisplayName: "Bob", Email: "bob.smith@example.com"}
+	members := []models.ProjectMember{
+		{UserID: jane.ID, User: jane},
+		{UserID: bob.ID, User: bob},
+	}
+
+	require.Equal(t, []models.User{jane, bob}, resolveMentions([]string{"janedoe", "bob.smith"}, members))
+	require.Equal(t, []models.User{bob}, resolveMentions([]string{"bob.smith@example.com", "bob"}, members))
+	require.Equal(t, []models.User{jane}, resolveMentions([]string{"1", "nobody"}, members))
+	require.Empty(t, resolveMentions([]string{"example.com"}, members))
+}
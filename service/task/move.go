@@ -0,0 +1,91 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+// MoveTaskHandler moves a task to a position in a status, between the tasks given as
+// its new neighbours. Only the moved task's rank changes, unless the neighbours share a
+// rank and the status's ranks have to be spread out to make room.
+func (s *taskService) MoveTaskHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	task, err := s.taskRepo.GetTaskByID(params["id"])
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't find task", http.StatusNotFound)
+		return
+	}
+
+	if !s.permissions.Can(accessToken, task.ProjectID, permission.EditTask) {
+		http.Error(w, "you don't have permission to edit tasks in this project", http.StatusForbidden)
+		return
+	}
+
+	var moveRequest MoveTaskRequest
+	err = json.NewDecoder(r.Body).Decode(&moveRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if moveRequest.StatusID == 0 {
+		http.Error(w, "status_id must be set", http.StatusBadRequest)
+		return
+	}
+
+	status, ok := s.resolveStatus(w, task.ProjectID, task.StatusID, &moveRequest.StatusID, nil)
+	if !ok {
+		return
+	}
+	closing := closesTask(task, status, nil)
+	if closing && !moveRequest.Force && !s.checkNotBlocked(w, task) {
+		return
+	}
+
+	position := repository.TaskPosition{AfterID: moveRequest.AfterID, BeforeID: moveRequest.BeforeID}
+	movedTask, err := s.taskRepo.MoveTask(task.ID, *status, position)
+	if errors.Is(err, repository.ErrWIPLimitReached) {
+		http.Error(w, fmt.Sprintf("%s is at its work in progress limit", status.Name), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, repository.ErrInvalidNeighbour) || errors.Is(err, repository.ErrNeighboursOutOfOrder) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't move task", http.StatusInternalServerError)
+		return
+	}
+
+	if closing && movedTask.SeriesID != nil {
+		s.continueSeries(movedTask)
+	}
+
+	responseBody, err := json.Marshal(movedTask)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
@@ -0,0 +1,134 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/taskquery"
+	"github.com/todanni/api/token"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// defaultOrder puts the most pressing tasks first
+var defaultOrder = []repository.TaskOrder{
+	{Field: repository.SortByPriority},
+	{Field: repository.SortByDeadline},
+}
+
+// parseTaskQuery reads a query in the taskquery language, with dates in the user's time
+// zone. It writes the error response and returns false if the query is invalid.
+func (s *taskService) parseTaskQuery(w http.ResponseWriter, userID string, query string) (repository.TaskFilter, bool) {
+	location, ok := s.userLocation(w, userID)
+	if !ok {
+		return repository.TaskFilter{}, false
+	}
+
+	filter, err := taskquery.Parse(query, taskquery.Context{
+		Me:       userID,
+		Location: location,
+		Now:      time.Now(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return repository.TaskFilter{}, false
+	}
+	return filter, true
+}
+
+// listTasks returns the page of tasks matching the filter that the request's limit and
+// cursor parameters ask for, in the sort order. Pages hold defaultLimit tasks unless the
+// request sets a limit, and every task is returned if both are 0. Only tasks in projects the caller is a
+// member of, and their token allows, are listed. Without a project, assignee, creator or
+// involves condition, the filter is narrowed to the caller's own tasks.
+// It writes the error response and returns false if it can't list them.
+func (s *taskService) listTasks(w http.ResponseWriter, r *http.Request, accessToken *token.ToDanniToken, filter repository.TaskFilter, sort string, defaultLimit int) (ListTasksResponse, bool) {
+	userID := accessToken.GetUserID()
+
+	for _, projectID := range filter.ProjectIDs {
+		if !s.permissions.Can(accessToken, projectID, permission.ViewProject) {
+			http.Error(w, fmt.Sprintf("you don't have access to project %d", projectID), http.StatusForbidden)
+			return ListTasksResponse{}, false
+		}
+	}
+	if len(filter.ProjectIDs) == 0 && len(filter.AssignedTo) == 0 &&
+		len(filter.CreatedBy) == 0 && len(filter.Involves) == 0 {
+		filter.Involves = []string{userID}
+	}
+	filter.MemberID = userID
+	filter.AllowedProjectIDs = accessToken.AllowedProjects()
+
+	order, err := taskquery.ParseSort(sort)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return ListTasksResponse{}, false
+	}
+	if len(order) == 0 {
+		order = defaultOrder
+	}
+
+	page := repository.TaskPage{Order: order, Limit: defaultLimit}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		page.Limit, err = strconv.Atoi(limit)
+		if err != nil || page.Limit < 1 || page.Limit > MaxPageSize {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", MaxPageSize), http.StatusBadRequest)
+			return ListTasksResponse{}, false
+		}
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		after, err := taskquery.DecodeCursor(cursor, order)
+		if err != nil {
+			http.Error(w, "invalid cursor, it may be for a different sort", http.StatusBadRequest)
+			return ListTasksResponse{}, false
+		}
+		page.After = &after
+	}
+
+	tasks, more, err := s.taskRepo.ListTasks(filter, page)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "invalid cursor, it may be for a different sort", http.StatusBadRequest)
+		return ListTasksResponse{}, false
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up tasks", http.StatusInternalServerError)
+		return ListTasksResponse{}, false
+	}
+
+	response := ListTasksResponse{Tasks: tasks}
+	if tasks == nil {
+		response.Tasks = []models.Task{}
+	}
+	if more {
+		response.NextCursor = taskquery.EncodeCursor(repository.NewTaskCursor(tasks[len(tasks)-1], order), order)
+	}
+	return response, true
+}
+
+// userLocation returns the user's time zone, or UTC if they haven't set a valid one.
+// It writes the error response and returns false if the user can't be looked up.
+func (s *taskService) userLocation(w http.ResponseWriter, userID string) (*time.Location, bool) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up your time zone", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	location, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		return time.UTC, true
+	}
+	return location, true
+}
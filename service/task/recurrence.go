@@ -0,0 +1,410 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/recurrence"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+const (
+	ThisScope   = "this"
+	FutureScope = "future"
+
+	// upcomingInstances is how many future deadlines are shown for a recurring task
+	upcomingInstances = 5
+)
+
+func (s *taskService) GetRecurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	series, ok := s.getTaskSeries(w, task)
+	if !ok {
+		return
+	}
+	s.writeRecurrence(w, series, task)
+}
+
+// SetRecurrenceHandler makes the task recurring, or changes the rule of its series for
+// this instance or for it and every instance after it.
+func (s *taskService) SetRecurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.EditTask)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	var setRequest SetRecurrenceRequest
+	err := json.NewDecoder(r.Body).Decode(&setRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if setRequest.Scope == "" {
+		setRequest.Scope = FutureScope
+	}
+	if err = validation.ValidateStruct(&setRequest,
+		validation.Field(&setRequest.Scope, validation.In(ThisScope, FutureScope)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if task.Deadline.IsZero() {
+		http.Error(w, "recurring tasks need a deadline", http.StatusBadRequest)
+		return
+	}
+
+	// A task that doesn't recur yet becomes the first instance of a new series
+	if task.SeriesID == nil {
+		if setRequest.Scope == ThisScope {
+			http.Error(w, "the task doesn't recur yet", http.StatusBadRequest)
+			return
+		}
+
+		series, ok := s.newTaskSeries(w, accessToken.GetUserID(), task.ProjectID, task.Deadline, setRequest.RecurrenceRequest, nil)
+		if !ok {
+			return
+		}
+
+		series, task, err = s.seriesRepo.CreateTaskSeries(series, task)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't make task recurring", http.StatusInternalServerError)
+			return
+		}
+		s.writeRecurrence(w, series, task)
+		return
+	}
+
+	series, ok := s.getTaskSeries(w, task)
+	if !ok {
+		return
+	}
+
+	if setRequest.Scope == ThisScope {
+		var override *string
+		if setRequest.Rule != "" {
+			rule, err := recurrence.Parse(setRequest.Rule)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			formatted := rule.String()
+			override = &formatted
+		}
+
+		err = s.seriesRepo.SetRecurrenceOverride(task.ID, override)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't update task recurrence", http.StatusInternalServerError)
+			return
+		}
+		task.RecurrenceOverride = override
+		s.writeRecurrence(w, series, task)
+		return
+	}
+
+	updated, ok := s.newTaskSeries(w, accessToken.GetUserID(), task.ProjectID, task.Deadline, setRequest.RecurrenceRequest, &series)
+	if !ok {
+		return
+	}
+
+	// Changing the rule from the first instance changes the whole series, otherwise the
+	// instances before this one keep the old rule
+	first, err := s.seriesRepo.IsFirstSeriesInstance(task)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up task recurrence", http.StatusInternalServerError)
+		return
+	}
+
+	if first && series.EndedAt == nil {
+		updated.ID = series.ID
+		updated.Start = series.Start
+		series, err = s.seriesRepo.UpdateTaskSeries(updated)
+		if err == nil {
+			err = s.seriesRepo.SetRecurrenceOverride(task.ID, nil)
+		}
+	} else {
+		series, err = s.seriesRepo.SplitTaskSeries(series, task, updated)
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update task recurrence", http.StatusInternalServerError)
+		return
+	}
+
+	task.SeriesID = &series.ID
+	task.RecurrenceOverride = nil
+	s.writeRecurrence(w, series, task)
+}
+
+// StopRecurrenceHandler stops the task's series creating any more instances.
+func (s *taskService) StopRecurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.EditTask)
+	if !ok {
+		return
+	}
+
+	series, ok := s.getTaskSeries(w, task)
+	if !ok {
+		return
+	}
+
+	err := s.seriesRepo.EndTaskSeries(series.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't stop task recurrence", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// newTaskSeries validates the recurrence request, returning a series starting at the
+// deadline. Anything not set in the request is taken from the current series, if there
+// is one, or the defaults. It writes the error response and returns false if it's invalid.
+func (s *taskService) newTaskSeries(w http.ResponseWriter, userID string, projectID uint, deadline time.Time, request RecurrenceRequest, current *models.TaskSeries) (models.TaskSeries, bool) {
+	if request.Rule == "" && current != nil {
+		request.Rule = current.Rule
+	}
+	rule, err := recurrence.Parse(request.Rule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return models.TaskSeries{}, false
+	}
+
+	if request.Trigger == "" {
+		request.Trigger = models.CompletionTrigger
+		if current != nil {
+			request.Trigger = current.Trigger
+		}
+	}
+	if err = validation.Validate(request.Trigger,
+		validation.In(models.CompletionTrigger, models.ScheduleTrigger),
+	); err != nil {
+		http.Error(w, "trigger must be completion or schedule", http.StatusBadRequest)
+		return models.TaskSeries{}, false
+	}
+
+	if request.TimeZone == "" {
+		if current != nil {
+			request.TimeZone = current.TimeZone
+		} else {
+			user, err := s.userRepo.GetUserByID(userID)
+			if err != nil {
+				log.Error(err)
+				http.Error(w, "couldn't look up your time zone", http.StatusInternalServerError)
+				return models.TaskSeries{}, false
+			}
+			request.TimeZone = user.TimeZone
+		}
+	}
+	if _, err = time.LoadLocation(request.TimeZone); err != nil {
+		http.Error(w, "unknown time zone", http.StatusBadRequest)
+		return models.TaskSeries{}, false
+	}
+
+	return models.TaskSeries{
+		ProjectID: projectID,
+		Rule:      rule.String(),
+		TimeZone:  request.TimeZone,
+		Start:     deadline,
+		Trigger:   request.Trigger,
+		CreatedBy: userID,
+	}, true
+}
+
+// getTaskSeries returns the series the task is an instance of. It writes the error
+// response and returns false if it doesn't recur.
+func (s *taskService) getTaskSeries(w http.ResponseWriter, task models.Task) (models.TaskSeries, bool) {
+	if task.SeriesID == nil {
+		http.Error(w, "the task doesn't recur", http.StatusNotFound)
+		return models.TaskSeries{}, false
+	}
+
+	series, err := s.seriesRepo.GetTaskSeries(*task.SeriesID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up task recurrence", http.StatusInternalServerError)
+		return models.TaskSeries{}, false
+	}
+	return series, true
+}
+
+func (s *taskService) writeRecurrence(w http.ResponseWriter, series models.TaskSeries, task models.Task) {
+	upcoming := []time.Time{}
+	if series.EndedAt == nil {
+		deadlines, err := upcomingDeadlines(series, task, upcomingInstances)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't work out upcoming deadlines", http.StatusInternalServerError)
+			return
+		}
+		upcoming = append(upcoming, deadlines...)
+	}
+
+	responseBody, err := json.Marshal(RecurrenceResponse{
+		Series:   series,
+		Override: task.RecurrenceOverride,
+		Upcoming: upcoming,
+	})
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// continueSeries creates the instance after a recurring task that was just completed,
+// unless its series is on a schedule or has ended. Failures are only logged, as the
+// task itself has already been updated.
+func (s *taskService) continueSeries(task models.Task) {
+	series, err := s.seriesRepo.GetTaskSeries(*task.SeriesID)
+	if err != nil {
+		log.Errorf("couldn't look up series %d: %v", *task.SeriesID, err)
+		return
+	}
+	if series.Trigger != models.CompletionTrigger || series.EndedAt != nil {
+		return
+	}
+
+	err = s.createNextInstance(series, task, time.Now())
+	if err != nil {
+		log.Errorf("couldn't create the next instance of series %d: %v", series.ID, err)
+	}
+}
+
+// scheduleRecurringTasks creates the next instance of scheduled series once their latest
+// instance's deadline has passed, checking every interval.
+func (s *taskService) scheduleRecurringTasks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		due, err := s.seriesRepo.ListDueTaskSeries(now)
+		if err != nil {
+			log.Errorf("couldn't look up recurring tasks that are due: %v", err)
+			continue
+		}
+
+		for _, series := range due {
+			latest, err := s.seriesRepo.LatestSeriesInstance(series.ID)
+			if err == nil {
+				err = s.createNextInstance(series, latest, now)
+			}
+			if err != nil {
+				log.Errorf("couldn't create the next instance of series %d: %v", series.ID, err)
+			}
+		}
+	}
+}
+
+// createNextInstance creates the instance of the series after the given one, in the
+// project's first open status, or ends the series if its rule has run out. If that status
+// is at its WIP limit, repository.ErrWIPLimitReached is returned and scheduled series are
+// tried again on the next run.
+func (s *taskService) createNextInstance(series models.TaskSeries, from models.Task, now time.Time) error {
+	deadline, ok, err := nextDeadline(series, from, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.seriesRepo.EndTaskSeries(series.ID)
+	}
+
+	done := false
+	next := models.Task{
+		Title:       from.Title,
+		Description: from.Description,
+		Done:        &done,
+		ParentID:    from.ParentID,
+		SeriesID:    &series.ID,
+		ProjectID:   from.ProjectID,
+		CreatedBy:   from.CreatedBy,
+		AssignedTo:  from.AssignedTo,
+		Priority:    from.Priority,
+		Urgent:      from.Urgent,
+		Important:   from.Important,
+		Deadline:    deadline,
+	}
+
+	statuses, err := s.statusRepo.ListTaskStatuses(from.ProjectID)
+	if err != nil {
+		return err
+	}
+	if status := statusForDone(statuses, nil, false); status != nil {
+		next.StatusID = &status.ID
+	}
+
+	_, err = s.seriesRepo.CreateSeriesInstance(from, next)
+	if errors.Is(err, repository.ErrInstanceExists) {
+		return nil
+	}
+	return err
+}
+
+// nextDeadline works out when the instance after the given one is due, in the series'
+// time zone. Occurrences that have already passed are skipped, so a task completed late
+// is followed by the next one still to come. It returns false if the rule has run out.
+func nextDeadline(series models.TaskSeries, from models.Task, now time.Time) (time.Time, bool, error) {
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	after := from.Deadline
+	if now.After(after) {
+		after = now
+	}
+
+	// An override counts from this instance rather than the start of the series
+	if from.RecurrenceOverride != nil {
+		rule, err := recurrence.Parse(*from.RecurrenceOverride)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		next, ok := rule.Next(from.Deadline.In(loc), after)
+		return next, ok, nil
+	}
+
+	rule, err := recurrence.Parse(series.Rule)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	next, ok := rule.Next(series.Start.In(loc), after)
+	return next, ok, nil
+}
+
+// upcomingDeadlines returns when the next n instances after the task will be due, if
+// each is completed on time.
+func upcomingDeadlines(series models.TaskSeries, task models.Task, n int) ([]time.Time, error) {
+	var deadlines []time.Time
+	for len(deadlines) < n {
+		next, ok, err := nextDeadline(series, task, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		deadlines = append(deadlines, next)
+		task = models.Task{Deadline: next}
+	}
+	return deadlines, nil
+}
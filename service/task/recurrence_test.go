@@ -0,0 +1,57 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestNextDeadline(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Every Monday at 9am in New York, which is 14:00 UTC in winter and 13:00 in summer
+	start := time.Date(2023, time.March, 6, 9, 0, 0, 0, newYork)
+	series := models.TaskSeries{
+		Rule:     "FREQ=WEEKLY;BYDAY=MO",
+		TimeZone: "America/New_York",
+		Start:    start.UTC(),
+	}
+	first := models.Task{Deadline: start.UTC()}
+
+	// Completed early, the next instance is a week after the deadline, across the DST change
+	next, ok, err := nextDeadline(series, first, start.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2023, time.March, 13, 13, 0, 0, 0, time.UTC), next.UTC())
+
+	// Completed late, missed occurrences are skipped
+	next, ok, err = nextDeadline(series, first, time.Date(2023, time.March, 22, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2023, time.March, 27, 9, 0, 0, 0, newYork), next)
+
+	// An override only decides the instance after this one
+	override := "FREQ=DAILY"
+	overridden := models.Task{Deadline: start.UTC(), RecurrenceOverride: &override}
+	upcoming, err := upcomingDeadlines(series, overridden, 3)
+	require.NoError(t, err)
+	require.Equal(t, []time.Time{
+		time.Date(2023, time.March, 7, 9, 0, 0, 0, newYork),
+		time.Date(2023, time.March, 13, 9, 0, 0, 0, newYork),
+		time.Date(2023, time.March, 20, 9, 0, 0, 0, newYork),
+	}, upcoming)
+
+	// Series stop once the rule runs out
+	series.Rule = "FREQ=WEEKLY;COUNT=2"
+	upcoming, err = upcomingDeadlines(series, first, 5)
+	require.NoError(t, err)
+	require.Len(t, upcoming, 1)
+
+	series.TimeZone = "Nowhere/Special"
+	_, _, err = nextDeadline(series, first, start)
+	require.Error(t, err)
+}
@@ -1,23 +1,247 @@
 package task
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/todanni/api/models"
 )
 
 type CreateTaskRequest struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
 	Done        bool      `json:"done"`
+	StatusID    *uint     `json:"status_id"`
+	ParentID    *uint     `json:"parent_id"`
 	Deadline    time.Time `json:"deadline"`
 	ProjectID   uint      `json:"project_id"`
 	CreatedBy   uint      `json:"created_by"`
 	AssignedTo  string    `json:"assigned_to"`
+	LabelIDs    []uint    `json:"label_ids"`
+	// Priority defaults to P2. Urgent and Important are worked out from the deadline and
+	// priority if they're left out.
+	Priority  models.Priority `json:"priority"`
+	Urgent    *bool           `json:"urgent"`
+	Important *bool           `json:"important"`
+	// Recurrence makes the task the first instance of a recurring task, due at Deadline
+	Recurrence *RecurrenceRequest `json:"recurrence"`
 }
 
+// UpdateTaskRequest moves the task to StatusID if it's set. Otherwise Done, which is for
+// clients that predate statuses, moves it to the project's first closed or open status.
+// ParentID of 0 makes a subtask a top level task. LabelIDs replaces the task's labels if
+// it's set, so an empty list removes them all.
 type UpdateTaskRequest struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
-	Done        bool      `json:"done"`
+	Done        *bool     `json:"done"`
+	StatusID    *uint     `json:"status_id"`
+	ParentID    *uint     `json:"parent_id"`
 	AssignedTo  string    `json:"assigned_to"`
 	Deadline    time.Time `json:"deadline"`
+	LabelIDs    *[]uint   `json:"label_ids"`
+	// Priority is left as it is if it's empty. Setting Urgent or Important to null goes
+	// back to working it out from the task's deadline or priority.
+	Priority  models.Priority `json:"priority"`
+	Urgent    OptionalBool    `json:"urgent"`
+	Important OptionalBool    `json:"important"`
+	// Force closes the task even if tasks blocking it are still open
+	Force bool `json:"force"`
+}
+
+// OptionalBool tells a field that's left out of a request apart from one set to null.
+type OptionalBool struct {
+	Set   bool
+	Value *bool
+}
+
+func (b *OptionalBool) UnmarshalJSON(data []byte) error {
+	b.Set = true
+	return json.Unmarshal(data, &b.Value)
+}
+
+// MoveTaskRequest puts the task in the status, below the task AfterID and above the task BeforeID.
+// Either can be left out to place it next to the other, and both to put it at the bottom.
+type MoveTaskRequest struct {
+	StatusID uint  `json:"status_id"`
+	AfterID  *uint `json:"after_id"`
+	BeforeID *uint `json:"before_id"`
+	// Force closes the task even if tasks blocking it are still open
+	Force bool `json:"force"`
+}
+
+// TaskResponse is a task along with how far along its subtasks and checklist are, and
+// whether it's waiting on other tasks. Blocked counts every open blocker, but
+// OpenBlockerIDs leaves out those in projects the caller can't see.
+type TaskResponse struct {
+	models.Task
+	Progress       TaskProgress `json:"progress"`
+	Blocked        bool         `json:"blocked"`
+	OpenBlockerIDs []uint       `json:"open_blocker_ids"`
+}
+
+// TaskProgress counts every subtask below the task, however deeply nested, and the
+// task's own checklist items. Percent is of both together.
+type TaskProgress struct {
+	SubtasksDone   int `json:"subtasks_done"`
+	SubtasksTotal  int `json:"subtasks_total"`
+	ChecklistDone  int `json:"checklist_done"`
+	ChecklistTotal int `json:"checklist_total"`
+	Percent        int `json:"percent"`
+}
+
+// TaskTreeNode is a task with its checklist and its subtasks nested below it.
+type TaskTreeNode struct {
+	models.Task
+	Progress  TaskProgress           `json:"progress"`
+	Checklist []models.ChecklistItem `json:"checklist"`
+	Subtasks  []TaskTreeNode         `json:"subtasks"`
+}
+
+type CreateChecklistItemRequest struct {
+	Text string `json:"text"`
+	// Position defaults to after the task's last item
+	Position *int `json:"position"`
+}
+
+type UpdateChecklistItemRequest struct {
+	Text     string `json:"text"`
+	Done     *bool  `json:"done"`
+	Position *int   `json:"position"`
+}
+
+// CreateTaskDependencyRequest links the task to another, either as waiting on the
+// BlockedByID task or as blocking the BlocksID task. Exactly one has to be set.
+type CreateTaskDependencyRequest struct {
+	BlockedByID *uint `json:"blocked_by_id"`
+	BlocksID    *uint `json:"blocks_id"`
+}
+
+// TaskDependenciesResponse leaves out tasks in projects the caller can't see.
+type TaskDependenciesResponse struct {
+	BlockedBy []models.Task `json:"blocked_by"`
+	Blocks    []models.Task `json:"blocks"`
+}
+
+// RecurrenceRequest sets how a task recurs. Rule is an RRULE like "FREQ=WEEKLY;BYDAY=MO".
+// Trigger defaults to completion, and TimeZone to the caller's.
+type RecurrenceRequest struct {
+	Rule     string                   `json:"rule"`
+	Trigger  models.RecurrenceTrigger `json:"trigger"`
+	TimeZone string                   `json:"time_zone"`
+}
+
+// SetRecurrenceRequest changes how a task recurs. With the future scope, the default, the
+// rule applies to this instance and every one after it. With the this scope, it only
+// decides when the next instance is due, and an empty rule goes back to the series' rule.
+type SetRecurrenceRequest struct {
+	RecurrenceRequest
+	Scope string `json:"scope"`
+}
+
+type RecurrenceResponse struct {
+	Series models.TaskSeries `json:"series"`
+	// Override is the rule for the instance after this one, if it differs from the series'
+	Override *string     `json:"override"`
+	Upcoming []time.Time `json:"upcoming"`
+}
+
+type CreateCommentRequest struct {
+	Body string `json:"body"`
+	// ParentID makes the comment a reply to another comment on the task
+	ParentID *uint `json:"parent_id"`
+}
+
+type UpdateCommentRequest struct {
+	Body string `json:"body"`
+}
+
+// CommentResponse is a comment with its replies, oldest first. Deleted comments are only
+// shown, without their body, while they still have replies.
+type CommentResponse struct {
+	ID        uint              `json:"id"`
+	TaskID    uint              `json:"task_id"`
+	ParentID  *uint             `json:"parent_id"`
+	AuthorID  string            `json:"author_id"`
+	Body      string            `json:"body"`
+	Mentions  []string          `json:"mentions"`
+	EditedAt  *time.Time        `json:"edited_at"`
+	Deleted   bool              `json:"deleted"`
+	CreatedAt time.Time         `json:"created_at"`
+	Replies   []CommentResponse `json:"replies"`
+}
+
+// AttachmentResponse is an attachment with a signed URL to download it from, which
+// stops working at ExpiresAt.
+type AttachmentResponse struct {
+	models.Attachment
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TriageResponse sorts the caller's open tasks into the quadrants of an Eisenhower matrix,
+// each in priority then deadline order.
+type TriageResponse struct {
+	// DoFirst tasks are urgent and important
+	DoFirst []models.Task `json:"do_first"`
+	// Schedule tasks are important but not urgent
+	Schedule []models.Task `json:"schedule"`
+	// Delegate tasks are urgent but not important
+	Delegate []models.Task `json:"delegate"`
+	// Eliminate tasks are neither
+	Eliminate []models.Task `json:"eliminate"`
+}
+
+// ListTasksResponse is a page of tasks. NextCursor fetches the page after it, and is
+// left out on the last page.
+type ListTasksResponse struct {
+	Tasks      []models.Task `json:"tasks"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// CreateViewRequest saves a task query. The view is shared with the project's members if
+// ProjectID is set, and runs against that project unless the query names others.
+type CreateViewRequest struct {
+	Name      string              `json:"name"`
+	ProjectID *uint               `json:"project_id"`
+	Query     string              `json:"query"`
+	Sort      string              `json:"sort"`
+	GroupBy   models.TaskGrouping `json:"group_by"`
+}
+
+// UpdateViewRequest changes the fields that are set.
+type UpdateViewRequest struct {
+	Name    string               `json:"name"`
+	Query   *string              `json:"query"`
+	Sort    *string              `json:"sort"`
+	GroupBy *models.TaskGrouping `json:"group_by"`
+}
+
+// ViewTasksResponse is a page of the tasks a saved view finds. If the view is grouped,
+// Groups lists the groups on the page in the order they first appear, with the IDs of
+// their tasks. A task with several labels is in the group for each of them.
+type ViewTasksResponse struct {
+	View models.SavedView `json:"view"`
+	ListTasksResponse
+	Groups []TaskGroup `json:"groups,omitempty"`
+}
+
+// TaskGroup is the tasks that share a value for a view's grouping. Key is the value, like
+// a status ID or a priority, and is empty for tasks without one.
+type TaskGroup struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	TaskIDs []uint `json:"task_ids"`
+}
+
+// AgendaResponse sorts the caller's open tasks by when they're due, in days in the
+// caller's time zone. Tasks due more than a week from now are left out. Picked is the
+// caller's list for today, in the order they picked the tasks.
+type AgendaResponse struct {
+	Overdue []models.Task `json:"overdue"`
+	Today   []models.Task `json:"today"`
+	// Next7Days tasks are due in the seven days after today
+	Next7Days []models.Task `json:"next_7_days"`
+	NoDate    []models.Task `json:"no_date"`
+	Picked    []models.Task `json:"picked"`
 }
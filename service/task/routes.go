@@ -14,7 +14,44 @@ func (s *taskService) routes() {
 
 	r.HandleFunc("/", s.ListTasksHandler).Methods(http.MethodGet)
 	r.HandleFunc("/", s.CreateTaskHandler).Methods(http.MethodPost)
+	r.HandleFunc("/triage", s.TriageHandler).Methods(http.MethodGet)
+	r.HandleFunc("/agenda", s.AgendaHandler).Methods(http.MethodGet)
+	r.HandleFunc("/today", s.ListTodayHandler).Methods(http.MethodGet)
+	r.HandleFunc("/today/{id:[0-9]+}", s.AddTodayHandler).Methods(http.MethodPut)
+	r.HandleFunc("/today/{id:[0-9]+}", s.RemoveTodayHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/views", s.ListViewsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/views", s.CreateViewHandler).Methods(http.MethodPost)
+	r.HandleFunc("/views/{view_id:[0-9]+}", s.GetViewHandler).Methods(http.MethodGet)
+	r.HandleFunc("/views/{view_id:[0-9]+}", s.UpdateViewHandler).Methods(http.MethodPatch)
+	r.HandleFunc("/views/{view_id:[0-9]+}", s.DeleteViewHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/views/{view_id:[0-9]+}/tasks", s.RunViewHandler).Methods(http.MethodGet)
 	r.HandleFunc("/{id}", s.GetTaskHandler).Methods(http.MethodGet)
 	r.HandleFunc("/{id}", s.UpdateTaskHandler).Methods(http.MethodPatch)
 	r.HandleFunc("/{id}", s.DeleteTaskHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/{id:[0-9]+}/move", s.MoveTaskHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/tree", s.GetTaskTreeHandler).Methods(http.MethodGet)
+
+	r.HandleFunc("/{id:[0-9]+}/dependencies", s.ListTaskDependenciesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/dependencies", s.CreateTaskDependencyHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/dependencies/{blocker_id:[0-9]+}", s.DeleteTaskDependencyHandler).Methods(http.MethodDelete)
+
+	r.HandleFunc("/{id:[0-9]+}/recurrence", s.GetRecurrenceHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/recurrence", s.SetRecurrenceHandler).Methods(http.MethodPut)
+	r.HandleFunc("/{id:[0-9]+}/recurrence", s.StopRecurrenceHandler).Methods(http.MethodDelete)
+
+	r.HandleFunc("/{id:[0-9]+}/comments", s.ListCommentsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/comments", s.CreateCommentHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/comments/{comment_id:[0-9]+}", s.UpdateCommentHandler).Methods(http.MethodPatch)
+	r.HandleFunc("/{id:[0-9]+}/comments/{comment_id:[0-9]+}", s.DeleteCommentHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/{id:[0-9]+}/comments/{comment_id:[0-9]+}/revisions", s.ListCommentRevisionsHandler).Methods(http.MethodGet)
+
+	r.HandleFunc("/{id:[0-9]+}/attachments", s.ListAttachmentsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/attachments", s.UploadAttachmentHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/attachments/{attachment_id:[0-9]+}", s.GetAttachmentHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/attachments/{attachment_id:[0-9]+}", s.DeleteAttachmentHandler).Methods(http.MethodDelete)
+
+	r.HandleFunc("/{id:[0-9]+}/checklist", s.ListChecklistItemsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/{id:[0-9]+}/checklist", s.CreateChecklistItemHandler).Methods(http.MethodPost)
+	r.HandleFunc("/{id:[0-9]+}/checklist/{item_id:[0-9]+}", s.UpdateChecklistItemHandler).Methods(http.MethodPatch)
+	r.HandleFunc("/{id:[0-9]+}/checklist/{item_id:[0-9]+}", s.DeleteChecklistItemHandler).Methods(http.MethodDelete)
 }
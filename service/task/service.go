@@ -2,14 +2,20 @@ package task
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/todanni/api/blob"
+	"github.com/todanni/api/email"
 	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
 	"github.com/todanni/api/repository"
 	"github.com/todanni/api/token"
 )
@@ -20,21 +26,164 @@ type TasksService interface {
 	UpdateTaskHandler(w http.ResponseWriter, r *http.Request)
 	ListTasksHandler(w http.ResponseWriter, r *http.Request)
 	DeleteTaskHandler(w http.ResponseWriter, r *http.Request)
+	MoveTaskHandler(w http.ResponseWriter, r *http.Request)
+	GetTaskTreeHandler(w http.ResponseWriter, r *http.Request)
+	TriageHandler(w http.ResponseWriter, r *http.Request)
+	AgendaHandler(w http.ResponseWriter, r *http.Request)
+	ListTodayHandler(w http.ResponseWriter, r *http.Request)
+	AddTodayHandler(w http.ResponseWriter, r *http.Request)
+	RemoveTodayHandler(w http.ResponseWriter, r *http.Request)
+
+	ListChecklistItemsHandler(w http.ResponseWriter, r *http.Request)
+	CreateChecklistItemHandler(w http.ResponseWriter, r *http.Request)
+	UpdateChecklistItemHandler(w http.ResponseWriter, r *http.Request)
+	DeleteChecklistItemHandler(w http.ResponseWriter, r *http.Request)
+
+	ListTaskDependenciesHandler(w http.ResponseWriter, r *http.Request)
+	CreateTaskDependencyHandler(w http.ResponseWriter, r *http.Request)
+	DeleteTaskDependencyHandler(w http.ResponseWriter, r *http.Request)
+
+	GetRecurrenceHandler(w http.ResponseWriter, r *http.Request)
+	SetRecurrenceHandler(w http.ResponseWriter, r *http.Request)
+	StopRecurrenceHandler(w http.ResponseWriter, r *http.Request)
+
+	ListCommentsHandler(w http.ResponseWriter, r *http.Request)
+	CreateCommentHandler(w http.ResponseWriter, r *http.Request)
+	UpdateCommentHandler(w http.ResponseWriter, r *http.Request)
+	DeleteCommentHandler(w http.ResponseWriter, r *http.Request)
+	ListCommentRevisionsHandler(w http.ResponseWriter, r *http.Request)
+
+	ListAttachmentsHandler(w http.ResponseWriter, r *http.Request)
+	UploadAttachmentHandler(w http.ResponseWriter, r *http.Request)
+	GetAttachmentHandler(w http.ResponseWriter, r *http.Request)
+	DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request)
+
+	ListViewsHandler(w http.ResponseWriter, r *http.Request)
+	CreateViewHandler(w http.ResponseWriter, r *http.Request)
+	GetViewHandler(w http.ResponseWriter, r *http.Request)
+	UpdateViewHandler(w http.ResponseWriter, r *http.Request)
+	DeleteViewHandler(w http.ResponseWriter, r *http.Request)
+	RunViewHandler(w http.ResponseWriter, r *http.Request)
+}
+
+const (
+	DefaultMaxSubtaskDepth     = 3
+	DefaultAttachmentMaxSize   = 25 << 20
+	DefaultAttachmentURLExpiry = 15 * time.Minute
+	DefaultUrgentWithin        = 48 * time.Hour
+)
+
+// DefaultAttachmentContentTypes are the files that can be attached to tasks unless the
+// options say otherwise.
+var DefaultAttachmentContentTypes = []string{"image/*", "application/pdf", "text/plain", "text/markdown", "text/csv"}
+
+// Options configures the task service.
+type Options struct {
+	// MaxSubtaskDepth is how many levels of subtasks can be nested under a top level task.
+	MaxSubtaskDepth int
+
+	// RecurrenceInterval is how often scheduled recurring tasks are checked for instances
+	// to create. The check is turned off if it's 0.
+	RecurrenceInterval time.Duration
+
+	// AttachmentMaxSize is the largest file in bytes that can be attached to a task.
+	AttachmentMaxSize int64
+	// AttachmentProjectQuota is how many bytes of attachments a project can have in
+	// total. There's no limit if it's 0.
+	AttachmentProjectQuota int64
+	// AttachmentContentTypes are the media types that can be attached. A type can end in
+	// /* to allow all of its subtypes.
+	AttachmentContentTypes []string
+	// AttachmentURLExpiry is how long attachment download links work for.
+	AttachmentURLExpiry time.Duration
+
+	// UrgentWithin is how close to its deadline a task has to be to count as urgent in
+	// the triage view, unless it's been marked urgent or not.
+	UrgentWithin time.Duration
 }
 
 type taskService struct {
-	router     *mux.Router
-	middleware token.AuthMiddleware
-	taskRepo   repository.TaskRepository
+	router        *mux.Router
+	middleware    token.AuthMiddleware
+	taskRepo      repository.TaskRepository
+	statusRepo    repository.TaskStatusRepository
+	checklistRepo repository.ChecklistRepository
+	dependencies  repository.TaskDependencyRepository
+	seriesRepo    repository.TaskSeriesRepository
+	userRepo      repository.UserRepository
+	projectRepo   repository.ProjectRepository
+	commentRepo   repository.TaskCommentRepository
+	attachments   repository.AttachmentRepository
+	labelRepo     repository.LabelRepository
+	savedViews    repository.SavedViewRepository
+	today         repository.TodayRepository
+	blobs         blob.Store
+	emailClient   email.SenderClient
+	permissions   permission.Checker
+	options       Options
 }
 
-func NewTaskService(r *mux.Router, taskRepo repository.TaskRepository, mw token.AuthMiddleware) TasksService {
+func NewTaskService(
+	r *mux.Router,
+	taskRepo repository.TaskRepository,
+	statusRepo repository.TaskStatusRepository,
+	checklistRepo repository.ChecklistRepository,
+	dependencies repository.TaskDependencyRepository,
+	seriesRepo repository.TaskSeriesRepository,
+	userRepo repository.UserRepository,
+	projectRepo repository.ProjectRepository,
+	commentRepo repository.TaskCommentRepository,
+	attachments repository.AttachmentRepository,
+	labelRepo repository.LabelRepository,
+	savedViews repository.SavedViewRepository,
+	today repository.TodayRepository,
+	blobs blob.Store,
+	emailClient email.SenderClient,
+	mw token.AuthMiddleware,
+	permissions permission.Checker,
+	options Options,
+) TasksService {
+	if options.MaxSubtaskDepth <= 0 {
+		options.MaxSubtaskDepth = DefaultMaxSubtaskDepth
+	}
+	if options.AttachmentMaxSize <= 0 {
+		options.AttachmentMaxSize = DefaultAttachmentMaxSize
+	}
+	if len(options.AttachmentContentTypes) == 0 {
+		options.AttachmentContentTypes = DefaultAttachmentContentTypes
+	}
+	if options.AttachmentURLExpiry <= 0 {
+		options.AttachmentURLExpiry = DefaultAttachmentURLExpiry
+	}
+	if options.UrgentWithin <= 0 {
+		options.UrgentWithin = DefaultUrgentWithin
+	}
+
 	service := &taskService{
-		router:     r,
-		taskRepo:   taskRepo,
-		middleware: mw,
+		router:        r,
+		taskRepo:      taskRepo,
+		statusRepo:    statusRepo,
+		checklistRepo: checklistRepo,
+		dependencies:  dependencies,
+		seriesRepo:    seriesRepo,
+		userRepo:      userRepo,
+		projectRepo:   projectRepo,
+		commentRepo:   commentRepo,
+		attachments:   attachments,
+		labelRepo:     labelRepo,
+		savedViews:    savedViews,
+		today:         today,
+		blobs:         blobs,
+		emailClient:   emailClient,
+		middleware:    mw,
+		permissions:   permissions,
+		options:       options,
 	}
 	service.routes()
+
+	if options.RecurrenceInterval > 0 {
+		go service.scheduleRecurringTasks(options.RecurrenceInterval)
+	}
 	return service
 }
 
@@ -56,8 +205,8 @@ func (s *taskService) CreateTaskHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Check if the user belongs to the specified in the request project
-	if !accessToken.HasProjectPermission(createRequest.ProjectID) {
+	// Check if the user can create tasks in the project specified in the request
+	if !s.permissions.Can(accessToken, createRequest.ProjectID, permission.CreateTask) {
 		log.Infof("user with ID %s doesn't have permissions for project %d",
 			userID, createRequest.ProjectID)
 		http.Error(w, "user unauthorized for this project", http.StatusForbidden)
@@ -67,13 +216,13 @@ func (s *taskService) CreateTaskHandler(w http.ResponseWriter, r *http.Request)
 	if err = validation.ValidateStruct(&createRequest,
 		validation.Field(&createRequest.Title, validation.Required),
 		validation.Field(&createRequest.ProjectID, validation.Required),
+		validation.Field(&createRequest.Priority, validation.In(priorityValues...)),
 	); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Call DB and persist task
-	task, err := s.taskRepo.CreateTask(models.Task{
+	newTask := models.Task{
 		Title:       createRequest.Title,
 		Description: &createRequest.Description,
 		Done:        &createRequest.Done,
@@ -81,13 +230,69 @@ func (s *taskService) CreateTaskHandler(w http.ResponseWriter, r *http.Request)
 		CreatedBy:   userID,
 		AssignedTo:  &createRequest.AssignedTo,
 		Deadline:    createRequest.Deadline,
-	})
+		Priority:    createRequest.Priority,
+		Urgent:      createRequest.Urgent,
+		Important:   createRequest.Important,
+	}
+
+	labels, ok := s.resolveLabels(w, createRequest.ProjectID, createRequest.LabelIDs)
+	if !ok {
+		return
+	}
+
+	if createRequest.ParentID != nil {
+		if !s.checkParent(w, *createRequest.ParentID, createRequest.ProjectID, TaskTreeNode{}) {
+			return
+		}
+		newTask.ParentID = createRequest.ParentID
+	}
+
+	status, ok := s.resolveStatus(w, createRequest.ProjectID, nil, createRequest.StatusID, &createRequest.Done)
+	if !ok {
+		return
+	}
+	if status != nil {
+		done := status.Category == models.ClosedCategory
+		newTask.StatusID = &status.ID
+		newTask.Done = &done
+	}
 
+	// Call DB and persist task, along with its series if it recurs
+	var task models.Task
+	if createRequest.Recurrence != nil {
+		if createRequest.Deadline.IsZero() {
+			http.Error(w, "recurring tasks need a deadline", http.StatusBadRequest)
+			return
+		}
+
+		series, ok := s.newTaskSeries(w, userID, createRequest.ProjectID, createRequest.Deadline, *createRequest.Recurrence, nil)
+		if !ok {
+			return
+		}
+		_, task, err = s.seriesRepo.CreateTaskSeries(series, newTask)
+	} else {
+		task, err = s.taskRepo.CreateTask(newTask)
+	}
+
+	if errors.Is(err, repository.ErrWIPLimitReached) {
+		http.Error(w, fmt.Sprintf("%s is at its work in progress limit", status.Name), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "couldn't create task", http.StatusInternalServerError)
 		return
 	}
 
+	if len(labels) > 0 {
+		err = s.labelRepo.SetTaskLabels(task, labels)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't label task", http.StatusInternalServerError)
+			return
+		}
+	}
+	task.Labels = labels
+
 	responseBody, err := json.Marshal(task)
 	if err != nil {
 		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
@@ -98,6 +303,11 @@ func (s *taskService) CreateTaskHandler(w http.ResponseWriter, r *http.Request)
 	w.Write(responseBody)
 }
 
+// ListTasksHandler lists the tasks matching the q parameter, written in the taskquery
+// language, in sort order. The response is a plain array of tasks, as it was before
+// paging, and every task is listed unless a limit or cursor is sent. When there are more
+// tasks, the cursor for the next page is in the X-Next-Cursor header. The project_id,
+// label and label_match parameters are still read for older clients.
 func (s *taskService) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
 	// Read the user's JWT and get the user ID from it
 	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
@@ -108,25 +318,46 @@ func (s *taskService) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectID := r.URL.Query().Get("project_id")
-	var tasks []models.Task
-	var err error
-	if projectID != "" {
-		tasks, err = s.taskRepo.ListTasksByProject(projectID)
-	} else {
-		tasks, err = s.taskRepo.ListTasksByUser(userID)
+	query := r.URL.Query()
+	filter, ok := s.parseTaskQuery(w, userID, query.Get("q"))
+	if !ok {
+		return
+	}
+
+	if projectID := query.Get("project_id"); projectID != "" {
+		id, err := strconv.ParseUint(projectID, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid project ID", http.StatusBadRequest)
+			return
+		}
+		filter.ProjectIDs = append(filter.ProjectIDs, uint(id))
 	}
 
+	labelGroups, err := parseLabelGroups(query)
 	if err != nil {
-		http.Error(w, "couldn't look up tasks for user", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.LabelGroups = append(filter.LabelGroups, labelGroups...)
+
+	defaultLimit := 0
+	if query.Get("cursor") != "" {
+		defaultLimit = DefaultPageSize
+	}
+	response, ok := s.listTasks(w, r, accessToken, filter, query.Get("sort"), defaultLimit)
+	if !ok {
 		return
 	}
-	responseBody, err := json.Marshal(tasks)
+
+	responseBody, err := json.Marshal(response.Tasks)
 	if err != nil {
 		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
 		return
 	}
 
+	if response.NextCursor != "" {
+		w.Header().Add("X-Next-Cursor", response.NextCursor)
+	}
 	w.Header().Add("Content-Type", "application/json")
 	w.Write(responseBody)
 }
@@ -149,12 +380,32 @@ func (s *taskService) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !accessToken.HasProjectPermission(task.ProjectID) {
+	if !s.permissions.HasProjectPermission(accessToken, task.ProjectID) {
 		http.Error(w, "you don't have access", http.StatusForbidden)
 		return
 	}
 
-	responseBody, err := json.Marshal(task)
+	tree, ok := s.getTaskTree(w, task, false)
+	if !ok {
+		return
+	}
+
+	blockers, ok := s.openBlockers(w, task)
+	if !ok {
+		return
+	}
+
+	response := TaskResponse{
+		Task:           task,
+		Progress:       tree.Progress,
+		Blocked:        len(blockers) > 0,
+		OpenBlockerIDs: make([]uint, 0, len(blockers)),
+	}
+	for _, blocker := range s.visibleTasks(accessToken, blockers) {
+		response.OpenBlockerIDs = append(response.OpenBlockerIDs, blocker.ID)
+	}
+
+	responseBody, err := json.Marshal(response)
 	if err != nil {
 		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
 		return
@@ -181,8 +432,8 @@ func (s *taskService) UpdateTaskHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if !accessToken.HasProjectPermission(task.ProjectID) {
-		http.Error(w, "you don't have access", http.StatusForbidden)
+	if !s.permissions.Can(accessToken, task.ProjectID, permission.EditTask) {
+		http.Error(w, "you don't have permission to edit tasks in this project", http.StatusForbidden)
 		return
 	}
 
@@ -193,26 +444,119 @@ func (s *taskService) UpdateTaskHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err = validation.ValidateStruct(&updateRequest,
+		validation.Field(&updateRequest.Priority, validation.In(priorityValues...)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	taskIDUint, err := strconv.ParseUint(taskID, 10, 0)
 	if err != nil {
 		http.Error(w, "invalid task ID", http.StatusBadRequest)
 		return
 	}
 
-	updatedTask, err := s.taskRepo.UpdateTask(models.Task{
+	update := models.Task{
 		ID:          uint(taskIDUint),
 		Title:       updateRequest.Title,
 		Description: &updateRequest.Description,
-		Done:        &updateRequest.Done,
 		AssignedTo:  &updateRequest.AssignedTo,
 		Deadline:    updateRequest.Deadline,
-	})
+		Priority:    updateRequest.Priority,
+	}
 
+	// Moving a task moves its subtasks with it, so they count towards the depth limit
+	detach := false
+	if updateRequest.ParentID != nil {
+		if *updateRequest.ParentID == 0 {
+			detach = task.ParentID != nil
+		} else if task.ParentID == nil || *task.ParentID != *updateRequest.ParentID {
+			tree, ok := s.getTaskTree(w, task, false)
+			if !ok {
+				return
+			}
+			if !s.checkParent(w, *updateRequest.ParentID, task.ProjectID, tree) {
+				return
+			}
+			update.ParentID = updateRequest.ParentID
+		}
+	}
+
+	labels := task.Labels
+	if updateRequest.LabelIDs != nil {
+		var ok bool
+		labels, ok = s.resolveLabels(w, task.ProjectID, *updateRequest.LabelIDs)
+		if !ok {
+			return
+		}
+	}
+
+	status, ok := s.resolveStatus(w, task.ProjectID, task.StatusID, updateRequest.StatusID, updateRequest.Done)
+	if !ok {
+		return
+	}
+	closing := closesTask(task, status, updateRequest.Done)
+	if closing && !updateRequest.Force && !s.checkNotBlocked(w, task) {
+		return
+	}
+	if status != nil {
+		done := status.Category == models.ClosedCategory
+		update.StatusID = &status.ID
+		update.Done = &done
+	} else if updateRequest.Done != nil {
+		update.Done = updateRequest.Done
+	}
+
+	updatedTask, err := s.taskRepo.UpdateTask(update)
+	if errors.Is(err, repository.ErrWIPLimitReached) {
+		http.Error(w, fmt.Sprintf("%s is at its work in progress limit", status.Name), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "couldn't update task", http.StatusInternalServerError)
 		return
 	}
 
+	if detach {
+		err = s.taskRepo.SetTaskParent(task.ID, nil)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't update task", http.StatusInternalServerError)
+			return
+		}
+		updatedTask.ParentID = nil
+	}
+
+	if updateRequest.Urgent.Set || updateRequest.Important.Set {
+		if updateRequest.Urgent.Set {
+			updatedTask.Urgent = updateRequest.Urgent.Value
+		}
+		if updateRequest.Important.Set {
+			updatedTask.Important = updateRequest.Important.Value
+		}
+		err = s.taskRepo.SetTaskTriage(task.ID, updatedTask.Urgent, updatedTask.Important)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't update task", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if updateRequest.LabelIDs != nil {
+		err = s.labelRepo.SetTaskLabels(task, labels)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't label task", http.StatusInternalServerError)
+			return
+		}
+	}
+	updatedTask.Labels = labels
+
+	if closing && updatedTask.SeriesID != nil {
+		s.continueSeries(updatedTask)
+	}
+
 	responseBody, err := json.Marshal(updatedTask)
 	if err != nil {
 		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
@@ -235,7 +579,7 @@ func (s *taskService) DeleteTaskHandler(w http.ResponseWriter, r *http.Request)
 	params := mux.Vars(r)
 	taskID := params["id"]
 
-	// Only the person who created the task can delete it
+	// Members can delete the tasks they created, admins can delete any task
 	task, err := s.taskRepo.GetTaskByID(taskID)
 	if err != nil {
 		log.Error(err)
@@ -243,17 +587,49 @@ func (s *taskService) DeleteTaskHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if task.CreatedBy != userID {
-		log.Error(err)
-		http.Error(w, "only the person who created the task can delete it", http.StatusForbidden)
+	canDeleteAny := s.permissions.Can(accessToken, task.ProjectID, permission.DeleteAnyTask)
+	canDelete := canDeleteAny ||
+		(task.CreatedBy == userID && s.permissions.Can(accessToken, task.ProjectID, permission.DeleteTask))
+	if !canDelete {
+		http.Error(w, "you don't have permission to delete this task", http.StatusForbidden)
+		return
+	}
+
+	// Subtasks move up to the task's parent unless they're deleted too
+	var cascade bool
+	switch r.URL.Query().Get("subtasks") {
+	case "", "reparent":
+	case "cascade":
+		cascade = true
+	default:
+		http.Error(w, "subtasks must be cascade or reparent", http.StatusBadRequest)
 		return
 	}
 
-	err = s.taskRepo.DeleteTask(taskID)
+	if cascade && !canDeleteAny {
+		subtasks, err := s.taskRepo.ListSubtasks(task.ID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't look up subtasks", http.StatusInternalServerError)
+			return
+		}
+		for _, subtask := range subtasks {
+			if subtask.CreatedBy != userID {
+				http.Error(w, "you don't have permission to delete all of this task's subtasks", http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	attachments, err := s.taskRepo.DeleteTaskTree(task, cascade)
 	if err != nil {
-		log.Error()
+		log.Error(err)
 		http.Error(w, "couldn't delete task", http.StatusInternalServerError)
 		return
 	}
+
+	for _, attachment := range attachments {
+		s.deleteBlob(attachment.StorageKey)
+	}
 	w.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,79 @@
+package task
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+)
+
+// resolveStatus works out which status a task should move to, from either the status ID
+// or the done flag in the request. It returns nil if neither is set, or the project has no
+// statuses. It writes the error response and returns false if the status isn't one of the
+// project's or the move isn't allowed. WIP limits are checked when the task is saved.
+func (s *taskService) resolveStatus(w http.ResponseWriter, projectID uint, current *uint, statusID *uint, done *bool) (*models.TaskStatus, bool) {
+	var target *models.TaskStatus
+
+	switch {
+	case statusID != nil:
+		status, err := s.statusRepo.GetTaskStatusByID(*statusID)
+		if err != nil || status.ProjectID != projectID {
+			http.Error(w, "invalid status ID", http.StatusBadRequest)
+			return nil, false
+		}
+		target = &status
+
+	case done != nil:
+		statuses, err := s.statusRepo.ListTaskStatuses(projectID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't look up task statuses", http.StatusInternalServerError)
+			return nil, false
+		}
+		target = statusForDone(statuses, current, *done)
+
+	default:
+		return nil, true
+	}
+
+	if target == nil || (current != nil && *current == target.ID) {
+		return target, true
+	}
+
+	if current != nil {
+		allowed, err := s.statusRepo.IsTransitionAllowed(projectID, *current, target.ID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, "couldn't check status transition", http.StatusInternalServerError)
+			return nil, false
+		}
+		if !allowed {
+			http.Error(w, "moving the task to that status isn't allowed", http.StatusConflict)
+			return nil, false
+		}
+	}
+
+	return target, true
+}
+
+// statusForDone returns the status a task should be in when marked as done or not done.
+// A task already in a status matching done stays there, otherwise it goes to the first
+// closed or open status.
+func statusForDone(statuses []models.TaskStatus, current *uint, done bool) *models.TaskStatus {
+	category := models.OpenCategory
+	if done {
+		category = models.ClosedCategory
+	}
+
+	var first *models.TaskStatus
+	for i, status := range statuses {
+		if current != nil && status.ID == *current && (status.Category == models.ClosedCategory) == done {
+			return &statuses[i]
+		}
+		if first == nil && status.Category == category {
+			first = &statuses[i]
+		}
+	}
+	return first
+}
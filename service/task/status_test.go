@@ -0,0 +1,33 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestStatusForDone(t *testing.T) {
+	statuses := []models.TaskStatus{
+		{ID: 1, Category: models.OpenCategory},
+		{ID: 2, Category: models.ActiveCategory},
+		{ID: 3, Category: models.ClosedCategory},
+		{ID: 4, Category: models.ClosedCategory},
+	}
+	id := func(id uint) *uint { return &id }
+
+	// New tasks go to the first status of the category
+	require.Equal(t, uint(1), statusForDone(statuses, nil, false).ID)
+	require.Equal(t, uint(3), statusForDone(statuses, nil, true).ID)
+
+	// Tasks already in a matching status stay there
+	require.Equal(t, uint(4), statusForDone(statuses, id(4), true).ID)
+	require.Equal(t, uint(2), statusForDone(statuses, id(2), false).ID)
+
+	// Otherwise they move
+	require.Equal(t, uint(3), statusForDone(statuses, id(2), true).ID)
+	require.Equal(t, uint(1), statusForDone(statuses, id(4), false).ID)
+
+	require.Nil(t, statusForDone(nil, nil, true))
+}
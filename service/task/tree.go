@@ -0,0 +1,170 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+)
+
+// GetTaskTreeHandler returns the task with its checklist and all of its subtasks nested
+// below it, with progress rolled up at each level.
+func (s *taskService) GetTaskTreeHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.getTaskWithPermission(w, r, permission.ViewProject)
+	if !ok {
+		return
+	}
+
+	tree, ok := s.getTaskTree(w, task, true)
+	if !ok {
+		return
+	}
+
+	responseBody, err := json.Marshal(tree)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// buildTaskTree nests each subtask under its parent and rolls their progress up to
+// the task, given every task below it and the checklist items of all of them.
+func buildTaskTree(task models.Task, subtasks []models.Task, items []models.ChecklistItem) TaskTreeNode {
+	children := make(map[uint][]models.Task)
+	for _, subtask := range subtasks {
+		if subtask.ParentID != nil {
+			children[*subtask.ParentID] = append(children[*subtask.ParentID], subtask)
+		}
+	}
+
+	checklists := make(map[uint][]models.ChecklistItem)
+	for _, item := range items {
+		checklists[item.TaskID] = append(checklists[item.TaskID], item)
+	}
+
+	return buildTaskTreeNode(task, children, checklists)
+}
+
+func buildTaskTreeNode(task models.Task, children map[uint][]models.Task, checklists map[uint][]models.ChecklistItem) TaskTreeNode {
+	node := TaskTreeNode{
+		Task:      task,
+		Checklist: []models.ChecklistItem{},
+		Subtasks:  []TaskTreeNode{},
+	}
+	progress := &node.Progress
+
+	for _, child := range children[task.ID] {
+		childNode := buildTaskTreeNode(child, children, checklists)
+		progress.SubtasksTotal += 1 + childNode.Progress.SubtasksTotal
+		progress.SubtasksDone += childNode.Progress.SubtasksDone
+		if child.Done != nil && *child.Done {
+			progress.SubtasksDone++
+		}
+		node.Subtasks = append(node.Subtasks, childNode)
+	}
+
+	for _, item := range checklists[task.ID] {
+		progress.ChecklistTotal++
+		if item.Done {
+			progress.ChecklistDone++
+		}
+		node.Checklist = append(node.Checklist, item)
+	}
+
+	// A task with nothing below it is all or nothing
+	total := progress.SubtasksTotal + progress.ChecklistTotal
+	switch {
+	case total > 0:
+		progress.Percent = 100 * (progress.SubtasksDone + progress.ChecklistDone) / total
+	case task.Done != nil && *task.Done:
+		progress.Percent = 100
+	}
+	return node
+}
+
+// height returns how many levels of subtasks are below the node.
+func (n TaskTreeNode) height() int {
+	height := 0
+	for _, subtask := range n.Subtasks {
+		if h := subtask.height() + 1; h > height {
+			height = h
+		}
+	}
+	return height
+}
+
+// contains returns whether the task is the node or one of its subtasks.
+func (n TaskTreeNode) contains(taskID uint) bool {
+	if n.ID == taskID {
+		return true
+	}
+	for _, subtask := range n.Subtasks {
+		if subtask.contains(taskID) {
+			return true
+		}
+	}
+	return false
+}
+
+// getTaskTree returns the task with all of its subtasks and their checklists. It writes
+// the error response and returns false if it can't.
+func (s *taskService) getTaskTree(w http.ResponseWriter, task models.Task, withSubtaskChecklists bool) (TaskTreeNode, bool) {
+	subtasks, err := s.taskRepo.ListSubtasks(task.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up subtasks", http.StatusInternalServerError)
+		return TaskTreeNode{}, false
+	}
+
+	taskIDs := []uint{task.ID}
+	if withSubtaskChecklists {
+		for _, subtask := range subtasks {
+			taskIDs = append(taskIDs, subtask.ID)
+		}
+	}
+
+	items, err := s.checklistRepo.ListChecklistItems(taskIDs...)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up checklist", http.StatusInternalServerError)
+		return TaskTreeNode{}, false
+	}
+	return buildTaskTree(task, subtasks, items), true
+}
+
+// checkParent checks the task can become a subtask of the parent: the parent has to be
+// in the same project, can't be below the task, and the task's subtasks have to stay
+// within the depth limit. It writes the error response and returns false if not.
+func (s *taskService) checkParent(w http.ResponseWriter, parentID uint, projectID uint, tree TaskTreeNode) bool {
+	parent, err := s.taskRepo.GetTaskByID(strconv.FormatUint(uint64(parentID), 10))
+	if err != nil || parent.ProjectID != projectID {
+		http.Error(w, "parent_id must be a task in the same project", http.StatusBadRequest)
+		return false
+	}
+
+	if tree.ID != 0 && tree.contains(parent.ID) {
+		http.Error(w, "a task can't be a subtask of itself or its subtasks", http.StatusBadRequest)
+		return false
+	}
+
+	depth, err := s.taskRepo.TaskDepth(parent.ID)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up parent task", http.StatusInternalServerError)
+		return false
+	}
+
+	if depth+1+tree.height() > s.options.MaxSubtaskDepth {
+		http.Error(w, fmt.Sprintf("subtasks can only be nested %d levels deep", s.options.MaxSubtaskDepth), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestBuildTaskTree(t *testing.T) {
+	id := func(id uint) *uint { return &id }
+	done := func(done bool) *bool { return &done }
+
+	root := models.Task{ID: 1, Done: done(false)}
+	subtasks := []models.Task{
+		{ID: 2, ParentID: id(1), Done: done(true)},
+		{ID: 3, ParentID: id(1), Done: done(false)},
+		{ID: 4, ParentID: id(3), Done: done(true)},
+		{ID: 5, ParentID: id(4), Done: done(false)},
+	}
+	items := []models.ChecklistItem{
+		{ID: 1, TaskID: 1, Done: true},
+		{ID: 2, TaskID: 1, Done: false},
+		{ID: 3, TaskID: 4, Done: true},
+	}
+
+	tree := buildTaskTree(root, subtasks, items)
+
+	// Every subtask below the root counts, along with the root's own checklist
+	require.Equal(t, TaskProgress{
+		SubtasksDone:   2,
+		SubtasksTotal:  4,
+		ChecklistDone:  1,
+		ChecklistTotal: 2,
+		Percent:        50,
+	}, tree.Progress)
+	require.Len(t, tree.Subtasks, 2)
+	require.Len(t, tree.Checklist, 2)
+	require.Equal(t, 3, tree.height())
+
+	// Subtasks roll up their own subtasks
+	middle := tree.Subtasks[1]
+	require.Equal(t, uint(3), middle.ID)
+	require.Equal(t, 2, middle.Progress.SubtasksTotal)
+	require.Equal(t, 1, middle.Progress.SubtasksDone)
+	require.Equal(t, 2, middle.height())
+
+	require.True(t, tree.contains(5))
+	require.False(t, middle.contains(2))
+
+	// A task with nothing below it is either done or not
+	leaf := tree.Subtasks[0]
+	require.Equal(t, 100, leaf.Progress.Percent)
+	require.Equal(t, 0, leaf.height())
+	require.Equal(t, 0, middle.Subtasks[0].Subtasks[0].Progress.Percent)
+}
@@ -0,0 +1,133 @@
+package task
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/repository"
+	"github.com/todanni/api/token"
+)
+
+// priorities are in order, most pressing first
+var priorities = []models.Priority{
+	models.CriticalPriority, models.HighPriority, models.NormalPriority, models.LowPriority,
+}
+
+// priorityValues are the priorities for validation.In
+var priorityValues = []interface{}{
+	models.CriticalPriority, models.HighPriority, models.NormalPriority, models.LowPriority,
+}
+
+// TriageHandler returns the open tasks the caller created or is assigned to, sorted into
+// the quadrants of an Eisenhower matrix.
+func (s *taskService) TriageHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	userID := accessToken.GetUserID()
+	if userID == "" {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	open := false
+	tasks, _, err := s.taskRepo.ListTasks(repository.TaskFilter{
+		MemberID:          userID,
+		AllowedProjectIDs: accessToken.AllowedProjects(),
+		Involves:          []string{userID},
+		Done:              &open,
+	}, repository.TaskPage{})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up tasks for user", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(buildTriage(tasks, time.Now(), s.options.UrgentWithin))
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// buildTriage sorts the tasks into quadrants by whether they're urgent and important.
+func buildTriage(tasks []models.Task, now time.Time, urgentWithin time.Duration) TriageResponse {
+	response := TriageResponse{
+		DoFirst:   []models.Task{},
+		Schedule:  []models.Task{},
+		Delegate:  []models.Task{},
+		Eliminate: []models.Task{},
+	}
+
+	sortByPriority(tasks)
+	for _, task := range tasks {
+		urgent, important := isUrgent(task, now, urgentWithin), isImportant(task)
+		switch {
+		case urgent && important:
+			response.DoFirst = append(response.DoFirst, task)
+		case important:
+			response.Schedule = append(response.Schedule, task)
+		case urgent:
+			response.Delegate = append(response.Delegate, task)
+		default:
+			response.Eliminate = append(response.Eliminate, task)
+		}
+	}
+	return response
+}
+
+// isUrgent returns whether the task was marked urgent. If it wasn't marked either way,
+// it's urgent when it's overdue or due within urgentWithin.
+func isUrgent(task models.Task, now time.Time, urgentWithin time.Duration) bool {
+	if task.Urgent != nil {
+		return *task.Urgent
+	}
+	if task.Deadline.IsZero() {
+		return false
+	}
+	return !task.Deadline.After(now.Add(urgentWithin))
+}
+
+// isImportant returns whether the task was marked important. If it wasn't marked either
+// way, P0 and P1 tasks are important.
+func isImportant(task models.Task) bool {
+	if task.Important != nil {
+		return *task.Important
+	}
+	return task.Priority == models.CriticalPriority || task.Priority == models.HighPriority
+}
+
+// sortByPriority puts the most pressing tasks first, then the ones due soonest. Tasks
+// without a deadline go after those with one.
+func sortByPriority(tasks []models.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := priorityOrder(tasks[i].Priority), priorityOrder(tasks[j].Priority)
+		if a != b {
+			return a < b
+		}
+		if !tasks[i].Deadline.Equal(tasks[j].Deadline) {
+			if tasks[i].Deadline.IsZero() || tasks[j].Deadline.IsZero() {
+				return tasks[j].Deadline.IsZero()
+			}
+			return tasks[i].Deadline.Before(tasks[j].Deadline)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+// priorityOrder ranks tasks with no priority, from before priorities existed, as P2.
+func priorityOrder(priority models.Priority) int {
+	for i, p := range priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return 2
+}
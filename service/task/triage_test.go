@@ -0,0 +1,73 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestBuildTriage(t *testing.T) {
+	now := time.Date(2023, time.March, 6, 9, 0, 0, 0, time.UTC)
+	yes, no := true, false
+
+	tasks := []models.Task{
+		{ID: 1, Priority: models.CriticalPriority, Deadline: now.Add(24 * time.Hour)},
+		{ID: 2, Priority: models.HighPriority},
+		{ID: 3, Priority: models.LowPriority, Deadline: now.Add(-time.Hour)},
+		{ID: 4, Priority: models.NormalPriority, Deadline: now.Add(7 * 24 * time.Hour)},
+		// Explicit flags win over the deadline and priority
+		{ID: 5, Priority: models.LowPriority, Urgent: &yes, Important: &yes},
+		{ID: 6, Priority: models.CriticalPriority, Deadline: now, Urgent: &no, Important: &no},
+	}
+
+	triage := buildTriage(tasks, now, 48*time.Hour)
+	require.Equal(t, []uint{1, 5}, taskIDs(triage.DoFirst))
+	require.Equal(t, []uint{2}, taskIDs(triage.Schedule))
+	require.Equal(t, []uint{3}, taskIDs(triage.Delegate))
+	require.Equal(t, []uint{6, 4}, taskIDs(triage.Eliminate))
+
+	empty, err := json.Marshal(buildTriage(nil, now, time.Hour))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"do_first":[],"schedule":[],"delegate":[],"eliminate":[]}`, string(empty))
+}
+
+func TestSortByPriority(t *testing.T) {
+	now := time.Date(2023, time.March, 6, 9, 0, 0, 0, time.UTC)
+	tasks := []models.Task{
+		{ID: 1, Priority: models.LowPriority, Deadline: now},
+		{ID: 2, Priority: models.NormalPriority},
+		{ID: 3, Priority: models.NormalPriority, Deadline: now.Add(time.Hour)},
+		{ID: 4},
+		{ID: 5, Priority: models.NormalPriority, Deadline: now},
+		{ID: 6, Priority: models.CriticalPriority},
+	}
+
+	sortByPriority(tasks)
+	require.Equal(t, []uint{6, 5, 3, 2, 4, 1}, taskIDs(tasks))
+}
+
+func TestOptionalBool(t *testing.T) {
+	var request UpdateTaskRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"urgent": true, "important": null}`), &request))
+	require.True(t, request.Urgent.Set)
+	require.True(t, *request.Urgent.Value)
+	require.True(t, request.Important.Set)
+	require.Nil(t, request.Important.Value)
+
+	request = UpdateTaskRequest{}
+	require.NoError(t, json.Unmarshal([]byte(`{"title": "Ship it"}`), &request))
+	require.False(t, request.Urgent.Set)
+	require.False(t, request.Important.Set)
+}
+
+func taskIDs(tasks []models.Task) []uint {
+	ids := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
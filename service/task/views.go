@@ -0,0 +1,396 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/permission"
+	"github.com/todanni/api/taskquery"
+	"github.com/todanni/api/token"
+)
+
+// maxViewNameLength keeps view names short enough for a sidebar
+const maxViewNameLength = 100
+
+// taskGroupings are the values a view can be grouped by
+var taskGroupings = []interface{}{
+	models.NoGrouping, models.GroupByStatus, models.GroupByAssignee,
+	models.GroupByPriority, models.GroupByProject, models.GroupByLabel,
+}
+
+// ListViewsHandler lists the caller's personal views and the views shared with their projects.
+func (s *taskService) ListViewsHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	views, err := s.savedViews.ListSavedViews(accessToken.GetUserID())
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't look up views", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]models.SavedView, 0, len(views))
+	for _, view := range views {
+		if view.ProjectID == nil || accessToken.AllowsProject(*view.ProjectID) {
+			response = append(response, view)
+		}
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *taskService) CreateViewHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	var createRequest CreateViewRequest
+	err := json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	createRequest.Name = strings.TrimSpace(createRequest.Name)
+
+	if err = validation.ValidateStruct(&createRequest,
+		validation.Field(&createRequest.Name, validation.Required, validation.Length(1, maxViewNameLength)),
+		validation.Field(&createRequest.GroupBy, validation.In(taskGroupings...)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if createRequest.ProjectID != nil && !s.permissions.Can(accessToken, *createRequest.ProjectID, permission.ShareView) {
+		http.Error(w, "you don't have permission to share views with this project", http.StatusForbidden)
+		return
+	}
+
+	view := models.SavedView{
+		OwnerID:   accessToken.GetUserID(),
+		ProjectID: createRequest.ProjectID,
+		Name:      createRequest.Name,
+		Query:     createRequest.Query,
+		Sort:      createRequest.Sort,
+		GroupBy:   createRequest.GroupBy,
+	}
+	if !s.validateView(w, view) {
+		return
+	}
+
+	view, err = s.savedViews.CreateSavedView(view)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't save view", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(view)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseBody)
+}
+
+func (s *taskService) GetViewHandler(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.getView(w, r)
+	if !ok {
+		return
+	}
+
+	responseBody, err := json.Marshal(view)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// UpdateViewHandler lets users change their own views, and members who can edit the
+// project any view shared with it.
+func (s *taskService) UpdateViewHandler(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.getView(w, r)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	if !s.canChangeView(accessToken, view) {
+		http.Error(w, "you don't have permission to change this view", http.StatusForbidden)
+		return
+	}
+
+	var updateRequest UpdateViewRequest
+	err := json.NewDecoder(r.Body).Decode(&updateRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updateRequest.Name = strings.TrimSpace(updateRequest.Name)
+
+	if err = validation.ValidateStruct(&updateRequest,
+		validation.Field(&updateRequest.Name, validation.Length(1, maxViewNameLength)),
+	); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if updateRequest.Name != "" {
+		view.Name = updateRequest.Name
+	}
+	if updateRequest.Query != nil {
+		view.Query = *updateRequest.Query
+	}
+	if updateRequest.Sort != nil {
+		view.Sort = *updateRequest.Sort
+	}
+	if updateRequest.GroupBy != nil {
+		if err = validation.Validate(*updateRequest.GroupBy, validation.In(taskGroupings...)); err != nil {
+			http.Error(w, fmt.Sprintf("group_by: %v", err), http.StatusBadRequest)
+			return
+		}
+		view.GroupBy = *updateRequest.GroupBy
+	}
+	if !s.validateView(w, view) {
+		return
+	}
+
+	view, err = s.savedViews.UpdateSavedView(view)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't update view", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(view)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+func (s *taskService) DeleteViewHandler(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.getView(w, r)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	if !s.canChangeView(accessToken, view) {
+		http.Error(w, "you don't have permission to delete this view", http.StatusForbidden)
+		return
+	}
+
+	err := s.savedViews.DeleteSavedView(view)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "couldn't delete view", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RunViewHandler lists the tasks the view finds, the same way GET /tasks does for its
+// query and sort. The limit and cursor parameters page through them.
+func (s *taskService) RunViewHandler(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.getView(w, r)
+	if !ok {
+		return
+	}
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	filter, ok := s.parseTaskQuery(w, accessToken.GetUserID(), view.Query)
+	if !ok {
+		return
+	}
+	if view.ProjectID != nil && len(filter.ProjectIDs) == 0 {
+		filter.ProjectIDs = []uint{*view.ProjectID}
+	}
+
+	list, ok := s.listTasks(w, r, accessToken, filter, view.Sort, DefaultPageSize)
+	if !ok {
+		return
+	}
+
+	response := ViewTasksResponse{View: view, ListTasksResponse: list}
+	if view.GroupBy != models.NoGrouping {
+		response.Groups, ok = s.groupTasks(w, list.Tasks, view.GroupBy)
+		if !ok {
+			return
+		}
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "couldn't marshall body", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// getView returns the view from the request path if the caller can see it. Personal
+// views can only be seen by their owner, and shared views by the project's members.
+// It writes the error response and returns false if it can't.
+func (s *taskService) getView(w http.ResponseWriter, r *http.Request) (models.SavedView, bool) {
+	accessToken := r.Context().Value(token.AccessTokenContextKey).(*token.ToDanniToken)
+
+	viewID, err := strconv.ParseUint(mux.Vars(r)["view_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "invalid view ID", http.StatusBadRequest)
+		return models.SavedView{}, false
+	}
+
+	view, err := s.savedViews.GetSavedView(uint(viewID))
+	if err != nil {
+		http.Error(w, "couldn't find view", http.StatusNotFound)
+		return models.SavedView{}, false
+	}
+
+	if view.ProjectID == nil && view.OwnerID != accessToken.GetUserID() ||
+		view.ProjectID != nil && !s.permissions.Can(accessToken, *view.ProjectID, permission.ViewProject) {
+		http.Error(w, "couldn't find view", http.StatusNotFound)
+		return models.SavedView{}, false
+	}
+	return view, true
+}
+
+// canChangeView returns whether the caller can edit or delete the view.
+func (s *taskService) canChangeView(accessToken *token.ToDanniToken, view models.SavedView) bool {
+	if view.ProjectID == nil {
+		return view.OwnerID == accessToken.GetUserID()
+	}
+	if view.OwnerID == accessToken.GetUserID() && s.permissions.Can(accessToken, *view.ProjectID, permission.ShareView) {
+		return true
+	}
+	return s.permissions.Can(accessToken, *view.ProjectID, permission.EditProject)
+}
+
+// validateView checks the view's query and sort can be run. It writes the error response
+// and returns false if they can't.
+func (s *taskService) validateView(w http.ResponseWriter, view models.SavedView) bool {
+	if _, ok := s.parseTaskQuery(w, view.OwnerID, view.Query); !ok {
+		return false
+	}
+	if _, err := taskquery.ParseSort(view.Sort); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// groupTasks groups the tasks and names the groups by their statuses or projects where
+// that's what they're grouped by. It writes the error response and returns false if the
+// names can't be looked up.
+func (s *taskService) groupTasks(w http.ResponseWriter, tasks []models.Task, grouping models.TaskGrouping) ([]TaskGroup, bool) {
+	groups := buildTaskGroups(tasks, grouping)
+
+	names := map[string]string{}
+	switch grouping {
+	case models.GroupByStatus:
+		seen := map[uint]bool{}
+		for _, task := range tasks {
+			if seen[task.ProjectID] {
+				continue
+			}
+			seen[task.ProjectID] = true
+
+			statuses, err := s.statusRepo.ListTaskStatuses(task.ProjectID)
+			if err != nil {
+				log.Error(err)
+				http.Error(w, "couldn't look up task statuses", http.StatusInternalServerError)
+				return nil, false
+			}
+			for _, status := range statuses {
+				names[strconv.FormatUint(uint64(status.ID), 10)] = status.Name
+			}
+		}
+	case models.GroupByProject:
+		for _, group := range groups {
+			project, err := s.projectRepo.GetProjectByID(group.Key)
+			if err != nil {
+				log.Error(err)
+				http.Error(w, "couldn't look up projects", http.StatusInternalServerError)
+				return nil, false
+			}
+			names[group.Key] = project.Name
+		}
+	}
+
+	for i, group := range groups {
+		if name, ok := names[group.Key]; ok {
+			groups[i].Name = name
+		}
+	}
+	return groups, true
+}
+
+// buildTaskGroups groups the tasks in the order the groups first appear. Labels and
+// priorities are named after themselves, and the names of other groups are left for the
+// caller to fill in.
+func buildTaskGroups(tasks []models.Task, grouping models.TaskGrouping) []TaskGroup {
+	groups := []TaskGroup{}
+	index := map[string]int{}
+	add := func(key, name string, taskID uint) {
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, TaskGroup{Key: key, Name: name})
+		}
+		groups[i].TaskIDs = append(groups[i].TaskIDs, taskID)
+	}
+
+	for _, task := range tasks {
+		switch grouping {
+		case models.GroupByStatus:
+			if task.StatusID == nil {
+				add("", "No status", task.ID)
+				continue
+			}
+			add(strconv.FormatUint(uint64(*task.StatusID), 10), "", task.ID)
+		case models.GroupByAssignee:
+			if task.AssignedTo == nil || *task.AssignedTo == "" {
+				add("", "Unassigned", task.ID)
+				continue
+			}
+			add(*task.AssignedTo, *task.AssignedTo, task.ID)
+		case models.GroupByPriority:
+			add(string(task.Priority), string(task.Priority), task.ID)
+		case models.GroupByProject:
+			add(strconv.FormatUint(uint64(task.ProjectID), 10), "", task.ID)
+		case models.GroupByLabel:
+			if len(task.Labels) == 0 {
+				add("", "No label", task.ID)
+				continue
+			}
+			for _, label := range task.Labels {
+				add(strconv.FormatUint(uint64(label.ID), 10), label.Name, task.ID)
+			}
+		}
+	}
+	return groups
+}
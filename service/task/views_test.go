@@ -0,0 +1,53 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+)
+
+func TestBuildTaskGroups(t *testing.T) {
+	todo, doing := uint(10), uint(11)
+	alice, empty := "alice", ""
+	bug := models.Label{ID: 1, Name: "bug"}
+	design := models.Label{ID: 2, Name: "design"}
+
+	tasks := []models.Task{
+		{ID: 1, ProjectID: 1, StatusID: &doing, AssignedTo: &alice, Priority: models.HighPriority, Labels: []models.Label{bug, design}},
+		{ID: 2, ProjectID: 2, StatusID: &todo, AssignedTo: &empty, Priority: models.NormalPriority},
+		{ID: 3, ProjectID: 1, StatusID: &doing, Priority: models.HighPriority, Labels: []models.Label{design}},
+		{ID: 4, ProjectID: 1, Priority: models.NormalPriority},
+	}
+
+	require.Equal(t, []TaskGroup{
+		{Key: "11", TaskIDs: []uint{1, 3}},
+		{Key: "10", TaskIDs: []uint{2}},
+		{Key: "", Name: "No status", TaskIDs: []uint{4}},
+	}, buildTaskGroups(tasks, models.GroupByStatus))
+
+	require.Equal(t, []TaskGroup{
+		{Key: "alice", Name: "alice", TaskIDs: []uint{1}},
+		{Key: "", Name: "Unassigned", TaskIDs: []uint{2, 3, 4}},
+	}, buildTaskGroups(tasks, models.GroupByAssignee))
+
+	require.Equal(t, []TaskGroup{
+		{Key: "P1", Name: "P1", TaskIDs: []uint{1, 3}},
+		{Key: "P2", Name: "P2", TaskIDs: []uint{2, 4}},
+	}, buildTaskGroups(tasks, models.GroupByPriority))
+
+	require.Equal(t, []TaskGroup{
+		{Key: "1", TaskIDs: []uint{1, 3, 4}},
+		{Key: "2", TaskIDs: []uint{2}},
+	}, buildTaskGroups(tasks, models.GroupByProject))
+
+	// Tasks are in the group for each of their labels
+	require.Equal(t, []TaskGroup{
+		{Key: "1", Name: "bug", TaskIDs: []uint{1}},
+		{Key: "2", Name: "design", TaskIDs: []uint{1, 3}},
+		{Key: "", Name: "No label", TaskIDs: []uint{2, 4}},
+	}, buildTaskGroups(tasks, models.GroupByLabel))
+
+	require.Equal(t, []TaskGroup{}, buildTaskGroups(nil, models.GroupByStatus))
+}
@@ -0,0 +1,49 @@
+package taskquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/todanni/api/repository"
+)
+
+// cursor is what's inside the token for the next page. It remembers the sort it was made
+// for, since its values don't mean anything in another order.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+// EncodeCursor turns a cursor into an opaque token for the next page.
+func EncodeCursor(c repository.TaskCursor, order []repository.TaskOrder) string {
+	body, _ := json.Marshal(cursor{Sort: FormatSort(order), Values: c.Values})
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+// DecodeCursor reads a token from EncodeCursor, checking it was made for the same order.
+func DecodeCursor(token string, order []repository.TaskOrder) (repository.TaskCursor, error) {
+	body, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return repository.TaskCursor{}, repository.ErrInvalidCursor
+	}
+
+	var c cursor
+	if err = json.Unmarshal(body, &c); err != nil || c.Sort != FormatSort(order) {
+		return repository.TaskCursor{}, repository.ErrInvalidCursor
+	}
+	return repository.TaskCursor{Values: c.Values}, nil
+}
+
+// FormatSort writes the order the way ParseSort reads it.
+func FormatSort(order []repository.TaskOrder) string {
+	fields := make([]string, 0, len(order))
+	for _, o := range order {
+		if o.Desc {
+			fields = append(fields, "-"+string(o.Field))
+		} else {
+			fields = append(fields, string(o.Field))
+		}
+	}
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,343 @@
+// Package taskquery parses the query language used to filter and sort lists of tasks.
+//
+// A query is a list of terms separated by spaces. Terms like key:value filter on a field,
+// and anything else is text that has to appear in the task's title or description.
+// Values with spaces can be quoted, like status:"in progress" or "release notes".
+//
+//	project:1,2            tasks in any of the projects
+//	status:open            tasks in a status with the ID, name or category open, active or closed
+//	assignee:me            tasks assigned to the user ID, me, or none for unassigned tasks
+//	creator:me             tasks created by the user ID or me
+//	involves:me            tasks created by or assigned to the user ID or me
+//	label:bug,design       tasks with either label. Repeat the term to need both.
+//	priority:P0,P1         tasks with any of the priorities
+//	is:open                tasks that are open, or done with is:done
+//	deadline:<=+7d         tasks due in the next week or earlier, see below
+//	created:2023-01-01..   tasks created since the start of the year
+//	updated:yesterday      tasks last changed yesterday
+//
+// Listing several values separated by commas, or repeating a term, matches any of them,
+// except for labels. Deadline, created and updated take a date, a range of dates like
+// 2023-01-01..2023-01-31, or a date after >, >=, < or <=. Dates are YYYY-MM-DD, today,
+// yesterday, tomorrow, a number of days or weeks from today like -3d or +2w, or an RFC
+// 3339 time. deadline:none matches tasks without a deadline.
+package taskquery
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/repository"
+)
+
+// Context is what a query is evaluated against. Me is the caller's user ID, and dates are
+// days in Location relative to Now.
+type Context struct {
+	Me       string
+	Location *time.Location
+	Now      time.Time
+}
+
+// Parse turns the query into a task filter.
+func Parse(query string, ctx Context) (repository.TaskFilter, error) {
+	if ctx.Location == nil {
+		ctx.Location = time.UTC
+	}
+
+	terms, err := split(query)
+	if err != nil {
+		return repository.TaskFilter{}, err
+	}
+
+	var filter repository.TaskFilter
+	for _, term := range terms {
+		if !term.hasKey {
+			filter.Text = append(filter.Text, term.value)
+			continue
+		}
+
+		if term.value == "" {
+			return repository.TaskFilter{}, fmt.Errorf("%s needs a value", term.key)
+		}
+		values := splitList(term.value)
+
+		switch term.key {
+		case "project":
+			for _, value := range values {
+				id, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return repository.TaskFilter{}, fmt.Errorf("invalid project ID %q", value)
+				}
+				filter.ProjectIDs = append(filter.ProjectIDs, uint(id))
+			}
+		case "status":
+			for _, value := range values {
+				category := models.StatusCategory(strings.ToLower(value))
+				switch category {
+				case models.OpenCategory, models.ActiveCategory, models.ClosedCategory:
+					filter.Status.Categories = append(filter.Status.Categories, category)
+					continue
+				}
+				if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+					filter.Status.IDs = append(filter.Status.IDs, uint(id))
+					continue
+				}
+				filter.Status.Names = append(filter.Status.Names, value)
+			}
+		case "assignee":
+			for _, value := range values {
+				if strings.EqualFold(value, "none") {
+					value = ""
+				}
+				filter.AssignedTo = append(filter.AssignedTo, ctx.user(value))
+			}
+		case "creator":
+			for _, value := range values {
+				filter.CreatedBy = append(filter.CreatedBy, ctx.user(value))
+			}
+		case "involves":
+			for _, value := range values {
+				filter.Involves = append(filter.Involves, ctx.user(value))
+			}
+		case "label":
+			filter.LabelGroups = append(filter.LabelGroups, values)
+		case "priority":
+			for _, value := range values {
+				priority := models.Priority(strings.ToUpper(value))
+				switch priority {
+				case models.CriticalPriority, models.HighPriority, models.NormalPriority, models.LowPriority:
+					filter.Priorities = append(filter.Priorities, priority)
+				default:
+					return repository.TaskFilter{}, fmt.Errorf("priority must be P0, P1, P2 or P3")
+				}
+			}
+		case "is":
+			var done bool
+			switch strings.ToLower(term.value) {
+			case "open":
+			case "done":
+				done = true
+			default:
+				return repository.TaskFilter{}, fmt.Errorf("is must be open or done")
+			}
+			if filter.Done != nil && *filter.Done != done {
+				return repository.TaskFilter{}, fmt.Errorf("a task can't be both open and done")
+			}
+			filter.Done = &done
+		case "deadline":
+			if strings.EqualFold(term.value, "none") {
+				filter.Deadline.Missing = true
+				continue
+			}
+			if filter.Deadline, err = parseRange(term.value, ctx); err != nil {
+				return repository.TaskFilter{}, fmt.Errorf("deadline: %w", err)
+			}
+		case "created":
+			if filter.Created, err = parseRange(term.value, ctx); err != nil {
+				return repository.TaskFilter{}, fmt.Errorf("created: %w", err)
+			}
+		case "updated":
+			if filter.Updated, err = parseRange(term.value, ctx); err != nil {
+				return repository.TaskFilter{}, fmt.Errorf("updated: %w", err)
+			}
+		default:
+			return repository.TaskFilter{}, fmt.Errorf("unknown filter %q, put it in quotes to search for it", term.key)
+		}
+	}
+	return filter, nil
+}
+
+// ParseSort reads a list of fields to sort by, separated by commas. Fields are sorted
+// ascending unless they start with a -, like -updated.
+func ParseSort(sort string) ([]repository.TaskOrder, error) {
+	var order []repository.TaskOrder
+	seen := map[repository.TaskSortField]bool{}
+	for _, value := range splitList(sort) {
+		o := repository.TaskOrder{}
+		if strings.HasPrefix(value, "-") {
+			o.Desc = true
+			value = value[1:]
+		}
+
+		o.Field = repository.TaskSortField(strings.ToLower(value))
+		switch o.Field {
+		case repository.SortByPriority, repository.SortByDeadline, repository.SortByCreated,
+			repository.SortByUpdated, repository.SortByTitle:
+		default:
+			return nil, fmt.Errorf("can't sort by %q", value)
+		}
+
+		if seen[o.Field] {
+			return nil, fmt.Errorf("%s is in the sort more than once", o.Field)
+		}
+		seen[o.Field] = true
+		order = append(order, o)
+	}
+	return order, nil
+}
+
+// user replaces me with the caller's ID.
+func (ctx Context) user(value string) string {
+	if strings.EqualFold(value, "me") {
+		return ctx.Me
+	}
+	return value
+}
+
+type term struct {
+	key    string
+	value  string
+	hasKey bool
+}
+
+var errUnclosedQuote = errors.New("the query has an unclosed quote")
+
+// split breaks the query into terms at spaces outside of quotes.
+func split(query string) ([]term, error) {
+	var terms []term
+	var current strings.Builder
+	var key string
+	hasKey, quoted, started := false, false, false
+
+	flush := func() {
+		if started {
+			value := current.String()
+			if hasKey || value != "" {
+				terms = append(terms, term{key: key, value: value, hasKey: hasKey})
+			}
+		}
+		current.Reset()
+		key, hasKey, started = "", false, false
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		case r == ':' && !quoted && !hasKey && current.Len() > 0:
+			key = strings.ToLower(current.String())
+			hasKey = true
+			current.Reset()
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, errUnclosedQuote
+	}
+	flush()
+	return terms, nil
+}
+
+// splitList splits a comma separated list, dropping empty values.
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// parseRange reads a date, a range of dates or a comparison with a date.
+func parseRange(value string, ctx Context) (repository.TimeRange, error) {
+	var r repository.TimeRange
+
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(value, op) {
+			continue
+		}
+		start, end, err := parseDate(strings.TrimPrefix(value, op), ctx)
+		if err != nil {
+			return r, err
+		}
+		switch op {
+		case ">=":
+			r.From = &start
+		case ">":
+			r.From = &end
+		case "<=":
+			r.To = &end
+		case "<":
+			r.To = &start
+		}
+		return r, nil
+	}
+
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		if from == "" && to == "" {
+			return r, fmt.Errorf("a range needs a start or an end")
+		}
+		if from != "" {
+			start, _, err := parseDate(from, ctx)
+			if err != nil {
+				return r, err
+			}
+			r.From = &start
+		}
+		if to != "" {
+			_, end, err := parseDate(to, ctx)
+			if err != nil {
+				return r, err
+			}
+			r.To = &end
+		}
+		if r.From != nil && r.To != nil && !r.From.Before(*r.To) {
+			return r, fmt.Errorf("the range ends before it starts")
+		}
+		return r, nil
+	}
+
+	start, end, err := parseDate(value, ctx)
+	if err != nil {
+		return r, err
+	}
+	r.From, r.To = &start, &end
+	return r, nil
+}
+
+// parseDate returns when the date starts and when the next one does. A time is a moment
+// rather than a day, so it ends straight after it starts.
+func parseDate(value string, ctx Context) (time.Time, time.Time, error) {
+	now := ctx.Now.In(ctx.Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, ctx.Location)
+
+	var day time.Time
+	switch lower := strings.ToLower(value); {
+	case lower == "today":
+		day = today
+	case lower == "yesterday":
+		day = today.AddDate(0, 0, -1)
+	case lower == "tomorrow":
+		day = today.AddDate(0, 0, 1)
+	case len(lower) > 1 && (strings.HasSuffix(lower, "d") || strings.HasSuffix(lower, "w")):
+		n, err := strconv.Atoi(strings.TrimPrefix(lower[:len(lower)-1], "+"))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", value)
+		}
+		if strings.HasSuffix(lower, "w") {
+			n *= 7
+		}
+		day = today.AddDate(0, 0, n)
+	default:
+		if t, err := time.ParseInLocation("2006-01-02", value, ctx.Location); err == nil {
+			day = t
+			break
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, t.Add(time.Microsecond), nil
+		}
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return day, day.AddDate(0, 0, 1), nil
+}
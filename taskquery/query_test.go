@@ -0,0 +1,129 @@
+package taskquery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/todanni/api/models"
+	"github.com/todanni/api/repository"
+)
+
+var london, _ = time.LoadLocation("Europe/London")
+
+var testContext = Context{
+	Me:       "user-1",
+	Location: london,
+	Now:      time.Date(2023, time.June, 14, 23, 30, 0, 0, time.UTC),
+}
+
+func TestParse(t *testing.T) {
+	filter, err := Parse(`project:1,2 status:open,"In progress",7 assignee:me,none creator:user-2 `+
+		`involves:me label:bug,design label:urgent priority:p0,P1 is:open "release notes" Safari`, testContext)
+	require.NoError(t, err)
+
+	open := false
+	require.Equal(t, repository.TaskFilter{
+		ProjectIDs: []uint{1, 2},
+		Status: repository.StatusFilter{
+			IDs:        []uint{7},
+			Names:      []string{"In progress"},
+			Categories: []models.StatusCategory{models.OpenCategory},
+		},
+		AssignedTo:  []string{"user-1", ""},
+		CreatedBy:   []string{"user-2"},
+		Involves:    []string{"user-1"},
+		LabelGroups: [][]string{{"bug", "design"}, {"urgent"}},
+		Priorities:  []models.Priority{models.CriticalPriority, models.HighPriority},
+		Done:        &open,
+		Text:        []string{"release notes", "Safari"},
+	}, filter)
+
+	filter, err = Parse("", testContext)
+	require.NoError(t, err)
+	require.Equal(t, repository.TaskFilter{}, filter)
+
+	for _, query := range []string{
+		"project:abc",
+		"priority:urgent",
+		"is:blocked",
+		"is:open is:done",
+		"colour:red",
+		"status:",
+		`"unclosed`,
+		"deadline:soon",
+		"created:..",
+		"deadline:2023-02-01..2023-01-01",
+	} {
+		_, err = Parse(query, testContext)
+		require.Error(t, err, query)
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	// It's already the 15th in London
+	day := func(d int) *time.Time {
+		t := time.Date(2023, time.June, d, 0, 0, 0, 0, london)
+		return &t
+	}
+
+	tests := map[string]repository.TimeRange{
+		"deadline:today":                  {From: day(15), To: day(16)},
+		"deadline:tomorrow":               {From: day(16), To: day(17)},
+		"deadline:<today":                 {To: day(15)},
+		"deadline:<=+7d":                  {To: day(23)},
+		"deadline:>1w":                    {From: day(23)},
+		"deadline:>=-3d":                  {From: day(12)},
+		"deadline:2023-06-01..2023-06-10": {From: day(1), To: day(11)},
+		"deadline:2023-06-20..":           {From: day(20)},
+		"deadline:none":                   {Missing: true},
+	}
+	for query, expected := range tests {
+		filter, err := Parse(query, testContext)
+		require.NoError(t, err, query)
+		require.Equal(t, expected, filter.Deadline, query)
+	}
+
+	filter, err := Parse("updated:>=2023-06-01T12:00:00Z created:yesterday", testContext)
+	require.NoError(t, err)
+	require.True(t, filter.Updated.From.Equal(time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)))
+	require.Nil(t, filter.Updated.To)
+	require.Equal(t, repository.TimeRange{From: day(14), To: day(15)}, filter.Created)
+}
+
+func TestParseSort(t *testing.T) {
+	order, err := ParseSort("priority, -deadline,title")
+	require.NoError(t, err)
+	require.Equal(t, []repository.TaskOrder{
+		{Field: repository.SortByPriority},
+		{Field: repository.SortByDeadline, Desc: true},
+		{Field: repository.SortByTitle},
+	}, order)
+	require.Equal(t, "priority,-deadline,title", FormatSort(order))
+
+	order, err = ParseSort("")
+	require.NoError(t, err)
+	require.Empty(t, order)
+
+	_, err = ParseSort("colour")
+	require.Error(t, err)
+	_, err = ParseSort("deadline,-deadline")
+	require.Error(t, err)
+}
+
+func TestCursor(t *testing.T) {
+	order := []repository.TaskOrder{{Field: repository.SortByDeadline, Desc: true}}
+	task := models.Task{ID: 42, Deadline: time.Date(2023, time.June, 1, 9, 0, 0, 0, time.UTC)}
+
+	token := EncodeCursor(repository.NewTaskCursor(task, order), order)
+	cursor, err := DecodeCursor(token, order)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2023-06-01T09:00:00Z", "42"}, cursor.Values)
+
+	// A cursor only works with the order it was made for
+	_, err = DecodeCursor(token, []repository.TaskOrder{{Field: repository.SortByDeadline}})
+	require.ErrorIs(t, err, repository.ErrInvalidCursor)
+	_, err = DecodeCursor("not a cursor", order)
+	require.ErrorIs(t, err, repository.ErrInvalidCursor)
+}
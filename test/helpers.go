@@ -2,6 +2,7 @@ package test
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
@@ -20,6 +21,10 @@ type DbSuite struct {
 const (
 	dbName = "test"
 	passwd = "test"
+
+	// MinioAccessKey and MinioSecretKey are the credentials of the MinIO container's root user
+	MinioAccessKey = "minio"
+	MinioSecretKey = "minio-secret"
 )
 
 // SetupGormWithDocker creates a temporary Postgres docker container to run tests against.
@@ -72,6 +77,50 @@ func SetupGormWithDocker() (*gorm.DB, func()) {
 	return gdb, fnCleanup
 }
 
+// SetupMinioWithDocker creates a temporary MinIO docker container to run tests against.
+// Returns its endpoint and function to clean up container after testing is complete.
+func SetupMinioWithDocker() (string, func()) {
+	pool, err := dockertest.NewPool("")
+	chk(err)
+
+	runDockerOpt := &dockertest.RunOptions{
+		Repository: "minio/minio",
+		Tag:        "latest",
+		Cmd:        []string{"server", "/data"},
+		Env:        []string{"MINIO_ROOT_USER=" + MinioAccessKey, "MINIO_ROOT_PASSWORD=" + MinioSecretKey},
+	}
+
+	fnConfig := func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.NeverRestart()
+	}
+
+	resource, err := pool.RunWithOptions(runDockerOpt, fnConfig)
+	chk(err)
+	fnCleanup := func() {
+		err := resource.Close()
+		chk(err)
+	}
+
+	endpoint := fmt.Sprintf("http://localhost:%s", resource.GetPort("9000/tcp"))
+
+	// retry until the server is ready
+	err = pool.Retry(func() error {
+		resp, err := http.Get(endpoint + "/minio/health/live")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("minio isn't ready: %s", resp.Status)
+		}
+		return nil
+	})
+	chk(err)
+
+	return endpoint, fnCleanup
+}
+
 func chk(err error) {
 	if err != nil {
 		panic(err)
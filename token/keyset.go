@@ -0,0 +1,132 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+var (
+	ErrorUnsupportedKey = errors.New("signing key must be an RSA or Ed25519 private key")
+)
+
+// KeySet holds the keys access tokens are signed and verified with. Tokens are signed
+// with the active key, and verified with whichever key in the set matches their key ID,
+// so tokens signed before a rotation stay valid while the previous key is kept around.
+type KeySet struct {
+	active jwk.Key
+	public jwk.Set
+}
+
+// NewKeySet builds a key set from the active private key and any previous ones.
+// Keys can be RSA, signing with RS256, or Ed25519, signing with EdDSA, and are
+// identified by their thumbprint.
+func NewKeySet(active interface{}, previous ...interface{}) (*KeySet, error) {
+	activeKey, err := newSigningKey(active)
+	if err != nil {
+		return nil, err
+	}
+
+	public := jwk.NewSet()
+	for _, key := range append([]interface{}{active}, previous...) {
+		signingKey, err := newSigningKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey, err := jwk.PublicKeyOf(signingKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = public.AddKey(publicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return &KeySet{
+		active: activeKey,
+		public: public,
+	}, nil
+}
+
+// ParseKeySet builds a key set from PEM encoded private keys, the first being the active one.
+// Empty previous keys are skipped.
+func ParseKeySet(activePEM []byte, previousPEMs ...[]byte) (*KeySet, error) {
+	active, _, err := jwk.DecodePEM(activePEM)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse active signing key: %w", err)
+	}
+
+	previous := make([]interface{}, 0, len(previousPEMs))
+	for _, previousPEM := range previousPEMs {
+		if len(previousPEM) == 0 {
+			continue
+		}
+
+		key, _, err := jwk.DecodePEM(previousPEM)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse previous signing key: %w", err)
+		}
+		previous = append(previous, key)
+	}
+
+	return NewKeySet(active, previous...)
+}
+
+// GenerateKeySet returns a key set with a new random Ed25519 key. Tokens signed
+// with it can't be verified once the process exits, so it's only meant for
+// development and tests.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(private)
+}
+
+// ActiveKeyID returns the ID of the key new tokens are signed with.
+func (k *KeySet) ActiveKeyID() string {
+	return k.active.KeyID()
+}
+
+// MarshalJSON returns the public keys in the set as a JWKS document.
+func (k *KeySet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.public)
+}
+
+func newSigningKey(raw interface{}) (jwk.Key, error) {
+	var alg jwa.SignatureAlgorithm
+	switch raw.(type) {
+	case *rsa.PrivateKey:
+		alg = jwa.RS256
+	case ed25519.PrivateKey:
+		alg = jwa.EdDSA
+	default:
+		return nil, ErrorUnsupportedKey
+	}
+
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = jwk.AssignKeyID(key); err != nil {
+		return nil, err
+	}
+
+	if err = key.Set(jwk.AlgorithmKey, alg); err != nil {
+		return nil, err
+	}
+
+	if err = key.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
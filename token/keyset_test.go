@@ -0,0 +1,101 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySet_Rotation(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	oldKeys, err := NewKeySet(oldKey)
+	require.NoError(t, err)
+	rotatedKeys, err := NewKeySet(newKey, oldKey)
+	require.NoError(t, err)
+	require.NotEqual(t, oldKeys.ActiveKeyID(), rotatedKeys.ActiveKeyID())
+
+	accessToken := NewAccessToken()
+	accessToken.SetUserID("user1234")
+
+	// Tokens signed before the rotation are still accepted
+	signedToken, err := accessToken.SignToken(oldKeys)
+	require.NoError(t, err)
+	parsedToken := &ToDanniToken{}
+	require.NoError(t, parsedToken.Parse(string(signedToken), rotatedKeys))
+	require.Equal(t, "user1234", parsedToken.GetUserID())
+
+	// Tokens signed after it can't be verified with the old key set
+	signedToken, err = accessToken.SignToken(rotatedKeys)
+	require.NoError(t, err)
+	require.NoError(t, parsedToken.Parse(string(signedToken), rotatedKeys))
+	require.Error(t, parsedToken.Parse(string(signedToken), oldKeys))
+}
+
+func TestKeySet_UnknownKey(t *testing.T) {
+	otherKeys := mustGenerateKeySet()
+
+	accessToken := NewAccessToken()
+	accessToken.SetUserID("user1234")
+	signedToken, err := accessToken.SignToken(otherKeys)
+	require.NoError(t, err)
+
+	parsedToken := &ToDanniToken{}
+	require.Error(t, parsedToken.Parse(string(signedToken), signingKeys))
+}
+
+func TestKeySet_ParsePEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	activePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	previousPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	keys, err := ParseKeySet(activePEM, previousPEM)
+	require.NoError(t, err)
+	require.Equal(t, 2, keys.public.Len())
+
+	_, err = ParseKeySet([]byte("not a key"))
+	require.Error(t, err)
+}
+
+func TestKeySet_JWKSHasNoPrivateKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys, err := NewKeySet(rsaKey, edKey)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(keys)
+	require.NoError(t, err)
+
+	var document struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(jwks, &document))
+	require.Len(t, document.Keys, 2)
+	require.Equal(t, keys.ActiveKeyID(), document.Keys[0]["kid"])
+
+	for _, key := range document.Keys {
+		require.NotEmpty(t, key["kid"])
+		require.NotEmpty(t, key["alg"])
+		require.NotContains(t, key, "d")
+		require.NotContains(t, key, "p")
+		require.NotContains(t, key, "q")
+	}
+}
@@ -3,27 +3,67 @@ package token
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/todanni/api/models"
 )
 
 var (
 	ErrorEmptyAuthHeader = errors.New("authorization header wasn't set")
 	ErrorTokenNotPresent = errors.New("token not present")
+	ErrorTokenRevoked    = errors.New("token has been revoked")
+	ErrorInvalidPAT      = errors.New("personal access token is invalid, expired or revoked")
+	ErrorReadOnlyPAT     = errors.New("personal access token is read-only")
 )
 
+// SessionStore keeps track of login sessions and revoked tokens.
+type SessionStore interface {
+	// IsRevoked returns whether the token with the given ID, or the session it belongs to, has been revoked.
+	IsRevoked(tokenID, sessionID string) (bool, error)
+	// TouchSession records that the session was just used from the given address and device.
+	TouchSession(sessionID, ipAddress, userAgent string) error
+}
+
+// PersonalAccessTokenStore looks up personal access tokens presented as bearer tokens.
+type PersonalAccessTokenStore interface {
+	// GetPersonalAccessTokenByHash returns the token with the given hash, or gorm.ErrRecordNotFound.
+	GetPersonalAccessTokenByHash(hash string) (models.PersonalAccessToken, error)
+	// TouchPersonalAccessToken records that the token was just used.
+	TouchPersonalAccessToken(id uint) error
+}
+
 type AuthMiddleware struct {
-	signingKey string
+	keys           *KeySet
+	sessions       SessionStore
+	personalTokens PersonalAccessTokenStore
 }
 
-func NewAuthMiddleware(signingKey string) *AuthMiddleware {
+func NewAuthMiddleware(keys *KeySet) *AuthMiddleware {
 	return &AuthMiddleware{
-		signingKey: signingKey,
+		keys: keys,
 	}
 }
 
+// WithSessionStore makes the middleware reject tokens the store says are revoked,
+// and keep the store up to date with when each session was last used.
+func (m *AuthMiddleware) WithSessionStore(sessions SessionStore) *AuthMiddleware {
+	m.sessions = sessions
+	return m
+}
+
+// WithPersonalAccessTokens makes the middleware accept personal access tokens from the store
+// as bearer tokens, alongside JWTs.
+func (m *AuthMiddleware) WithPersonalAccessTokens(personalTokens PersonalAccessTokenStore) *AuthMiddleware {
+	m.personalTokens = personalTokens
+	return m
+}
+
 func (m *AuthMiddleware) JwtMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		accessTokenString, err := m.checkCookieValue(r)
@@ -37,6 +77,11 @@ func (m *AuthMiddleware) JwtMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if m.personalTokens != nil && IsPersonalAccessToken(accessTokenString) {
+			m.servePersonalAccessToken(w, r, next, accessTokenString)
+			return
+		}
+
 		accessToken, err := m.parseToken(accessTokenString)
 		if err != nil {
 			log.Error(err)
@@ -44,12 +89,46 @@ func (m *AuthMiddleware) JwtMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if m.sessions != nil {
+			revoked, err := m.sessions.IsRevoked(accessToken.GetTokenID(), accessToken.GetSessionID())
+			if err != nil {
+				log.Error(err)
+				http.Error(w, "couldn't check token", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, ErrorTokenRevoked.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			if sessionID := accessToken.GetSessionID(); sessionID != "" {
+				err = m.sessions.TouchSession(sessionID, ClientIP(r), r.UserAgent())
+				if err != nil {
+					log.Error(err)
+				}
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), AccessTokenContextKey, accessToken)
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// ClientIP returns the address the request came from, preferring the first
+// address in X-Forwarded-For as the service runs behind a proxy.
+func ClientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (m *AuthMiddleware) checkAuthHeader(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -73,8 +152,40 @@ func (m *AuthMiddleware) checkCookieValue(r *http.Request) (string, error) {
 	return accessTokenCookie.Value, err
 }
 
+// servePersonalAccessToken authenticates the request with a personal access token,
+// refusing anything but reads if the token is read-only.
+func (m *AuthMiddleware) servePersonalAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, value string) {
+	pat, err := m.personalTokens.GetPersonalAccessTokenByHash(HashRefreshToken(value))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error(err)
+		http.Error(w, "couldn't check token", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !pat.IsActive(time.Now()) {
+		http.Error(w, ErrorInvalidPAT.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if pat.Scope != models.ReadWriteScope && !isReadOnlyMethod(r.Method) {
+		http.Error(w, ErrorReadOnlyPAT.Error(), http.StatusForbidden)
+		return
+	}
+
+	err = m.personalTokens.TouchPersonalAccessToken(pat.ID)
+	if err != nil {
+		log.Error(err)
+	}
+
+	ctx := context.WithValue(r.Context(), AccessTokenContextKey, FromPersonalAccessToken(pat))
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
 func (m *AuthMiddleware) parseToken(tokenString string) (*ToDanniToken, error) {
 	accessToken := &ToDanniToken{}
-	err := accessToken.Parse(tokenString, m.signingKey)
+	err := accessToken.Parse(tokenString, m.keys)
 	return accessToken, err
 }
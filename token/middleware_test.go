@@ -4,11 +4,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
 
 	"github.com/todanni/api/models"
 )
@@ -34,7 +36,7 @@ func (s *AuthMiddlewareTestSuite) SetupSuite() {
 	dashboards := make([]models.Dashboard, 0)
 	accessToken.SetDashboardPermissions(dashboards)
 
-	signedToken, err := accessToken.SignToken([]byte(signingKey))
+	signedToken, err := accessToken.SignToken(signingKeys)
 	require.NoError(s.T(), err)
 	require.NotNil(s.T(), signedToken)
 	s.token = string(signedToken)
@@ -43,7 +45,7 @@ func (s *AuthMiddlewareTestSuite) SetupSuite() {
 func (s *AuthMiddlewareTestSuite) Test_AccessToken_Good() {
 	router := mux.NewRouter()
 
-	mw := NewAuthMiddleware(signingKey)
+	mw := NewAuthMiddleware(signingKeys)
 
 	router.Use(mw.JwtMiddleware)
 	router.HandleFunc("/", dummyHandler).Methods("GET")
@@ -58,7 +60,7 @@ func (s *AuthMiddlewareTestSuite) Test_AccessToken_Good() {
 
 func (s *AuthMiddlewareTestSuite) Test_NoAuthHeader_CookiePresent() {
 	router := mux.NewRouter()
-	mw := NewAuthMiddleware(signingKey)
+	mw := NewAuthMiddleware(signingKeys)
 
 	router.Use(mw.JwtMiddleware)
 	router.HandleFunc("/", dummyHandler).Methods("GET")
@@ -76,6 +78,110 @@ func (s *AuthMiddlewareTestSuite) Test_NoAuthHeader_CookiePresent() {
 	require.Equal(s.T(), 200, rw.Code)
 }
 
+type fakeSessionStore struct {
+	revoked map[string]bool
+	touched []string
+}
+
+func (f *fakeSessionStore) IsRevoked(tokenID, sessionID string) (bool, error) {
+	return f.revoked[tokenID] || f.revoked[sessionID], nil
+}
+
+func (f *fakeSessionStore) TouchSession(sessionID, ipAddress, userAgent string) error {
+	f.touched = append(f.touched, sessionID)
+	return nil
+}
+
+func (s *AuthMiddlewareTestSuite) Test_SessionStore_RevokedSession() {
+	sessionID := uuid.New().String()
+
+	accessToken := NewAccessToken()
+	accessToken.SetUserID("user1234")
+	accessToken.SetSessionID(sessionID)
+	signedToken, err := accessToken.SignToken(signingKeys)
+	require.NoError(s.T(), err)
+
+	store := &fakeSessionStore{revoked: map[string]bool{}}
+	router := mux.NewRouter()
+	mw := NewAuthMiddleware(signingKeys).WithSessionStore(store)
+
+	router.Use(mw.JwtMiddleware)
+	router.HandleFunc("/", dummyHandler).Methods("GET")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Add("Authorization", "Bearer "+string(signedToken))
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	require.Equal(s.T(), 200, rw.Code)
+	require.Equal(s.T(), []string{sessionID}, store.touched)
+
+	store.revoked[sessionID] = true
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	require.Equal(s.T(), 401, rw.Code)
+}
+
+type fakePersonalAccessTokenStore struct {
+	tokens map[string]models.PersonalAccessToken
+}
+
+func (f *fakePersonalAccessTokenStore) GetPersonalAccessTokenByHash(hash string) (models.PersonalAccessToken, error) {
+	pat, ok := f.tokens[hash]
+	if !ok {
+		return models.PersonalAccessToken{}, gorm.ErrRecordNotFound
+	}
+	return pat, nil
+}
+
+func (f *fakePersonalAccessTokenStore) TouchPersonalAccessToken(id uint) error {
+	return nil
+}
+
+func (s *AuthMiddlewareTestSuite) Test_PersonalAccessToken() {
+	readToken, readHash, _, err := NewPersonalAccessToken()
+	require.NoError(s.T(), err)
+	revokedToken, revokedHash, _, err := NewPersonalAccessToken()
+	require.NoError(s.T(), err)
+
+	revokedAt := time.Now().Add(-time.Hour)
+	store := &fakePersonalAccessTokenStore{tokens: map[string]models.PersonalAccessToken{
+		readHash:    {ID: 1, UserID: "user1234", Scope: models.ReadScope, ProjectIDs: []uint{1}},
+		revokedHash: {ID: 2, UserID: "user1234", Scope: models.ReadWriteScope, RevokedAt: &revokedAt},
+	}}
+
+	var seen *ToDanniToken
+	router := mux.NewRouter()
+	mw := NewAuthMiddleware(signingKeys).WithPersonalAccessTokens(store)
+	router.Use(mw.JwtMiddleware)
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Context().Value(AccessTokenContextKey).(*ToDanniToken)
+	}).Methods("GET", "POST")
+
+	request := func(method, pat string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Add("Authorization", "Bearer "+pat)
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	require.Equal(s.T(), 200, request("GET", readToken))
+	require.Equal(s.T(), "user1234", seen.GetUserID())
+	require.True(s.T(), seen.IsPersonalAccessToken())
+	require.True(s.T(), seen.AllowsProject(1))
+	require.False(s.T(), seen.AllowsProject(2))
+
+	require.Equal(s.T(), 403, request("POST", readToken))
+	require.Equal(s.T(), 401, request("GET", revokedToken))
+	require.Equal(s.T(), 401, request("GET", PersonalAccessTokenPrefix+"unknown"))
+
+	// JWTs are still accepted alongside personal access tokens
+	require.Equal(s.T(), 200, request("GET", s.token))
+	require.False(s.T(), seen.IsPersonalAccessToken())
+	require.True(s.T(), seen.AllowsProject(2))
+}
+
 func TestAuthMiddlewareTestSuite(t *testing.T) {
 	suite.Run(t, new(AuthMiddlewareTestSuite))
 }
@@ -0,0 +1,95 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+
+	"github.com/todanni/api/models"
+)
+
+const (
+	// PersonalAccessTokenPrefix starts every personal access token, so they can be
+	// told apart from JWTs and picked out by secret scanners.
+	PersonalAccessTokenPrefix = "tdn_"
+	personalAccessTokenBytes  = 32
+	// personalAccessTokenShownLength is how much of a token is kept in the clear to identify it.
+	personalAccessTokenShownLength = len(PersonalAccessTokenPrefix) + 4
+)
+
+// NewPersonalAccessToken returns a new random personal access token to hand to the user
+// once, the hash of it which is what gets stored, and the prefix that's shown in listings.
+func NewPersonalAccessToken() (string, string, string, error) {
+	raw := make([]byte, personalAccessTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", "", err
+	}
+
+	pat := PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	return pat, HashRefreshToken(pat), pat[:personalAccessTokenShownLength], nil
+}
+
+// IsPersonalAccessToken returns whether the string looks like a personal access token rather than a JWT.
+func IsPersonalAccessToken(value string) bool {
+	return strings.HasPrefix(value, PersonalAccessTokenPrefix)
+}
+
+// FromPersonalAccessToken returns an unsigned access token for a request
+// authenticated with the personal access token. It's only used within the request
+// and carries the personal access token's scope and project restrictions.
+func FromPersonalAccessToken(pat models.PersonalAccessToken) *ToDanniToken {
+	accessToken := NewAccessToken()
+	accessToken.SetUserID(pat.UserID)
+	accessToken.setClaim("pat", pat.ID)
+	accessToken.setClaim("scope", string(pat.Scope))
+	if len(pat.ProjectIDs) > 0 {
+		accessToken.setClaim("pat_projects", pat.ProjectIDs)
+	}
+	return accessToken
+}
+
+// IsPersonalAccessToken returns whether the request was authenticated with a personal access token.
+func (t *ToDanniToken) IsPersonalAccessToken() bool {
+	_, ok := t.token.Get("pat")
+	return ok
+}
+
+// GetScope returns what the token can be used for. Tokens from a login can do anything the user can.
+func (t *ToDanniToken) GetScope() models.TokenScope {
+	scope, ok := t.token.Get("scope")
+	if !ok {
+		return models.ReadWriteScope
+	}
+	return models.TokenScope(scope.(string))
+}
+
+// IsProjectRestricted returns whether the token can only be used for some of the user's projects.
+func (t *ToDanniToken) IsProjectRestricted() bool {
+	_, ok := t.token.Get("pat_projects")
+	return ok
+}
+
+// AllowedProjects returns the projects the token is restricted to, or nil if it isn't.
+func (t *ToDanniToken) AllowedProjects() []uint {
+	projects, ok := t.token.Get("pat_projects")
+	if !ok {
+		return nil
+	}
+	return projects.([]uint)
+}
+
+// AllowsProject returns whether the token may be used for the project. It doesn't check
+// that the user is a member, only that the token hasn't been restricted to other projects.
+func (t *ToDanniToken) AllowsProject(projectID uint) bool {
+	projects, ok := t.token.Get("pat_projects")
+	if !ok {
+		return true
+	}
+
+	for _, allowed := range projects.([]uint) {
+		if allowed == projectID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	RefreshTokenCookieName = "todanni-refresh-token"
+	refreshTokenBytes      = 32
+)
+
+var (
+	RefreshExpirationTime = 30 * 24 * time.Hour
+)
+
+// NewRefreshToken returns a new random refresh token to hand to the client,
+// along with the hash of it which is what gets stored.
+func NewRefreshToken() (string, string, error) {
+	raw := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+	return refreshToken, HashRefreshToken(refreshToken), nil
+}
+
+// HashRefreshToken returns the hex encoded SHA-256 hash of a refresh token.
+func HashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,27 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshToken_HashMatches(t *testing.T) {
+	refreshToken, hash, err := NewRefreshToken()
+	require.NoError(t, err)
+	require.NotEmpty(t, refreshToken)
+
+	require.Equal(t, hash, HashRefreshToken(refreshToken))
+	require.NotEqual(t, refreshToken, hash)
+}
+
+func TestRefreshToken_Unique(t *testing.T) {
+	first, firstHash, err := NewRefreshToken()
+	require.NoError(t, err)
+
+	second, secondHash, err := NewRefreshToken()
+	require.NoError(t, err)
+
+	require.NotEqual(t, first, second)
+	require.NotEqual(t, firstHash, secondHash)
+}
@@ -4,7 +4,6 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	log "github.com/sirupsen/logrus"
 
@@ -28,9 +27,10 @@ type ToDanniToken struct {
 }
 
 // NewAccessToken returns a ToDanni JWT issued at the current time
-// with no claims yet set on it, other than issuer.
+// with no claims yet set on it, other than issuer and a unique token ID.
 func NewAccessToken() *ToDanniToken {
 	t, _ := jwt.NewBuilder().
+		JwtID(uuid.New().String()).
 		Issuer(ToDanniTokenIssuer).
 		IssuedAt(time.Now()).
 		Expiration(time.Now().Add(ExpirationTime)).
@@ -38,9 +38,10 @@ func NewAccessToken() *ToDanniToken {
 	return &ToDanniToken{token: t}
 }
 
-// SignToken returns the
-func (t *ToDanniToken) SignToken(signingKey []byte) ([]byte, error) {
-	signed, err := jwt.Sign(t.token, jwt.WithKey(jwa.HS256, signingKey))
+// SignToken returns the token signed with the key set's active key,
+// with the key's ID in the header.
+func (t *ToDanniToken) SignToken(keys *KeySet) ([]byte, error) {
+	signed, err := jwt.Sign(t.token, jwt.WithKey(keys.active.Algorithm(), keys.active))
 	if err != nil {
 		return nil, err
 	}
@@ -48,8 +49,10 @@ func (t *ToDanniToken) SignToken(signingKey []byte) ([]byte, error) {
 	return signed, nil
 }
 
-func (t *ToDanniToken) Parse(signedToken, signingKey string) error {
-	verifiedToken, err := jwt.Parse([]byte(signedToken), jwt.WithKey(jwa.HS256, []byte(signingKey)))
+// Parse verifies the signed token with the key from the key set matching its
+// key ID, and validates its claims.
+func (t *ToDanniToken) Parse(signedToken string, keys *KeySet) error {
+	verifiedToken, err := jwt.Parse([]byte(signedToken), jwt.WithKeySet(keys.public))
 	if err != nil {
 		log.Error(err)
 		return err
@@ -71,6 +74,30 @@ func (t *ToDanniToken) GetUserID() string {
 	return userID.(string)
 }
 
+// GetTokenID returns the token's unique ID (the jti claim).
+func (t *ToDanniToken) GetTokenID() string {
+	return t.token.JwtID()
+}
+
+// GetExpiration returns when the token stops being valid.
+func (t *ToDanniToken) GetExpiration() time.Time {
+	return t.token.Expiration()
+}
+
+// SetSessionID sets the login session the token was issued for.
+// Tokens re-minted by a refresh keep the session ID of the original login.
+func (t *ToDanniToken) SetSessionID(id string) {
+	t.setClaim("sid", id)
+}
+
+func (t *ToDanniToken) GetSessionID() string {
+	sessionID, ok := t.token.Get("sid")
+	if !ok {
+		return ""
+	}
+	return sessionID.(string)
+}
+
 func (t *ToDanniToken) SetDashboardPermissions(dashboards []models.Dashboard) *ToDanniToken {
 	userDashboardIDs := make([]uuid.UUID, 0)
 
@@ -87,11 +114,19 @@ func (t *ToDanniToken) HasDashboardPermission(dashboard uuid.UUID) bool {
 		return false
 	}
 
-	dashboardsPermissionsArray := dashboardsPermissions.([]interface{})
-
-	for _, value := range dashboardsPermissionsArray {
-		if value.(string) == dashboard.String() {
-			return true
+	switch dashboardsPermissionsArray := dashboardsPermissions.(type) {
+	case []uuid.UUID:
+		// Set on this token rather than parsed from a signed one
+		for _, value := range dashboardsPermissionsArray {
+			if value == dashboard {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, value := range dashboardsPermissionsArray {
+			if value.(string) == dashboard.String() {
+				return true
+			}
 		}
 	}
 	return false
@@ -113,13 +148,21 @@ func (t *ToDanniToken) HasProjectPermission(project uint) bool {
 		return false
 	}
 
-	projectsPermissionsArray := projectPermissions.([]interface{})
-
-	for _, permission := range projectsPermissionsArray {
-		projectID := permission.(float64)
+	switch projectsPermissionsArray := projectPermissions.(type) {
+	case []uint:
+		// Set on this token rather than parsed from a signed one
+		for _, projectID := range projectsPermissionsArray {
+			if projectID == project {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, permission := range projectsPermissionsArray {
+			projectID := permission.(float64)
 
-		if uint(projectID) == project {
-			return true
+			if uint(projectID) == project {
+				return true
+			}
 		}
 	}
 	return false
@@ -10,9 +10,15 @@ import (
 	"github.com/todanni/api/models"
 )
 
-const (
-	signingKey = "examplesigningkey"
-)
+var signingKeys = mustGenerateKeySet()
+
+func mustGenerateKeySet() *KeySet {
+	keys, err := GenerateKeySet()
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
 
 func TestToken_EndToEnd_NoDashboardsAndProjects(t *testing.T) {
 	// Issue a token
@@ -29,12 +35,12 @@ func TestToken_EndToEnd_NoDashboardsAndProjects(t *testing.T) {
 	dashboards := make([]models.Dashboard, 0)
 	accessToken.SetDashboardPermissions(dashboards)
 
-	signedToken, err := accessToken.SignToken([]byte(signingKey))
+	signedToken, err := accessToken.SignToken(signingKeys)
 	require.NoError(t, err)
 	require.NotNil(t, signedToken)
 
 	parsedToken := &ToDanniToken{}
-	err = parsedToken.Parse(string(signedToken), signingKey)
+	err = parsedToken.Parse(string(signedToken), signingKeys)
 	require.NoError(t, err)
 
 	userID := parsedToken.GetUserID()
@@ -81,12 +87,12 @@ func TestToken_EndToEnd_OneDashboardAndProject(t *testing.T) {
 	}
 	accessToken.SetDashboardPermissions(dashboards)
 
-	signedToken, err := accessToken.SignToken([]byte(signingKey))
+	signedToken, err := accessToken.SignToken(signingKeys)
 	require.NoError(t, err)
 	require.NotNil(t, signedToken)
 
 	parsedToken := &ToDanniToken{}
-	err = parsedToken.Parse(string(signedToken), signingKey)
+	err = parsedToken.Parse(string(signedToken), signingKeys)
 	require.NoError(t, err)
 
 	userID := parsedToken.GetUserID()